@@ -8,12 +8,17 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"sync"
 	"time"
+
+	"github.com/AdRoll/goamz/testutil"
 )
 
 type HTTPServer struct {
 	URL      string
 	Timeout  time.Duration
+	mu       sync.Mutex
+	faults   *testutil.Faults
 	started  bool
 	request  chan *http.Request
 	response chan ResponseFunc
@@ -80,7 +85,22 @@ func body(req *http.Request) string {
 	return string(data)
 }
 
+// SetFaults attaches f to the server so that its fault rules are applied
+// to subsequent requests. A nil f disables fault injection.
+func (s *HTTPServer) SetFaults(f *testutil.Faults) {
+	s.mu.Lock()
+	s.faults = f
+	s.mu.Unlock()
+}
+
 func (s *HTTPServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	faults := s.faults
+	s.mu.Unlock()
+	faults.Serve(w, req, http.HandlerFunc(s.serveHTTP))
+}
+
+func (s *HTTPServer) serveHTTP(w http.ResponseWriter, req *http.Request) {
 	req.ParseMultipartForm(1e6)
 	data, err := ioutil.ReadAll(req.Body)
 	if err != nil {
@@ -3,6 +3,7 @@ package autoscaling
 import (
 	"github.com/AdRoll/goamz/autoscaling/astest"
 	"github.com/AdRoll/goamz/aws"
+	"github.com/AdRoll/goamz/testutil"
 	"testing"
 )
 
@@ -200,3 +201,24 @@ func TestAutoScalingGroup(t *testing.T) {
 	}
 	testServer.Flush()
 }
+
+func TestInjectedFault(t *testing.T) {
+	if _, err := aws.EnvAuth(); err == nil {
+		t.Skip("faults are only injected into the mock server")
+	}
+	as := New(aws.Auth{AccessKey: "abc", SecretKey: "123"}, aws.Region{AutoScalingEndpoint: testServer.URL})
+	testServer.Start()
+	faults := &testutil.Faults{}
+	faults.Add(testutil.FaultRule{Fault: testutil.ThrottlingFault(testutil.QueryProtocol), Times: 1})
+	testServer.SetFaults(faults)
+	defer testServer.SetFaults(nil)
+
+	_, err := as.DescribeAutoScalingGroups(nil)
+	if err == nil {
+		t.Fatal("expected the injected throttling error")
+	}
+	if faults.Fired() != 1 {
+		t.Fatalf("fault fired %d times, want 1", faults.Fired())
+	}
+	testServer.Flush()
+}
@@ -185,6 +185,24 @@ func (s *V4Signer) Sign(req *http.Request) {
 	return
 }
 
+/*
+Credential returns the credential scope of a signature made at time t,
+prefixed with the access key, as sent in the "x-amz-credential" field of
+browser-based POST uploads and presigned URLs.
+*/
+func (s *V4Signer) Credential(t time.Time) string {
+	return s.auth.AccessKey + "/" + s.credentialScope(t)
+}
+
+/*
+SignPolicy returns the signature of the base64-encoded policy document of a
+browser-based POST upload, t being the time given in its "x-amz-date" field.
+(http://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-post-example.html)
+*/
+func (s *V4Signer) SignPolicy(t time.Time, policy string) string {
+	return s.signature(t, policy)
+}
+
 /*
 requestTime method will parse the time from the request "x-amz-date" or "date" headers.
 If the "x-amz-date" header is present, that will take priority over the "date" header.
@@ -542,6 +542,15 @@ func (s *V4SignerSuite) TestCases(c *check.C) {
 	}
 }
 
+func (s *V4SignerSuite) TestSignPolicy(c *check.C) {
+	signer := aws.NewV4Signer(s.auth, "s3", s.region)
+	t := time.Date(2015, 12, 29, 0, 0, 0, 0, time.UTC)
+	policy := "eyAiZXhwaXJhdGlvbiI6ICIyMDE1LTEyLTMwVDEyOjAwOjAwLjAwMFoiIH0="
+
+	c.Check(signer.Credential(t), check.Equals, "AKIDEXAMPLE/20151229/us-east-1/s3/aws4_request")
+	c.Check(signer.SignPolicy(t, policy), check.Equals, "c141fac1e27713aee99b132f7acbd47fb84633329efc2368fb97c8ef038818fe")
+}
+
 func ExampleV4Signer() {
 	// Get auth from env vars
 	auth, err := aws.EnvAuth()
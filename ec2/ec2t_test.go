@@ -54,6 +54,23 @@ func (s *LocalServerSuite) TestSecurityGroups(c *check.C) {
 	s.clientTests.TestSecurityGroups(c)
 }
 
+func (s *LocalServerSuite) TestThrottlingFault(c *check.C) {
+	faults := &testutil.Faults{}
+	faults.Add(testutil.FaultRule{Fault: testutil.ThrottlingFault(testutil.EC2Protocol), Nth: 1})
+	s.srv.srv.SetFaults(faults)
+	defer s.srv.srv.SetFaults(nil)
+
+	_, err := s.ec2.DescribeInstances(nil, nil)
+	c.Assert(err, check.NotNil)
+	ec2err, ok := err.(*ec2.Error)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(ec2err.StatusCode, check.Equals, 503)
+	c.Assert(ec2err.Code, check.Equals, "RequestLimitExceeded")
+
+	_, err = s.ec2.DescribeInstances(nil, nil)
+	c.Assert(err, check.IsNil)
+}
+
 // TestUserData is not defined on ServerTests because it
 // requires the ec2test server to function.
 func (s *LocalServerSuite) TestUserData(c *check.C) {
@@ -9,6 +9,7 @@ import (
 	"encoding/xml"
 	"fmt"
 	"github.com/AdRoll/goamz/ec2"
+	"github.com/AdRoll/goamz/testutil"
 	"io"
 	"net"
 	"net/http"
@@ -55,6 +56,7 @@ type Server struct {
 	reservationId        counter
 	groupId              counter
 	initialInstanceState ec2.InstanceState
+	faults               *testutil.Faults
 }
 
 // reservation holds a simulated ec2 reservation.
@@ -262,7 +264,10 @@ func NewServer() (*Server, error) {
 	// we use HandlerFunc rather than *Server directly so that we
 	// can avoid exporting HandlerFunc from *Server.
 	go http.Serve(l, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-		srv.serveHTTP(w, req)
+		srv.mu.Lock()
+		faults := srv.faults
+		srv.mu.Unlock()
+		faults.Serve(w, req, http.HandlerFunc(srv.serveHTTP))
 	}))
 	return srv, nil
 }
@@ -279,6 +284,14 @@ func (srv *Server) SetInitialInstanceState(state ec2.InstanceState) {
 	srv.mu.Unlock()
 }
 
+// SetFaults attaches f to the server so that its fault rules are applied
+// to subsequent requests. A nil f disables fault injection.
+func (srv *Server) SetFaults(f *testutil.Faults) {
+	srv.mu.Lock()
+	srv.faults = f
+	srv.mu.Unlock()
+}
+
 // URL returns the URL of the server.
 func (srv *Server) URL() string {
 	return srv.url
@@ -1,15 +1,13 @@
 package elb_test
 
 import (
-	"flag"
 	"github.com/AdRoll/goamz/aws"
 	"github.com/AdRoll/goamz/ec2"
 	"github.com/AdRoll/goamz/elb"
+	"github.com/AdRoll/goamz/testutil"
 	"gopkg.in/check.v1"
 )
 
-var amazon = flag.Bool("amazon", false, "Enable tests against amazon server")
-
 // AmazonServer represents an Amazon AWS server.
 type AmazonServer struct {
 	auth aws.Auth
@@ -40,7 +38,7 @@ type ClientTests struct {
 }
 
 func (s *AmazonClientSuite) SetUpSuite(c *check.C) {
-	if !*amazon {
+	if !testutil.Amazon {
 		c.Skip("AmazonClientSuite tests not enabled")
 	}
 	s.srv.SetUp(c)
@@ -4,6 +4,7 @@ import (
 	"github.com/AdRoll/goamz/aws"
 	"github.com/AdRoll/goamz/elb"
 	"github.com/AdRoll/goamz/elb/elbtest"
+	"github.com/AdRoll/goamz/testutil"
 	"gopkg.in/check.v1"
 )
 
@@ -51,7 +52,7 @@ type AmazonServerSuite struct {
 var _ = check.Suite(&AmazonServerSuite{})
 
 func (s *AmazonServerSuite) SetUpSuite(c *check.C) {
-	if !*amazon {
+	if !testutil.Amazon {
 		c.Skip("AmazonServerSuite tests not enabled")
 	}
 	s.srv.SetUp(c)
@@ -7,6 +7,7 @@ import (
 	"encoding/xml"
 	"fmt"
 	"github.com/AdRoll/goamz/elb"
+	"github.com/AdRoll/goamz/testutil"
 	"net"
 	"net/http"
 	"net/url"
@@ -27,6 +28,7 @@ type Server struct {
 	instances      []string
 	instanceStates map[string][]*elb.InstanceState
 	instCount      int
+	faults         *testutil.Faults
 }
 
 // Starts and returns a new server
@@ -42,7 +44,10 @@ func NewServer() (*Server, error) {
 		instanceStates: make(map[string][]*elb.InstanceState),
 	}
 	go http.Serve(l, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-		srv.serveHTTP(w, req)
+		srv.mutex.Lock()
+		faults := srv.faults
+		srv.mutex.Unlock()
+		faults.Serve(w, req, http.HandlerFunc(srv.serveHTTP))
 	}))
 	return srv, nil
 }
@@ -52,6 +57,14 @@ func (srv *Server) Quit() {
 	srv.listener.Close()
 }
 
+// SetFaults attaches f to the server so that its fault rules are applied
+// to subsequent requests. A nil f disables fault injection.
+func (srv *Server) SetFaults(f *testutil.Faults) {
+	srv.mutex.Lock()
+	srv.faults = f
+	srv.mutex.Unlock()
+}
+
 // URL returns the URL of the server.
 func (srv *Server) URL() string {
 	return srv.url
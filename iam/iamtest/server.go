@@ -8,6 +8,7 @@ import (
 	"encoding/xml"
 	"fmt"
 	"github.com/AdRoll/goamz/iam"
+	"github.com/AdRoll/goamz/testutil"
 	"net"
 	"net/http"
 	"strings"
@@ -31,6 +32,7 @@ type Server struct {
 	accessKeys   []iam.AccessKey
 	userPolicies []iam.UserPolicy
 	mutex        sync.Mutex
+	faults       *testutil.Faults
 }
 
 func NewServer() (*Server, error) {
@@ -43,7 +45,10 @@ func NewServer() (*Server, error) {
 		url:      "http://" + l.Addr().String(),
 	}
 	go http.Serve(l, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-		srv.serveHTTP(w, req)
+		srv.mutex.Lock()
+		faults := srv.faults
+		srv.mutex.Unlock()
+		faults.Serve(w, req, http.HandlerFunc(srv.serveHTTP))
 	}))
 	return srv, nil
 }
@@ -53,6 +58,14 @@ func (srv *Server) Quit() error {
 	return srv.listener.Close()
 }
 
+// SetFaults attaches f to the server so that its fault rules are applied
+// to subsequent requests. A nil f disables fault injection.
+func (srv *Server) SetFaults(f *testutil.Faults) {
+	srv.mutex.Lock()
+	srv.faults = f
+	srv.mutex.Unlock()
+}
+
 // URL returns a URL for the server.
 func (srv *Server) URL() string {
 	return srv.url
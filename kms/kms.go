@@ -127,6 +127,19 @@ func (k *KMS) Decrypt(info DecryptInfo) (DecryptResp, error) {
 	return resp, err
 }
 
+func (k *KMS) GenerateDataKey(info GenerateDataKeyInfo) (GenerateDataKeyResp, error) {
+	resp := GenerateDataKeyResp{}
+	bResp, err := k.query(&info)
+
+	if err != nil {
+		return resp, err
+	}
+
+	err = json.Unmarshal(bResp, &resp)
+
+	return resp, err
+}
+
 func (k *KMS) EnableKey(info EnableKeyInfo) error {
 	_, err := k.query(&info)
 
@@ -1,6 +1,7 @@
 package kms_test
 
 import (
+	"encoding/json"
 	"github.com/AdRoll/goamz/aws"
 	"github.com/AdRoll/goamz/kms"
 	"github.com/AdRoll/goamz/testutil"
@@ -49,3 +50,26 @@ func (s *S) TestDescribeKey(c *check.C) {
 	c.Assert(desc.KeyMetadata.KeyId, check.Equals, "12345678-1234-1234-1234-123456789012")
 	c.Assert(desc.KeyMetadata.KeyUsage, check.Equals, "ENCRYPT_DECRYPT")
 }
+
+func (s *S) TestGenerateDataKey(c *check.C) {
+	testServer.Response(200, nil, GenerateDataKeyExample)
+
+	info := kms.GenerateDataKeyInfo{KeyId: "alias/test", KeySpec: "AES_256"}
+	info.EncryptionContext = map[string]string{"purpose": "test"}
+	resp, err := s.kms.GenerateDataKey(info)
+	req := testServer.WaitRequest()
+
+	c.Assert(req.Header.Get("X-Amz-Target"), check.Equals, "TrentService.GenerateDataKey")
+	var body map[string]interface{}
+	c.Assert(json.NewDecoder(req.Body).Decode(&body), check.IsNil)
+	c.Assert(body["KeyId"], check.Equals, "alias/test")
+	c.Assert(body["KeySpec"], check.Equals, "AES_256")
+	c.Assert(body["EncryptionContext"], check.DeepEquals, map[string]interface{}{"purpose": "test"})
+	_, ok := body["NumberOfBytes"]
+	c.Assert(ok, check.Equals, false)
+
+	c.Assert(err, check.IsNil)
+	c.Assert(resp.CiphertextBlob, check.DeepEquals, []byte{1, 2, 3, 0, 120, 65, 81})
+	c.Assert(resp.KeyId, check.Equals, "arn:aws:kms:us-east-1:123456789012:key/12345678-1234-1234-1234-123456789012")
+	c.Assert(resp.Plaintext, check.HasLen, 32)
+}
@@ -58,6 +58,23 @@ func (d *DecryptInfo) ActionName() string {
 	return "Decrypt"
 }
 
+type GenerateDataKeyInfo struct {
+	//4 forms for KeyId - http://docs.aws.amazon.com/kms/latest/APIReference/API_GenerateDataKey.html
+	//1. Key ARN
+	//2. Alias ARN
+	//3. Globally Unique Key
+	//4. Alias Name
+	KeyId string
+	ProduceKeyOpt
+	//Either KeySpec (AES_128 or AES_256) or NumberOfBytes must be set
+	KeySpec       string `json:",omitempty"`
+	NumberOfBytes int    `json:",omitempty"`
+}
+
+func (g *GenerateDataKeyInfo) ActionName() string {
+	return "GenerateDataKey"
+}
+
 type EnableKeyInfo struct {
 	//2 forms for KeyId - http://docs.aws.amazon.com/kms/latest/APIReference/API_EnableKey.html
 	//1. Key ARN
@@ -36,6 +36,12 @@ type DecryptResp struct {
 	Plaintext []byte
 }
 
+type GenerateDataKeyResp struct {
+	CiphertextBlob []byte
+	KeyId          string
+	Plaintext      []byte
+}
+
 //For some actions, we just only check if it is success by status code. (200)
 //1. EnableKey
 //2. DisableKey
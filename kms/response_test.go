@@ -13,3 +13,11 @@ var DescribeKeyExample = `
 	}
 }
 `
+
+var GenerateDataKeyExample = `
+{
+	"CiphertextBlob": "AQIDAHhBUQ==",
+	"KeyId": "arn:aws:kms:us-east-1:123456789012:key/12345678-1234-1234-1234-123456789012",
+	"Plaintext": "ABEiM0RVZneImaq7zN3u/wARIjNEVWZ3iJmqu8zd7v8="
+}
+`
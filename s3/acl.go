@@ -0,0 +1,194 @@
+package s3
+
+import (
+	"encoding/xml"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Permission is the access granted to a grantee.
+type Permission string
+
+const (
+	PermFullControl = Permission("FULL_CONTROL")
+	PermRead        = Permission("READ")
+	PermWrite       = Permission("WRITE")
+	PermReadACP     = Permission("READ_ACP")
+	PermWriteACP    = Permission("WRITE_ACP")
+)
+
+// Grantee types.
+const (
+	CanonicalUser         = "CanonicalUser"
+	AmazonCustomerByEmail = "AmazonCustomerByEmail"
+	Group                 = "Group"
+)
+
+// URIs of the predefined groups.
+const (
+	AllUsersGroup           = "http://acs.amazonaws.com/groups/global/AllUsers"
+	AuthenticatedUsersGroup = "http://acs.amazonaws.com/groups/global/AuthenticatedUsers"
+	LogDeliveryGroup        = "http://acs.amazonaws.com/groups/s3/LogDelivery"
+)
+
+const xsiNamespace = "http://www.w3.org/2001/XMLSchema-instance"
+
+// AccessControlPolicy holds the owner and the access control list of a
+// bucket or an object.
+type AccessControlPolicy struct {
+	XMLName xml.Name `xml:"AccessControlPolicy"`
+	Owner   Owner
+	Grants  []Grant `xml:"AccessControlList>Grant"`
+}
+
+// Grant gives Permission to Grantee.
+type Grant struct {
+	Grantee    Grantee
+	Permission Permission
+}
+
+// Grantee is a user or group an ACL grants access to. Type tells which
+// of ID, EmailAddress or URI identifies it.
+type Grantee struct {
+	Type         string `xml:"http://www.w3.org/2001/XMLSchema-instance type,attr"`
+	ID           string `xml:"ID,omitempty"`
+	DisplayName  string `xml:"DisplayName,omitempty"`
+	EmailAddress string `xml:"EmailAddress,omitempty"`
+	URI          string `xml:"URI,omitempty"`
+}
+
+// CanonicalUserGrantee returns the grantee for the account with the
+// given canonical user ID.
+func CanonicalUserGrantee(id string) Grantee {
+	return Grantee{Type: CanonicalUser, ID: id}
+}
+
+// EmailGrantee returns the grantee for the account registered with the
+// given email address.
+func EmailGrantee(email string) Grantee {
+	return Grantee{Type: AmazonCustomerByEmail, EmailAddress: email}
+}
+
+// GroupGrantee returns the grantee for one of the predefined groups,
+// such as AllUsersGroup.
+func GroupGrantee(uri string) Grantee {
+	return Grantee{Type: Group, URI: uri}
+}
+
+// MarshalXML writes the grantee type as an xsi:type attribute, the form
+// S3 expects.
+func (g Grantee) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Attr = []xml.Attr{
+		{Name: xml.Name{Local: "xmlns:xsi"}, Value: xsiNamespace},
+		{Name: xml.Name{Local: "xsi:type"}, Value: g.Type},
+	}
+	type grantee struct {
+		ID           string `xml:"ID,omitempty"`
+		DisplayName  string `xml:"DisplayName,omitempty"`
+		EmailAddress string `xml:"EmailAddress,omitempty"`
+		URI          string `xml:"URI,omitempty"`
+	}
+	return e.EncodeElement(grantee{g.ID, g.DisplayName, g.EmailAddress, g.URI}, start)
+}
+
+// grantHeaders maps each permission to the header granting it.
+var grantHeaders = map[Permission]string{
+	PermFullControl: "x-amz-grant-full-control",
+	PermRead:        "x-amz-grant-read",
+	PermWrite:       "x-amz-grant-write",
+	PermReadACP:     "x-amz-grant-read-acp",
+	PermWriteACP:    "x-amz-grant-write-acp",
+}
+
+// addGrantHeaders adds the x-amz-grant-* headers for grants to headers.
+func addGrantHeaders(headers map[string][]string, grants []Grant) {
+	values := make(map[string][]string)
+	for _, g := range grants {
+		var v string
+		switch {
+		case g.Grantee.ID != "":
+			v = "id=" + strconv.Quote(g.Grantee.ID)
+		case g.Grantee.EmailAddress != "":
+			v = "emailAddress=" + strconv.Quote(g.Grantee.EmailAddress)
+		default:
+			v = "uri=" + strconv.Quote(g.Grantee.URI)
+		}
+		h := grantHeaders[g.Permission]
+		values[h] = append(values[h], v)
+	}
+	for h, v := range values {
+		headers[h] = []string{strings.Join(v, ", ")}
+	}
+}
+
+func (b *Bucket) getACL(path string) (*AccessControlPolicy, error) {
+	req := &request{
+		bucket: b.Name,
+		path:   path,
+		params: url.Values{"acl": {""}},
+	}
+	policy := &AccessControlPolicy{}
+	var err error
+	for attempt := attempts.Start(); attempt.Next(); {
+		err = b.S3.query(req, policy)
+		if !shouldRetry(err) {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+func (b *Bucket) putACL(path string, policy AccessControlPolicy) error {
+	doc, err := xml.Marshal(policy)
+	if err != nil {
+		return err
+	}
+	buf := makeXmlBuffer(doc)
+	req := &request{
+		method:  "PUT",
+		bucket:  b.Name,
+		path:    path,
+		headers: map[string][]string{"Content-Length": {strconv.Itoa(buf.Len())}},
+		params:  url.Values{"acl": {""}},
+		payload: buf,
+	}
+	return b.S3.query(req, nil)
+}
+
+// GetBucketACL returns the access control policy of the bucket.
+//
+// See https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketAcl.html
+// for details.
+func (b *Bucket) GetBucketACL() (*AccessControlPolicy, error) {
+	return b.getACL("/")
+}
+
+// PutBucketACL replaces the access control policy of the bucket. The
+// policy must name the bucket owner.
+//
+// See https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketAcl.html
+// for details.
+func (b *Bucket) PutBucketACL(policy AccessControlPolicy) error {
+	return b.putACL("/", policy)
+}
+
+// GetObjectACL returns the access control policy of the object at path.
+//
+// See https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetObjectAcl.html
+// for details.
+func (b *Bucket) GetObjectACL(path string) (*AccessControlPolicy, error) {
+	return b.getACL(path)
+}
+
+// PutObjectACL replaces the access control policy of the object at path.
+// The policy must name the object owner.
+//
+// See https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutObjectAcl.html
+// for details.
+func (b *Bucket) PutObjectACL(path string, policy AccessControlPolicy) error {
+	return b.putACL(path, policy)
+}
@@ -0,0 +1,88 @@
+package s3_test
+
+import (
+	"github.com/AdRoll/goamz/s3"
+	"gopkg.in/check.v1"
+)
+
+var GetACLDump = `<?xml version="1.0" encoding="UTF-8"?>
+<AccessControlPolicy xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <Owner>
+    <ID>75aa57f09aa0c8caeab4f8c24e99d10f8e7faeebf76c078efc7c6caea54ba06a</ID>
+    <DisplayName>CustomersName@amazon.com</DisplayName>
+  </Owner>
+  <AccessControlList>
+    <Grant>
+      <Grantee xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:type="CanonicalUser">
+        <ID>75aa57f09aa0c8caeab4f8c24e99d10f8e7faeebf76c078efc7c6caea54ba06a</ID>
+        <DisplayName>CustomersName@amazon.com</DisplayName>
+      </Grantee>
+      <Permission>FULL_CONTROL</Permission>
+    </Grant>
+    <Grant>
+      <Grantee xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:type="Group">
+        <URI>http://acs.amazonaws.com/groups/global/AllUsers</URI>
+      </Grantee>
+      <Permission>READ</Permission>
+    </Grant>
+  </AccessControlList>
+</AccessControlPolicy>`
+
+func (s *S) TestGetObjectACL(c *check.C) {
+	testServer.Response(200, nil, GetACLDump)
+
+	b := s.s3.Bucket("bucket")
+	policy, err := b.GetObjectACL("name")
+	c.Assert(err, check.IsNil)
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Method, check.Equals, "GET")
+	c.Assert(req.URL.Path, check.Equals, "/bucket/name")
+	c.Assert(req.URL.RawQuery, check.Equals, "acl=")
+
+	c.Assert(policy.Owner.DisplayName, check.Equals, "CustomersName@amazon.com")
+	c.Assert(policy.Grants, check.HasLen, 2)
+	c.Assert(policy.Grants[0].Grantee.Type, check.Equals, s3.CanonicalUser)
+	c.Assert(policy.Grants[0].Grantee.ID, check.Equals, "75aa57f09aa0c8caeab4f8c24e99d10f8e7faeebf76c078efc7c6caea54ba06a")
+	c.Assert(policy.Grants[0].Permission, check.Equals, s3.PermFullControl)
+	c.Assert(policy.Grants[1].Grantee, check.Equals, s3.GroupGrantee(s3.AllUsersGroup))
+	c.Assert(policy.Grants[1].Permission, check.Equals, s3.PermRead)
+}
+
+func (s *S) TestPutBucketACL(c *check.C) {
+	testServer.Response(200, nil, "")
+
+	b := s.s3.Bucket("bucket")
+	err := b.PutBucketACL(s3.AccessControlPolicy{
+		Owner:  s3.Owner{ID: "1234"},
+		Grants: []s3.Grant{{Grantee: s3.EmailGrantee("xyz@amazon.com"), Permission: s3.PermWriteACP}},
+	})
+	c.Assert(err, check.IsNil)
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Method, check.Equals, "PUT")
+	c.Assert(req.URL.Path, check.Equals, "/bucket/")
+	c.Assert(req.URL.RawQuery, check.Equals, "acl=")
+	c.Assert(readAll(req.Body), check.Equals, `<?xml version="1.0" encoding="UTF-8"?>
+<AccessControlPolicy><Owner><ID>1234</ID><DisplayName></DisplayName></Owner><AccessControlList>`+
+		`<Grant><Grantee xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:type="AmazonCustomerByEmail"><EmailAddress>xyz@amazon.com</EmailAddress></Grantee>`+
+		`<Permission>WRITE_ACP</Permission></Grant></AccessControlList></AccessControlPolicy>`)
+}
+
+func (s *S) TestPutWithGrants(c *check.C) {
+	testServer.Response(200, nil, "")
+
+	b := s.s3.Bucket("bucket")
+	options := s3.Options{Grants: []s3.Grant{
+		{Grantee: s3.CanonicalUserGrantee("1234"), Permission: s3.PermFullControl},
+		{Grantee: s3.GroupGrantee(s3.AllUsersGroup), Permission: s3.PermRead},
+		{Grantee: s3.EmailGrantee("xyz@amazon.com"), Permission: s3.PermRead},
+	}}
+	err := b.Put("name", []byte("content"), "text/plain", s3.Private, options)
+	c.Assert(err, check.IsNil)
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Header["X-Amz-Acl"], check.IsNil)
+	c.Assert(req.Header["X-Amz-Grant-Full-Control"], check.DeepEquals, []string{`id="1234"`})
+	c.Assert(req.Header["X-Amz-Grant-Read"], check.DeepEquals, []string{`uri="http://acs.amazonaws.com/groups/global/AllUsers", emailAddress="xyz@amazon.com"`})
+}
@@ -0,0 +1,182 @@
+package s3
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/xml"
+	"net/url"
+	"strconv"
+)
+
+// CORSConfiguration holds the cross-origin resource sharing rules of a
+// bucket.
+type CORSConfiguration struct {
+	XMLName   xml.Name   `xml:"CORSConfiguration"`
+	CORSRules []CORSRule `xml:"CORSRule"`
+}
+
+// CORSRule allows requests from AllowedOrigins using AllowedMethods.
+type CORSRule struct {
+	ID             string   `xml:"ID,omitempty"`
+	AllowedOrigins []string `xml:"AllowedOrigin"`
+	AllowedMethods []string `xml:"AllowedMethod"`
+	AllowedHeaders []string `xml:"AllowedHeader,omitempty"`
+	ExposeHeaders  []string `xml:"ExposeHeader,omitempty"`
+	MaxAgeSeconds  int      `xml:"MaxAgeSeconds,omitempty"`
+}
+
+// Tag is a key/value pair attached to a bucket or an object.
+type Tag struct {
+	Key   string
+	Value string
+}
+
+// Tagging holds the set of tags of a bucket or an object.
+type Tagging struct {
+	XMLName xml.Name `xml:"Tagging"`
+	TagSet  []Tag    `xml:"TagSet>Tag"`
+}
+
+// BucketLoggingStatus holds the server access logging configuration of a
+// bucket. Logging is disabled when LoggingEnabled is nil.
+type BucketLoggingStatus struct {
+	XMLName        xml.Name        `xml:"BucketLoggingStatus"`
+	LoggingEnabled *LoggingEnabled `xml:"LoggingEnabled,omitempty"`
+}
+
+// LoggingEnabled names the bucket and key prefix that access logs are
+// written to.
+type LoggingEnabled struct {
+	TargetBucket string
+	TargetPrefix string
+}
+
+// putSubresourceXML marshals v and stores it in a subresource of the
+// bucket, if path is "/", or of the object at path. Content-MD5 is always
+// sent since some subresources require it.
+func (b *Bucket) putSubresourceXML(path, subresource string, v interface{}) error {
+	doc, err := xml.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return b.putSubresource(path, subresource, makeXmlBuffer(doc).Bytes())
+}
+
+func (b *Bucket) putSubresource(path, subresource string, doc []byte) error {
+	digest := md5.Sum(doc)
+	headers := map[string][]string{
+		"Content-Length": {strconv.Itoa(len(doc))},
+		"Content-MD5":    {base64.StdEncoding.EncodeToString(digest[:])},
+	}
+	req := &request{
+		path:    path,
+		method:  "PUT",
+		bucket:  b.Name,
+		headers: headers,
+		payload: bytes.NewReader(doc),
+		params:  url.Values{subresource: {""}},
+	}
+	return b.S3.query(req, nil)
+}
+
+func (b *Bucket) getSubresourceXML(path, subresource string, v interface{}) error {
+	req := &request{
+		bucket: b.Name,
+		path:   path,
+		params: url.Values{subresource: {""}},
+	}
+	var err error
+	for attempt := attempts.Start(); attempt.Next(); {
+		err = b.S3.query(req, v)
+		if !shouldRetry(err) {
+			break
+		}
+	}
+	return err
+}
+
+// GetBucketCORS returns the CORS configuration of the bucket. S3 returns
+// a NoSuchCORSConfiguration error if there is none.
+func (b *Bucket) GetBucketCORS() (*CORSConfiguration, error) {
+	conf := &CORSConfiguration{}
+	if err := b.getSubresourceXML("/", "cors", conf); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+// PutBucketCORS replaces the CORS configuration of the bucket.
+//
+// See https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketCors.html
+// for details.
+func (b *Bucket) PutBucketCORS(conf CORSConfiguration) error {
+	return b.putSubresourceXML("/", "cors", conf)
+}
+
+// DeleteBucketCORS removes the CORS configuration of the bucket.
+func (b *Bucket) DeleteBucketCORS() error {
+	return b.DelBucketSubresource("cors")
+}
+
+// GetBucketPolicy returns the JSON policy document of the bucket. S3
+// returns a NoSuchBucketPolicy error if there is none.
+func (b *Bucket) GetBucketPolicy() ([]byte, error) {
+	return b.GetBucketSubresource("policy")
+}
+
+// PutBucketPolicy replaces the policy of the bucket with the given JSON
+// policy document.
+//
+// See https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketPolicy.html
+// for details.
+func (b *Bucket) PutBucketPolicy(policy []byte) error {
+	return b.putSubresource("/", "policy", policy)
+}
+
+// DeleteBucketPolicy removes the policy of the bucket.
+func (b *Bucket) DeleteBucketPolicy() error {
+	return b.DelBucketSubresource("policy")
+}
+
+// GetBucketTagging returns the tags of the bucket. S3 returns a
+// NoSuchTagSet error if there are none.
+func (b *Bucket) GetBucketTagging() (*Tagging, error) {
+	tagging := &Tagging{}
+	if err := b.getSubresourceXML("/", "tagging", tagging); err != nil {
+		return nil, err
+	}
+	return tagging, nil
+}
+
+// PutBucketTagging replaces the tags of the bucket.
+//
+// See https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketTagging.html
+// for details.
+func (b *Bucket) PutBucketTagging(tagging Tagging) error {
+	return b.putSubresourceXML("/", "tagging", tagging)
+}
+
+// DeleteBucketTagging removes all the tags of the bucket.
+func (b *Bucket) DeleteBucketTagging() error {
+	return b.DelBucketSubresource("tagging")
+}
+
+// GetBucketLogging returns the server access logging configuration of
+// the bucket.
+func (b *Bucket) GetBucketLogging() (*BucketLoggingStatus, error) {
+	status := &BucketLoggingStatus{}
+	if err := b.getSubresourceXML("/", "logging", status); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// PutBucketLogging replaces the server access logging configuration of
+// the bucket. A status with no LoggingEnabled turns logging off.
+//
+// See https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketLogging.html
+// for details.
+func (b *Bucket) PutBucketLogging(status BucketLoggingStatus) error {
+	return b.putSubresourceXML("/", "logging", status)
+}
@@ -0,0 +1,71 @@
+package s3_test
+
+import (
+	"github.com/AdRoll/goamz/s3"
+	"github.com/AdRoll/goamz/testutil"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestPutBucketCORS(c *check.C) {
+	testServer.Response(200, nil, "")
+
+	b := s.s3.Bucket("bucket")
+	err := b.PutBucketCORS(s3.CORSConfiguration{CORSRules: []s3.CORSRule{{
+		AllowedOrigins: []string{"*"},
+		AllowedMethods: []string{"GET"},
+		AllowedHeaders: []string{"Authorization"},
+	}}})
+	c.Assert(err, check.IsNil)
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Method, check.Equals, "PUT")
+	c.Assert(req.URL.RawQuery, check.Equals, "cors=")
+	c.Assert(req.Header.Get("Content-MD5"), check.Not(check.Equals), "")
+	c.Assert(readAll(req.Body), check.Equals, `<?xml version="1.0" encoding="UTF-8"?>
+<CORSConfiguration><CORSRule><AllowedOrigin>*</AllowedOrigin><AllowedMethod>GET</AllowedMethod><AllowedHeader>Authorization</AllowedHeader></CORSRule></CORSConfiguration>`)
+}
+
+func (s *S) TestGetBucketTagging(c *check.C) {
+	testServer.Response(200, nil, `<Tagging><TagSet><Tag><Key>a</Key><Value>1</Value></Tag><Tag><Key>b</Key><Value>2</Value></Tag></TagSet></Tagging>`)
+
+	b := s.s3.Bucket("bucket")
+	tagging, err := b.GetBucketTagging()
+	c.Assert(err, check.IsNil)
+	c.Assert(tagging.TagSet, check.DeepEquals, []s3.Tag{{"a", "1"}, {"b", "2"}})
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Method, check.Equals, "GET")
+	c.Assert(req.URL.Path, check.Equals, "/bucket/")
+	c.Assert(req.URL.RawQuery, check.Equals, "tagging=")
+}
+
+func (s *S) TestGetBucketPolicyRetriesTruncatedBody(c *check.C) {
+	faults := &testutil.Faults{}
+	testServer.SetFaults(faults)
+	defer testServer.SetFaults(nil)
+	faults.Add(testutil.FaultRule{Fault: testutil.Fault{Truncate: true}, Times: 1})
+	policy := `{"Version":"2012-10-17","Statement":[]}`
+	testServer.Response(200, nil, policy)
+	testServer.Response(200, nil, policy)
+
+	b := s.s3.Bucket("bucket")
+	data, err := b.GetBucketPolicy()
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, policy)
+	c.Assert(faults.Fired(), check.Equals, 1)
+
+	for _, req := range testServer.WaitRequests(2) {
+		c.Assert(req.URL.RawQuery, check.Equals, "policy=")
+	}
+}
+
+func (s *S) TestDeleteBucketPolicy(c *check.C) {
+	testServer.Response(204, nil, "")
+
+	b := s.s3.Bucket("bucket")
+	c.Assert(b.DeleteBucketPolicy(), check.IsNil)
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Method, check.Equals, "DELETE")
+	c.Assert(req.URL.RawQuery, check.Equals, "policy=")
+}
@@ -0,0 +1,227 @@
+package s3
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/AdRoll/goamz/s3/internal/pool"
+)
+
+// MaxCopySize is the size of the largest object PutCopy can copy in a
+// single request. Larger objects must be copied in parts.
+const MaxCopySize = 5 * 1024 * 1024 * 1024
+
+// Values of CopyOptions.MetadataDirective.
+const (
+	MetadataDirectiveCopy    = "COPY"
+	MetadataDirectiveReplace = "REPLACE"
+)
+
+// CopyObjectOptions controls a Copy.
+type CopyObjectOptions struct {
+	// CopyOptions describe the new object as for PutCopy. Its metadata
+	// and tags are those of the source object unless MetadataDirective
+	// or TaggingDirective is REPLACE.
+	CopyOptions
+
+	// MultipartThreshold is the size above which the object is copied
+	// in parts. It defaults to, and cannot exceed, MaxCopySize.
+	MultipartThreshold int64
+
+	// PartSize is the size of every part but the last one. It defaults
+	// to MinPartSize, or to the smallest size that fits the object in
+	// MaxParts parts.
+	PartSize int64
+
+	// Concurrency is the number of parts copied in parallel. It
+	// defaults to 1.
+	Concurrency int
+}
+
+// copySourceHeader returns the x-amz-copy-source header naming source,
+// "bucket/key", or its version versionId if set.
+func copySourceHeader(source, versionId string) string {
+	source = url.QueryEscape(source)
+	if versionId != "" {
+		source += "?versionId=" + url.QueryEscape(versionId)
+	}
+	return source
+}
+
+// headCopySource sends a HEAD request for the object at path in the bucket
+// source, asking for the version and with the SSE-C key of the source
+// given by options.
+func headCopySource(source *Bucket, path string, options CopyOptions) (*http.Response, error) {
+	params := url.Values{}
+	if options.SourceVersionId != "" {
+		params.Set("versionId", options.SourceVersionId)
+	}
+	headers := map[string][]string{}
+	if options.CopySourceSSECustomerAlgorithm != "" {
+		headers["x-amz-server-side-encryption-customer-algorithm"] = []string{options.CopySourceSSECustomerAlgorithm}
+		headers["x-amz-server-side-encryption-customer-key"] = []string{options.CopySourceSSECustomerKey}
+		headers["x-amz-server-side-encryption-customer-key-MD5"] = []string{options.CopySourceSSECustomerKeyMD5}
+	}
+	resp, err := source.head(path, params, headers)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return resp, nil
+}
+
+// copyRangeSize returns the number of bytes of the copy source range r,
+// "bytes=first-last", and false if r is not such a range.
+func copyRangeSize(r string) (int64, bool) {
+	if !strings.HasPrefix(r, "bytes=") {
+		return 0, false
+	}
+	bounds := strings.SplitN(strings.TrimPrefix(r, "bytes="), "-", 2)
+	if len(bounds) != 2 {
+		return 0, false
+	}
+	first, err := strconv.ParseInt(bounds[0], 10, 64)
+	if err != nil || first < 0 {
+		return 0, false
+	}
+	last, err := strconv.ParseInt(bounds[1], 10, 64)
+	if err != nil || last < first {
+		return 0, false
+	}
+	return last - first + 1, true
+}
+
+// Copy copies the object at sourcePath in the bucket source, which may
+// be in another region, to path in bucket b. The source object is first
+// inspected with a HEAD request: objects up to options.MultipartThreshold
+// bytes are copied with a single PutCopy request, and larger ones with a
+// multipart upload whose parts are copied from byte ranges of the source,
+// up to options.Concurrency at a time.
+//
+// A multipart upload cannot copy the metadata of the source object, so
+// Copy sets the metadata and tags of the source on the new object itself,
+// unless options replaces them. If a part fails, the remaining parts are
+// cancelled, the multipart upload is aborted and the error is returned.
+//
+// See https://docs.aws.amazon.com/AmazonS3/latest/userguide/CopyingObjectsMPUapi.html
+// for details.
+func (b *Bucket) Copy(path string, perm ACL, source *Bucket, sourcePath string, options CopyObjectOptions) (*CopyObjectResult, error) {
+	resp, err := headCopySource(source, sourcePath, options.CopyOptions)
+	if err != nil {
+		return nil, err
+	}
+	size := resp.ContentLength
+	if size < 0 {
+		return nil, fmt.Errorf("s3: object %s has unknown size", sourcePath)
+	}
+
+	threshold := options.MultipartThreshold
+	if threshold <= 0 || threshold > MaxCopySize {
+		threshold = MaxCopySize
+	}
+	sourceName := source.Name + "/" + sourcePath
+	if size <= threshold {
+		return b.PutCopy(path, perm, options.CopyOptions, sourceName)
+	}
+
+	partSize := options.PartSize
+	if partSize <= 0 {
+		partSize = minPartSizeFor(size)
+	}
+	nparts := int((size + partSize - 1) / partSize)
+	if nparts > MaxParts {
+		return nil, fmt.Errorf("s3: %d bytes in parts of %d bytes need %d parts; the maximum is %d", size, partSize, nparts, MaxParts)
+	}
+
+	initOptions := options.Options
+	contType := options.ContentType
+	if options.MetadataDirective != MetadataDirectiveReplace {
+		contType = resp.Header.Get("Content-Type")
+		copyMetadata(&initOptions, resp.Header)
+	}
+	if options.TaggingDirective != TaggingDirectiveReplace {
+		initOptions.Tags = nil
+		if count := resp.Header.Get("x-amz-tagging-count"); count != "" && count != "0" {
+			tagging, err := source.GetObjectTagging(sourcePath)
+			if err != nil {
+				return nil, err
+			}
+			initOptions.Tags = tagging.TagSet
+		}
+	}
+	m, err := b.InitMulti(path, contType, perm, initOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parts only need the encryption keys of the source and new objects.
+	partOptions := CopyOptions{
+		Options: Options{
+			SSECustomerAlgorithm: options.SSECustomerAlgorithm,
+			SSECustomerKey:       options.SSECustomerKey,
+			SSECustomerKeyMD5:    options.SSECustomerKeyMD5,
+		},
+		SourceVersionId:                options.SourceVersionId,
+		CopySourceSSECustomerAlgorithm: options.CopySourceSSECustomerAlgorithm,
+		CopySourceSSECustomerKey:       options.CopySourceSSECustomerKey,
+		CopySourceSSECustomerKeyMD5:    options.CopySourceSSECustomerKeyMD5,
+	}
+	var (
+		mu    sync.Mutex
+		parts = make([]Part, nparts)
+		g     = pool.New(options.Concurrency)
+	)
+	for n := 1; n <= nparts; n++ {
+		n := n
+		ok := g.Go(func() error {
+			offset := int64(n-1) * partSize
+			length := partSize
+			if offset+length > size {
+				length = size - offset
+			}
+			options := partOptions
+			options.CopySourceOptions = "bytes=" + strconv.FormatInt(offset, 10) + "-" + strconv.FormatInt(offset+length-1, 10)
+			_, part, err := m.putPartCopy(n, options, sourceName, length)
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			parts[n-1] = part
+			mu.Unlock()
+			return nil
+		})
+		if !ok {
+			break
+		}
+	}
+	if err := g.Wait(); err != nil {
+		m.Abort()
+		return nil, err
+	}
+	result, err := m.complete(parts)
+	if err != nil {
+		m.Abort()
+		return nil, err
+	}
+	result.SourceVersionId = resp.Header.Get("x-amz-version-id")
+	return result, nil
+}
+
+// copyMetadata sets the metadata of options to that of an object
+// described by the headers of a HEAD response.
+func copyMetadata(options *Options, header http.Header) {
+	options.ContentEncoding = header.Get("Content-Encoding")
+	options.CacheControl = header.Get("Cache-Control")
+	options.ContentDisposition = header.Get("Content-Disposition")
+	options.RedirectLocation = header.Get("x-amz-website-redirect-location")
+	options.Meta = make(map[string][]string)
+	for key, values := range header {
+		if strings.HasPrefix(key, "X-Amz-Meta-") {
+			options.Meta[strings.ToLower(key[len("X-Amz-Meta-"):])] = values
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package s3
+
+import (
+	"sync"
+
+	"github.com/AdRoll/goamz/s3/internal/pool"
+)
+
+// MaxDeleteObjects is the number of objects DelMulti removes at most in
+// one request.
+const MaxDeleteObjects = 1000
+
+// DeleteOptions controls a DeletePrefix.
+type DeleteOptions struct {
+	// Concurrency is the number of DelMulti requests sent in parallel.
+	// It defaults to 1.
+	Concurrency int
+
+	// BatchSize is the number of objects removed by each DelMulti
+	// request. It defaults to, and cannot exceed, MaxDeleteObjects.
+	BatchSize int
+
+	// Versions removes every version and delete marker of the keys,
+	// rather than only their current version. Unless it is set,
+	// DeletePrefix asks S3 whether versioning was ever enabled on the
+	// bucket and sets it if so, as deleting the current version of a key
+	// in a versioned bucket only adds a delete marker.
+	Versions bool
+}
+
+// DeleteSummary reports the outcome of a DeletePrefix.
+type DeleteSummary struct {
+	// Deleted is the number of objects, or versions, removed.
+	Deleted int
+
+	// Errors describes the objects S3 failed to remove.
+	Errors []DeleteError
+}
+
+// DeletePrefix removes all the objects whose keys begin with prefix,
+// listing them as it goes and removing them in batches of up to
+// options.BatchSize objects with concurrent DelMulti requests. In a
+// bucket whose versioning is enabled or suspended, every version and
+// delete marker of the keys is removed.
+//
+// Objects S3 fails to remove are reported in the summary and do not stop
+// the deletion. If a listing or DelMulti request fails, the remaining
+// batches are cancelled and the error is returned along with the summary
+// of the objects already handled.
+func (b *Bucket) DeletePrefix(prefix string, options DeleteOptions) (*DeleteSummary, error) {
+	batchSize := options.BatchSize
+	if batchSize <= 0 || batchSize > MaxDeleteObjects {
+		batchSize = MaxDeleteObjects
+	}
+	if !options.Versions {
+		conf, err := b.GetBucketVersioning()
+		if err != nil {
+			return &DeleteSummary{}, err
+		}
+		options.Versions = conf.Status != ""
+	}
+
+	var (
+		mu      sync.Mutex
+		summary = &DeleteSummary{}
+		g       = pool.New(options.Concurrency)
+		batch   []Object
+	)
+	send := func() bool {
+		objects := batch
+		batch = nil
+		return g.Go(func() error {
+			result, err := b.DelMultiWithResult(Delete{Quiet: true, Objects: objects})
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			summary.Deleted += len(objects) - len(result.Errors)
+			summary.Errors = append(summary.Errors, result.Errors...)
+			mu.Unlock()
+			return nil
+		})
+	}
+	add := func(o Object) bool {
+		batch = append(batch, o)
+		return len(batch) < batchSize || send()
+	}
+	err := b.listDeletions(prefix, options.Versions, add)
+	if err != nil {
+		g.Fail(err)
+	} else if len(batch) > 0 {
+		send()
+	}
+	return summary, g.Wait()
+}
+
+// listDeletions calls add with the objects whose keys begin with prefix,
+// or all their versions and delete markers, until add returns false.
+func (b *Bucket) listDeletions(prefix string, versions bool, add func(Object) bool) error {
+	if !versions {
+		iter := b.ObjectIter(ListV2Options{Prefix: prefix})
+		for iter.Next() {
+			if !add(Object{Key: iter.Key().Key}) {
+				return nil
+			}
+		}
+		return iter.Err()
+	}
+	iter := b.VersionIter(prefix, "", 0)
+	for iter.Next() {
+		v := iter.Version()
+		if !add(Object{Key: v.Key, VersionId: v.VersionId}) {
+			return nil
+		}
+	}
+	return iter.Err()
+}
+
+// EmptyBucket removes every object of the bucket, with all its versions
+// and delete markers, so that DelBucket can then remove the bucket. It
+// works like DeletePrefix with an empty prefix and options.Versions set,
+// listing the versions without checking that the bucket is versioned.
+func (b *Bucket) EmptyBucket(options DeleteOptions) (*DeleteSummary, error) {
+	options.Versions = true
+	return b.DeletePrefix("", options)
+}
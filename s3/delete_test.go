@@ -0,0 +1,78 @@
+package s3_test
+
+import (
+	"github.com/AdRoll/goamz/s3"
+	"github.com/AdRoll/goamz/testutil"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestDeletePrefixErrors(c *check.C) {
+	testServer.Response(200, nil, `<VersioningConfiguration/>`)
+	testServer.Response(200, nil, `<ListBucketResult>
+  <Contents><Key>logs/a</Key></Contents>
+  <Contents><Key>logs/b</Key></Contents>
+  <IsTruncated>false</IsTruncated>
+</ListBucketResult>`)
+	testServer.Response(200, nil, `<DeleteResult>
+  <Error><Key>logs/b</Key><Code>AccessDenied</Code><Message>Access Denied</Message></Error>
+</DeleteResult>`)
+
+	b := s.s3.Bucket("bucket")
+	summary, err := b.DeletePrefix("logs/", s3.DeleteOptions{})
+	c.Assert(err, check.IsNil)
+	c.Assert(summary.Deleted, check.Equals, 1)
+	c.Assert(summary.Errors, check.DeepEquals, []s3.DeleteError{{Key: "logs/b", Code: "AccessDenied", Message: "Access Denied"}})
+
+	req := testServer.WaitRequest()
+	c.Assert(req.URL.RawQuery, check.Equals, "versioning=")
+	req = testServer.WaitRequest()
+	c.Assert(req.Form.Get("prefix"), check.Equals, "logs/")
+	req = testServer.WaitRequest()
+	c.Assert(req.Method, check.Equals, "POST")
+	c.Assert(req.URL.RawQuery, check.Equals, "delete=")
+	c.Assert(readAll(req.Body), check.Equals, `<?xml version="1.0" encoding="UTF-8"?>
+<Delete><Quiet>true</Quiet><Object><Key>logs/a</Key></Object><Object><Key>logs/b</Key></Object></Delete>`)
+}
+
+func (s *S) TestDeletePrefixStopsAfterFailure(c *check.C) {
+	s.DisableRetries()
+	testServer.Response(200, nil, `<VersioningConfiguration/>`)
+	testServer.Response(200, nil, `<ListBucketResult>
+  <Contents><Key>logs/a</Key></Contents>
+  <Contents><Key>logs/b</Key></Contents>
+  <Contents><Key>logs/c</Key></Contents>
+  <IsTruncated>false</IsTruncated>
+</ListBucketResult>`)
+	deletes := 0
+	testServer.ResponseFunc(3, func(path string) testutil.Response {
+		deletes++
+		return testutil.Response{Status: 403, Body: `<Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>`}
+	})
+
+	b := s.s3.Bucket("bucket")
+	summary, err := b.DeletePrefix("logs/", s3.DeleteOptions{BatchSize: 1})
+	c.Assert(err, check.ErrorMatches, "Access Denied")
+	c.Assert(summary.Deleted, check.Equals, 0)
+	c.Assert(deletes, check.Equals, 1)
+}
+
+func (s *S) TestDeletePrefixVersionedBucket(c *check.C) {
+	testServer.Response(200, nil, `<VersioningConfiguration><Status>Suspended</Status></VersioningConfiguration>`)
+	testServer.Response(200, nil, ListVersionsWithDeleteMarkersDump)
+	testServer.Response(200, nil, `<DeleteResult/>`)
+
+	b := s.s3.Bucket("bucket")
+	summary, err := b.DeletePrefix("", s3.DeleteOptions{})
+	c.Assert(err, check.IsNil)
+	c.Assert(summary.Deleted, check.Equals, 4)
+
+	req := testServer.WaitRequest()
+	c.Assert(req.URL.RawQuery, check.Equals, "versioning=")
+	req = testServer.WaitRequest()
+	_, ok := req.Form["versions"]
+	c.Assert(ok, check.Equals, true)
+	req = testServer.WaitRequest()
+	c.Assert(req.URL.RawQuery, check.Equals, "delete=")
+	c.Assert(readAll(req.Body), check.Equals, `<?xml version="1.0" encoding="UTF-8"?>
+<Delete><Quiet>true</Quiet><Object><Key>a</Key><VersionId>a3</VersionId></Object><Object><Key>a</Key><VersionId>a2</VersionId></Object><Object><Key>a</Key><VersionId>a1</VersionId></Object><Object><Key>b</Key><VersionId>b1</VersionId></Object></Delete>`)
+}
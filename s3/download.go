@@ -0,0 +1,157 @@
+package s3
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/AdRoll/goamz/s3/internal/pool"
+)
+
+// DownloadOptions controls a Download.
+type DownloadOptions struct {
+	// PartSize is the size of each byte range requested. It defaults
+	// to MinPartSize.
+	PartSize int64
+
+	// Concurrency is the number of ranges fetched in parallel. It
+	// defaults to 1.
+	Concurrency int
+
+	// Retries is the number of times a range is requested again after
+	// it failed or its body was cut short, on top of the retries done
+	// for transient errors.
+	Retries int
+
+	// Offset is the number of bytes at the start of the object that
+	// were already written by an earlier, interrupted download. Only
+	// the remaining bytes are fetched.
+	Offset int64
+
+	// ETag, if set, is the ETag of the object seen by the earlier
+	// download. Download fails without fetching anything if the object
+	// has changed since.
+	ETag string
+}
+
+// Download fetches the object at path into w, requesting byte ranges
+// of options.PartSize in parallel. The object is first inspected with
+// a HEAD request, and every range is requested with If-Match set to the
+// object's ETag so that a concurrent overwrite fails the download rather
+// than mixing two versions. A range whose body is cut short is requested
+// again up to options.Retries times. If a range still fails, the
+// remaining ranges are cancelled and the error is returned; bytes
+// already written to w are left in place.
+//
+// Download returns the size and ETag of the object. If a range fails,
+// it returns instead the number of bytes at the start of the object
+// that were fully written to w, counting options.Offset, along with the
+// ETag: the download can be resumed by passing them as options.Offset
+// and options.ETag.
+func (b *Bucket) Download(path string, w io.WriterAt, options DownloadOptions) (size int64, etag string, err error) {
+	partSize := options.PartSize
+	if partSize <= 0 {
+		partSize = MinPartSize
+	}
+
+	resp, err := b.Head(path, nil)
+	if err != nil {
+		return 0, "", err
+	}
+	resp.Body.Close()
+	size, etag = resp.ContentLength, resp.Header.Get("ETag")
+	if size < 0 {
+		return 0, "", fmt.Errorf("s3: object %s has unknown size", path)
+	}
+	if options.ETag != "" && options.ETag != etag {
+		return 0, "", fmt.Errorf("s3: object %s changed: ETag is %s, was %s", path, etag, options.ETag)
+	}
+	if options.Offset > size {
+		return 0, "", fmt.Errorf("s3: cannot resume download of %s at byte %d: object has %d bytes", path, options.Offset, size)
+	}
+
+	var (
+		mu   sync.Mutex
+		done = make([]bool, (size-options.Offset+partSize-1)/partSize)
+		g    = pool.New(options.Concurrency)
+	)
+	for offset := options.Offset; offset < size; offset += partSize {
+		offset := offset
+		ok := g.Go(func() error {
+			length := partSize
+			if offset+length > size {
+				length = size - offset
+			}
+			if err := b.getRange(path, etag, w, offset, length, options.Retries); err != nil {
+				return err
+			}
+			mu.Lock()
+			done[(offset-options.Offset)/partSize] = true
+			mu.Unlock()
+			return nil
+		})
+		if !ok {
+			break
+		}
+	}
+
+	if err := g.Wait(); err != nil {
+		written := options.Offset
+		for i := 0; i < len(done) && done[i]; i++ {
+			written += partSize
+		}
+		return written, etag, err
+	}
+	return size, etag, nil
+}
+
+// getRange copies length bytes of the object at path, starting at
+// offset, into w at the same offset. The range is requested again up to
+// retries times if it fails.
+func (b *Bucket) getRange(path, etag string, w io.WriterAt, offset, length int64, retries int) error {
+	headers := http.Header{}
+	headers.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-"+strconv.FormatInt(offset+length-1, 10))
+	if etag != "" {
+		headers.Set("If-Match", etag)
+	}
+	for try := 0; ; try++ {
+		err := b.copyRange(path, headers, w, offset, length)
+		if err == nil || try >= retries {
+			return err
+		}
+	}
+}
+
+func (b *Bucket) copyRange(path string, headers http.Header, w io.WriterAt, offset, length int64) error {
+	resp, err := b.GetResponseWithHeaders(path, headers)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.ContentLength >= 0 && resp.ContentLength != length {
+		return fmt.Errorf("s3: range at byte %d of %s has %d bytes, want %d", offset, path, resp.ContentLength, length)
+	}
+	n, err := io.Copy(&offsetWriter{w, offset}, io.LimitReader(resp.Body, length))
+	if err != nil {
+		return err
+	}
+	if n != length {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+// offsetWriter turns sequential writes into WriteAt calls starting at
+// off.
+type offsetWriter struct {
+	w   io.WriterAt
+	off int64
+}
+
+func (o *offsetWriter) Write(p []byte) (int, error) {
+	n, err := o.w.WriteAt(p, o.off)
+	o.off += int64(n)
+	return n, err
+}
@@ -0,0 +1,118 @@
+package s3_test
+
+import (
+	"github.com/AdRoll/goamz/s3"
+	"github.com/AdRoll/goamz/testutil"
+	"gopkg.in/check.v1"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// memWriterAt is an in-memory io.WriterAt.
+type memWriterAt struct {
+	mu   sync.Mutex
+	data []byte
+}
+
+func (m *memWriterAt) WriteAt(p []byte, off int64) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if end := int(off) + len(p); end > len(m.data) {
+		m.data = append(m.data, make([]byte, end-len(m.data))...)
+	}
+	return copy(m.data[off:], p), nil
+}
+
+var headDownloadResponse = map[string]string{"Content-Length": "9", "ETag": `"etag"`}
+
+func (s *S) TestDownloadConcurrent(c *check.C) {
+	testServer.Response(200, headDownloadResponse, "")
+	// Every range of "abcabcabc" holds the same bytes, so the order in
+	// which they are served does not matter.
+	testServer.Responses(3, 206, nil, "abc")
+
+	b := s.s3.Bucket("bucket")
+	w := &memWriterAt{}
+	size, etag, err := b.Download("name", w, s3.DownloadOptions{PartSize: 3, Concurrency: 3})
+	c.Assert(err, check.IsNil)
+	c.Assert(size, check.Equals, int64(9))
+	c.Assert(etag, check.Equals, `"etag"`)
+	c.Assert(string(w.data), check.Equals, "abcabcabc")
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Method, check.Equals, "HEAD")
+	var ranges []string
+	for _, req := range testServer.WaitRequests(3) {
+		c.Assert(req.Method, check.Equals, "GET")
+		c.Assert(req.Header.Get("If-Match"), check.Equals, `"etag"`)
+		ranges = append(ranges, req.Header.Get("Range"))
+	}
+	sort.Strings(ranges)
+	c.Assert(ranges, check.DeepEquals, []string{"bytes=0-2", "bytes=3-5", "bytes=6-8"})
+}
+
+func (s *S) TestDownloadResume(c *check.C) {
+	testServer.Response(200, headDownloadResponse, "")
+	testServer.Response(206, nil, "ghi")
+
+	b := s.s3.Bucket("bucket")
+	w := &memWriterAt{data: []byte("abcdef")}
+	options := s3.DownloadOptions{PartSize: 4, Offset: 6, ETag: `"etag"`}
+	_, _, err := b.Download("name", w, options)
+	c.Assert(err, check.IsNil)
+	c.Assert(string(w.data), check.Equals, "abcdefghi")
+
+	testServer.WaitRequest()
+	req := testServer.WaitRequest()
+	c.Assert(req.Header.Get("Range"), check.Equals, "bytes=6-8")
+}
+
+func (s *S) TestDownloadFailureReturnsWrittenPrefix(c *check.C) {
+	s.DisableRetries()
+	testServer.Response(200, headDownloadResponse, "")
+	testServer.Response(206, nil, "def")
+	testServer.Response(403, nil, `<Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>`)
+
+	b := s.s3.Bucket("bucket")
+	w := &memWriterAt{data: []byte("abc")}
+	options := s3.DownloadOptions{PartSize: 3, Offset: 3}
+	written, etag, err := b.Download("name", w, options)
+	c.Assert(err, check.ErrorMatches, "Access Denied")
+	c.Assert(written, check.Equals, int64(6))
+	c.Assert(etag, check.Equals, `"etag"`)
+	c.Assert(string(w.data), check.Equals, "abcdef")
+	testServer.WaitRequests(3)
+}
+
+func (s *S) TestDownloadResumeChangedObject(c *check.C) {
+	testServer.Response(200, headDownloadResponse, "")
+
+	b := s.s3.Bucket("bucket")
+	options := s3.DownloadOptions{Offset: 6, ETag: `"old"`}
+	_, _, err := b.Download("name", &memWriterAt{}, options)
+	c.Assert(err, check.ErrorMatches, `s3: object name changed: ETag is "etag", was "old"`)
+}
+
+func (s *S) TestDownloadRetriesTruncatedRange(c *check.C) {
+	faults := &testutil.Faults{}
+	testServer.SetFaults(faults)
+	defer testServer.SetFaults(nil)
+	faults.Add(testutil.FaultRule{
+		Fault: testutil.Fault{Truncate: true},
+		Match: func(req *http.Request) bool { return req.Header.Get("Range") == "bytes=0-4" },
+		Times: 1,
+	})
+	testServer.Response(200, map[string]string{"Content-Length": "9"}, "")
+	testServer.Response(206, nil, "abcde")
+	testServer.Response(206, nil, "abcde")
+	testServer.Response(206, nil, "fghi")
+
+	b := s.s3.Bucket("bucket")
+	w := &memWriterAt{}
+	_, _, err := b.Download("name", w, s3.DownloadOptions{PartSize: 5, Retries: 1})
+	c.Assert(err, check.IsNil)
+	c.Assert(string(w.data), check.Equals, "abcdefghi")
+	c.Assert(faults.Fired(), check.Equals, 1)
+	testServer.WaitRequests(4)
+}
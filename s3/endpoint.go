@@ -0,0 +1,267 @@
+package s3
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/AdRoll/goamz/aws"
+)
+
+// Addressing modes, selecting where the bucket name goes in request URLs.
+const (
+	// AddressingAuto uses the S3BucketEndpoint template of the region if
+	// it has one, and path-style addressing otherwise.
+	AddressingAuto = iota
+
+	// AddressingPathStyle puts the bucket in the path, as in
+	// https://s3.amazonaws.com/bucket/key. Most S3-compatible stores
+	// expect it.
+	AddressingPathStyle
+
+	// AddressingVirtualHosted puts the bucket in the host name, as in
+	// https://bucket.s3.amazonaws.com/key. The bucket name must then be
+	// DNS-compatible.
+	AddressingVirtualHosted
+)
+
+// Transfer acceleration states.
+const (
+	AccelerateEnabled   = "Enabled"
+	AccelerateSuspended = "Suspended"
+)
+
+// AccelerateConfiguration holds the transfer acceleration state of a
+// bucket. Status is empty for a bucket that never had it enabled.
+type AccelerateConfiguration struct {
+	XMLName xml.Name `xml:"AccelerateConfiguration"`
+	Status  string   `xml:"Status,omitempty"`
+}
+
+// NewWithEndpoint returns an S3 talking to an S3-compatible store, such as
+// MinIO or Ceph RGW, listening at endpoint, e.g. "http://localhost:9000".
+// Buckets are addressed path-style and requests are signed with signature
+// version 4 for regionName, which defaults to us-east-1.
+func NewWithEndpoint(auth aws.Auth, endpoint, regionName string) *S3 {
+	if regionName == "" {
+		regionName = aws.USEast.Name
+	}
+	region := aws.Region{
+		Name:       regionName,
+		S3Endpoint: strings.TrimRight(endpoint, "/"),
+	}
+	return &S3{
+		Auth:       auth,
+		Region:     region,
+		Signature:  aws.V4Signature,
+		Addressing: AddressingPathStyle,
+	}
+}
+
+// GetBucketAccelerate returns the transfer acceleration state of the
+// bucket.
+func (b *Bucket) GetBucketAccelerate() (*AccelerateConfiguration, error) {
+	conf := &AccelerateConfiguration{}
+	if err := b.getSubresourceXML("/", "accelerate", conf); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+// PutBucketAccelerate enables or suspends transfer acceleration on the
+// bucket. Once it is enabled, set S3.Accelerate to use it.
+//
+// See https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketAccelerateConfiguration.html
+// for details.
+func (b *Bucket) PutBucketAccelerate(conf AccelerateConfiguration) error {
+	return b.putSubresourceXML("/", "accelerate", conf)
+}
+
+// checkEndpoint checks that the endpoint of requests can be used. The
+// acceleration and dual-stack endpoints only exist on AWS, so asking for
+// them with the endpoint of an S3-compatible store is an error rather
+// than a silent switch to AWS.
+func (s3 *S3) checkEndpoint(accelerate bool) error {
+	if (accelerate || s3.DualStack) && !isAWSEndpoint(s3.Region.S3Endpoint) {
+		return fmt.Errorf("s3: transfer acceleration and dual-stack are only available on AWS, not at %s", s3.Region.S3Endpoint)
+	}
+	return nil
+}
+
+// endpoint returns the service endpoint requests are sent to, before the
+// bucket is added to it.
+func (s3 *S3) endpoint(accelerate bool) string {
+	switch {
+	case accelerate && s3.DualStack:
+		return "https://s3-accelerate.dualstack.amazonaws.com"
+	case accelerate:
+		return "https://s3-accelerate.amazonaws.com"
+	case s3.DualStack:
+		domain := "amazonaws.com"
+		if strings.HasPrefix(s3.Region.Name, "cn-") {
+			domain = "amazonaws.com.cn"
+		}
+		return "https://s3.dualstack." + s3.Region.Name + "." + domain
+	}
+	return s3.Region.S3Endpoint
+}
+
+// isAWSEndpoint reports whether endpoint is an AWS S3 endpoint. An empty
+// endpoint is taken to be one, the region giving the AWS host name.
+func isAWSEndpoint(endpoint string) bool {
+	if endpoint == "" {
+		return true
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return false
+	}
+	host := u.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.HasSuffix(host, ".amazonaws.com") || strings.HasSuffix(host, ".amazonaws.com.cn")
+}
+
+// accelerates reports whether req goes to the transfer acceleration
+// endpoint. Listing buckets, creating or deleting a bucket and changing
+// its acceleration state are not supported there.
+func (s3 *S3) accelerates(req *request) bool {
+	if !s3.Accelerate || req.bucket == "" {
+		return false
+	}
+	if _, ok := req.params["accelerate"]; ok {
+		return false
+	}
+	bucketRoot := req.path == "" || req.path == "/"
+	return !(bucketRoot && len(req.params) == 0 && (req.method == "PUT" || req.method == "DELETE"))
+}
+
+// Sets baseurl on req from bucket name and the region endpoint
+func (s3 *S3) setBaseURL(req *request) error {
+	accelerate := s3.accelerates(req)
+	if err := s3.checkEndpoint(accelerate); err != nil && !req.unchecked {
+		return err
+	}
+	endpoint := s3.endpoint(accelerate)
+	if req.bucket == "" {
+		req.baseurl = endpoint
+		return nil
+	}
+
+	addressing := s3.Addressing
+	if accelerate {
+		addressing = AddressingVirtualHosted
+	}
+	switch addressing {
+	case AddressingPathStyle:
+		if err := checkPathStyleBucket(req.bucket); err != nil && !req.unchecked {
+			return err
+		}
+		req.baseurl = endpoint
+		req.path = "/" + req.bucket + req.path
+	case AddressingVirtualHosted:
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return fmt.Errorf("bad S3 endpoint URL %q: %v", endpoint, err)
+		}
+		// Periods in the bucket name do not match the wildcard TLS
+		// certificate, and acceleration does not allow them at all.
+		if req.unchecked {
+			err = checkBucketInjection(req.bucket)
+		} else {
+			err = checkVirtualHostedBucket(req.bucket, accelerate || u.Scheme == "https")
+		}
+		if err != nil {
+			return err
+		}
+		u.Host = req.bucket + "." + u.Host
+		req.baseurl = u.String()
+		req.hostBucket = true
+	default:
+		if s3.Region.S3BucketEndpoint == "" || s3.DualStack {
+			// Use the path method to address the bucket.
+			req.baseurl = endpoint
+			req.path = "/" + req.bucket + req.path
+			return nil
+		}
+		if err := checkBucketInjection(req.bucket); err != nil {
+			return err
+		}
+		req.baseurl = strings.Replace(s3.Region.S3BucketEndpoint, "${bucket}", req.bucket, -1)
+		req.hostBucket = true
+	}
+	return nil
+}
+
+// bucketURL returns the URL of the bucket, ending with a slash, as
+// addressed by s3.
+func (s3 *S3) bucketURL(bucket string) (string, error) {
+	req := &request{method: "POST", bucket: bucket, path: "/"}
+	if err := s3.setBaseURL(req); err != nil {
+		return "", err
+	}
+	return req.baseurl + req.path, nil
+}
+
+// checkBucketInjection rejects the bucket names that would change the
+// host of the URLs they are put in.
+func checkBucketInjection(name string) error {
+	if strings.IndexAny(name, "/:@") >= 0 {
+		return fmt.Errorf("bad S3 bucket: %q", name)
+	}
+	return nil
+}
+
+// checkPathStyleBucket checks name against the legacy bucket naming rules,
+// which path-style addressing still accepts.
+func checkPathStyleBucket(name string) error {
+	if len(name) == 0 || len(name) > 255 {
+		return fmt.Errorf("s3: bucket name %q must have 1 to 255 characters", name)
+	}
+	for _, c := range name {
+		if !isBucketNameChar(c) && c != '_' && !('A' <= c && c <= 'Z') {
+			return fmt.Errorf("s3: invalid character %q in bucket name %q", c, name)
+		}
+	}
+	return nil
+}
+
+// checkVirtualHostedBucket checks that name can be used as a DNS label of
+// the bucket host, rejecting periods too if noPeriods is set.
+//
+// See https://docs.aws.amazon.com/AmazonS3/latest/userguide/bucketnamingrules.html
+// for details.
+func checkVirtualHostedBucket(name string, noPeriods bool) error {
+	if len(name) < 3 || len(name) > 63 {
+		return fmt.Errorf("s3: bucket name %q must have 3 to 63 characters", name)
+	}
+	for _, c := range name {
+		if !isBucketNameChar(c) {
+			return fmt.Errorf("s3: invalid character %q in bucket name %q", c, name)
+		}
+	}
+	if !isAlnum(name[0]) || !isAlnum(name[len(name)-1]) {
+		return fmt.Errorf("s3: bucket name %q must begin and end with a letter or digit", name)
+	}
+	if strings.Contains(name, "..") || strings.Contains(name, ".-") || strings.Contains(name, "-.") {
+		return fmt.Errorf("s3: bucket name %q has an empty or malformed label", name)
+	}
+	if net.ParseIP(name) != nil {
+		return fmt.Errorf("s3: bucket name %q must not be an IP address", name)
+	}
+	if noPeriods && strings.Contains(name, ".") {
+		return fmt.Errorf("s3: bucket name %q must not contain periods; use path-style addressing", name)
+	}
+	return nil
+}
+
+func isBucketNameChar(c rune) bool {
+	return 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '.' || c == '-'
+}
+
+func isAlnum(c byte) bool {
+	return 'a' <= c && c <= 'z' || '0' <= c && c <= '9'
+}
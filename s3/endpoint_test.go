@@ -0,0 +1,179 @@
+package s3_test
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AdRoll/goamz/aws"
+	"github.com/AdRoll/goamz/s3"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestAddressingModes(c *check.C) {
+	auth := aws.Auth{AccessKey: "abc", SecretKey: "123"}
+	tests := []struct {
+		region     aws.Region
+		addressing int
+		accelerate bool
+		dualStack  bool
+		url        string
+	}{
+		{aws.USEast, s3.AddressingAuto, false, false, "https://s3.amazonaws.com/bucket/key"},
+		{aws.USEast, s3.AddressingPathStyle, false, false, "https://s3.amazonaws.com/bucket/key"},
+		{aws.USEast, s3.AddressingVirtualHosted, false, false, "https://bucket.s3.amazonaws.com/key"},
+		{aws.USWest2, s3.AddressingVirtualHosted, false, false, "https://bucket.s3-us-west-2.amazonaws.com/key"},
+		{aws.USWest2, s3.AddressingPathStyle, false, true, "https://s3.dualstack.us-west-2.amazonaws.com/bucket/key"},
+		{aws.USWest2, s3.AddressingVirtualHosted, false, true, "https://bucket.s3.dualstack.us-west-2.amazonaws.com/key"},
+		{aws.USWest2, s3.AddressingPathStyle, true, false, "https://bucket.s3-accelerate.amazonaws.com/key"},
+		{aws.USWest2, s3.AddressingAuto, true, true, "https://bucket.s3-accelerate.dualstack.amazonaws.com/key"},
+		{aws.Region{Name: "cn-north-1"}, s3.AddressingPathStyle, false, true, "https://s3.dualstack.cn-north-1.amazonaws.com.cn/bucket/key"},
+	}
+	for _, t := range tests {
+		s3c := s3.New(auth, t.region)
+		s3c.Addressing = t.addressing
+		s3c.Accelerate = t.accelerate
+		s3c.DualStack = t.dualStack
+		c.Check(s3c.Bucket("bucket").URL("key"), check.Equals, t.url)
+	}
+}
+
+func (s *S) TestVirtualHostedBucketNames(c *check.C) {
+	s3c := s3.New(aws.Auth{AccessKey: "abc", SecretKey: "123"}, aws.USEast)
+	s3c.Addressing = s3.AddressingVirtualHosted
+
+	for _, name := range []string{"ab", "Bucket", "bucket_1", "-bucket", "bucket-", "my..bucket", "my-.bucket", "192.168.5.4", "my.bucket"} {
+		_, err := s3c.Bucket(name).Get("key")
+		c.Check(err, check.ErrorMatches, "s3: .*bucket name.*", check.Commentf("%s", name))
+	}
+
+	// Periods are fine over plain HTTP.
+	s3c.Region = aws.Region{Name: "local", S3Endpoint: "http://s3.local:8080"}
+	c.Assert(s3c.Bucket("my.bucket").URL("key"), check.Equals, "http://my.bucket.s3.local:8080/key")
+}
+
+func (s *S) TestPathStyleBucketNames(c *check.C) {
+	s3c := s3.New(aws.Auth{AccessKey: "abc", SecretKey: "123"}, aws.Region{Name: "local", S3Endpoint: testServer.URL})
+	s3c.Addressing = s3.AddressingPathStyle
+	c.Assert(s3c.Bucket("Legacy_Bucket.1").URL("key"), check.Equals, testServer.URL+"/Legacy_Bucket.1/key")
+
+	for _, name := range []string{"a/b", "a:b", "a@b", strings.Repeat("a", 256)} {
+		_, err := s3c.Bucket(name).Get("key")
+		c.Check(err, check.ErrorMatches, "s3: .*bucket name.*", check.Commentf("%s", name))
+	}
+}
+
+func (s *S) TestAccelerateSkipsBucketOperations(c *check.C) {
+	s3c := s3.New(aws.Auth{AccessKey: "abc", SecretKey: "123"}, aws.Region{Name: "local", S3Endpoint: testServer.URL})
+	s3c.Accelerate = true
+
+	// Creating a bucket is not supported by the acceleration endpoint,
+	// so the request still reaches the regional one.
+	testServer.Response(200, nil, "")
+	err := s3c.Bucket("bucket").PutBucket(s3.Private)
+	c.Assert(err, check.IsNil)
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Method, check.Equals, "PUT")
+	c.Assert(req.URL.Path, check.Equals, "/bucket/")
+}
+
+func (s *S) TestAccelerateAndDualStackNeedAWS(c *check.C) {
+	s3c := s3.NewWithEndpoint(aws.Auth{AccessKey: "abc", SecretKey: "123"}, testServer.URL, "")
+	s3c.Addressing = s3.AddressingAuto
+	for _, t := range []struct{ accelerate, dualStack bool }{{true, false}, {false, true}, {true, true}} {
+		s3c.Accelerate = t.accelerate
+		s3c.DualStack = t.dualStack
+		_, err := s3c.Bucket("bucket").Get("key")
+		c.Check(err, check.ErrorMatches, "s3: transfer acceleration and dual-stack are only available on AWS, not at "+testServer.URL)
+	}
+}
+
+func (s *S) TestURLDoesNotCheckAddressing(c *check.C) {
+	s3c := s3.NewWithEndpoint(aws.Auth{AccessKey: "abc", SecretKey: "123"}, testServer.URL, "")
+	s3c.Accelerate = true
+	expires := time.Now().Add(time.Hour)
+
+	// URL and SignedURL cannot return the error, and do not panic.
+	c.Assert(s3c.Bucket("bucket").URL("key"), check.Equals, "https://bucket.s3-accelerate.amazonaws.com/key")
+	c.Assert(s3c.Bucket("bucket").SignedURL("key", expires), check.Matches, "https://bucket.s3-accelerate.amazonaws.com/key\\?.*")
+	_, err := s3c.Bucket("bucket").ObjectURL("key")
+	c.Assert(err, check.ErrorMatches, "s3: transfer acceleration and dual-stack are only available on AWS, not at "+testServer.URL)
+	_, err = s3c.Bucket("bucket").SignURL("GET", "key", expires, nil, nil)
+	c.Assert(err, check.ErrorMatches, "s3: transfer acceleration and dual-stack are only available on AWS, not at "+testServer.URL)
+
+	s3c = s3.New(aws.Auth{AccessKey: "abc", SecretKey: "123"}, aws.USEast)
+	s3c.Addressing = s3.AddressingVirtualHosted
+	c.Assert(s3c.Bucket("my.bucket").URL("key"), check.Equals, "https://my.bucket.s3.amazonaws.com/key")
+	_, err = s3c.Bucket("my.bucket").ObjectURL("key")
+	c.Assert(err, check.ErrorMatches, "s3: .*bucket name.*")
+
+	// Injection into the host name is still refused.
+	c.Assert(func() { s3c.Bucket("evil.com/").URL("key") }, check.PanicMatches, `bad S3 bucket: "evil.com/"`)
+}
+
+func (s *S) TestNewWithEndpoint(c *check.C) {
+	testServer.Response(200, nil, "")
+
+	s3c := s3.NewWithEndpoint(aws.Auth{AccessKey: "abc", SecretKey: "123"}, testServer.URL+"/", "")
+	c.Assert(s3c.Region.Name, check.Equals, "us-east-1")
+
+	err := s3c.Bucket("my.bucket").Put("name", []byte("content"), "text/plain", s3.Private, s3.Options{})
+	c.Assert(err, check.IsNil)
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Method, check.Equals, "PUT")
+	c.Assert(req.URL.Path, check.Equals, "/my.bucket/name")
+	c.Assert(req.Header.Get("Authorization"), check.Matches, "AWS4-HMAC-SHA256 Credential=abc/[0-9]+/us-east-1/s3/aws4_request,.*")
+}
+
+func (s *S) TestVirtualHostedSignatureV2(c *check.C) {
+	auth := aws.Auth{AccessKey: "abc", SecretKey: "123"}
+	s3c := s3.New(auth, aws.USEast)
+	s3c.Addressing = s3.AddressingVirtualHosted
+
+	expires := time.Now().Add(time.Hour)
+	u, err := url.Parse(s3c.Bucket("bucket").SignedURL("key", expires))
+	c.Assert(err, check.IsNil)
+	c.Assert(u.Host, check.Equals, "bucket.s3.amazonaws.com")
+
+	// The bucket is part of the signed resource even though it is not in
+	// the path.
+	params := map[string][]string{"Expires": {strconv.FormatInt(expires.Unix(), 10)}}
+	s3.Sign(auth, "GET", "/bucket/key", params, map[string][]string{})
+	c.Assert(u.Query().Get("Signature"), check.Equals, params["Signature"][0])
+}
+
+func (s *S) TestBucketEndpointSignatureV2(c *check.C) {
+	auth := aws.Auth{AccessKey: "abc", SecretKey: "123"}
+	region := aws.Region{Name: "faux-region-1", S3BucketEndpoint: "https://${bucket}.s3.example.com"}
+	s3c := s3.New(auth, region)
+
+	expires := time.Now().Add(time.Hour)
+	u, err := url.Parse(s3c.Bucket("bucket").SignedURL("key", expires))
+	c.Assert(err, check.IsNil)
+	c.Assert(u.Host, check.Equals, "bucket.s3.example.com")
+	c.Assert(u.Path, check.Equals, "/key")
+
+	// S3 checks the signature of /bucket/key, not of the path alone.
+	params := map[string][]string{"Expires": {strconv.FormatInt(expires.Unix(), 10)}}
+	s3.Sign(auth, "GET", "/bucket/key", params, map[string][]string{})
+	c.Assert(u.Query().Get("Signature"), check.Equals, params["Signature"][0])
+}
+
+func (s *S) TestPutBucketAccelerate(c *check.C) {
+	testServer.Response(200, nil, "")
+
+	s3c := s3.New(aws.Auth{AccessKey: "abc", SecretKey: "123"}, aws.Region{Name: "local", S3Endpoint: testServer.URL})
+	s3c.Accelerate = true
+	err := s3c.Bucket("bucket").PutBucketAccelerate(s3.AccelerateConfiguration{Status: s3.AccelerateEnabled})
+	c.Assert(err, check.IsNil)
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Method, check.Equals, "PUT")
+	c.Assert(req.URL.Path, check.Equals, "/bucket/")
+	c.Assert(req.URL.RawQuery, check.Equals, "accelerate=")
+	c.Assert(readAll(req.Body), check.Equals, `<?xml version="1.0" encoding="UTF-8"?>
+<AccelerateConfiguration><Status>Enabled</Status></AccelerateConfiguration>`)
+}
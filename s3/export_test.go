@@ -25,3 +25,11 @@ func SetListPartsMax(n int) {
 func SetListMultiMax(n int) {
 	listMultiMax = n
 }
+
+func SetMinPartSize(n int64) {
+	if n == 0 {
+		minPartSize = MinPartSize
+	} else {
+		minPartSize = n
+	}
+}
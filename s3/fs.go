@@ -0,0 +1,369 @@
+//go:build go1.16
+// +build go1.16
+
+package s3
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FS is a read-only file system over the objects of a bucket whose keys
+// begin with a prefix, with slashes in keys separating directories. The
+// file named "a/b.txt" is the object prefix+"a/b.txt"; directories are
+// the common prefixes of keys, S3 having no real directories.
+//
+// Opening a file only issues a HEAD request. Its content is read with a
+// GET request issued by the first read, and seeking in a file makes the
+// following read issue a ranged GET, so that FS can serve large objects
+// through HTTPFileSystem with a single GET each.
+type FS struct {
+	b      *Bucket
+	prefix string
+}
+
+var (
+	_ fs.FS        = (*FS)(nil)
+	_ fs.ReadDirFS = (*FS)(nil)
+	_ fs.StatFS    = (*FS)(nil)
+)
+
+// FS returns the objects of the bucket whose keys begin with prefix as a
+// file system. The prefix is usually empty, for the whole bucket, or ends
+// with a slash.
+func (b *Bucket) FS(prefix string) *FS {
+	return &FS{b: b, prefix: prefix}
+}
+
+// HTTPFileSystem returns f as an http.FileSystem, for http.FileServer.
+// It supports Range requests.
+func (f *FS) HTTPFileSystem() http.FileSystem {
+	return http.FS(f)
+}
+
+// key returns the key of the object for the file name, which is valid.
+func (f *FS) key(name string) string {
+	if name == "." {
+		return f.prefix
+	}
+	return f.prefix + name
+}
+
+// dirPrefix returns the prefix of the keys in the directory name.
+func (f *FS) dirPrefix(name string) string {
+	if name == "." {
+		return f.prefix
+	}
+	return f.prefix + name + "/"
+}
+
+// Open opens the named file or directory.
+func (f *FS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if name != "." {
+		resp, err := f.b.Head(f.key(name), nil)
+		if err == nil {
+			info := fileInfoFromResponse(path.Base(name), resp)
+			return &file{fsys: f, info: info, name: name}, nil
+		}
+		if !isNotFound(err) {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+	}
+	info, err := f.statDir(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &dir{fsys: f, info: info, name: name}, nil
+}
+
+// Stat returns the description of the named file or directory.
+func (f *FS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	if name != "." {
+		resp, err := f.b.Head(f.key(name), nil)
+		if err == nil {
+			return fileInfoFromResponse(path.Base(name), resp), nil
+		}
+		if !isNotFound(err) {
+			return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+		}
+	}
+	info, err := f.statDir(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	return info, nil
+}
+
+// statDir describes the directory name, which exists if a key is in it.
+// The root always exists.
+func (f *FS) statDir(name string) (*fileInfo, error) {
+	info := &fileInfo{name: path.Base(name), dir: true}
+	if name == "." {
+		return info, nil
+	}
+	resp, err := f.b.List(f.dirPrefix(name), "/", "", 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Contents) == 0 && len(resp.CommonPrefixes) == 0 {
+		return nil, fs.ErrNotExist
+	}
+	return info, nil
+}
+
+// ReadDir reads the named directory and returns its entries sorted by
+// name. Keys whose names within the directory are not valid file names,
+// such as "a//b", are left out, and so are directories named like a file,
+// which Open would not open.
+func (f *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	entries, err := f.readDir(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	return entries, nil
+}
+
+func (f *FS) readDir(name string) ([]fs.DirEntry, error) {
+	prefix := f.dirPrefix(name)
+	var entries []fs.DirEntry
+	files := make(map[string]bool)
+	found := false
+	marker := ""
+	for {
+		resp, err := f.b.List(prefix, "/", marker, 1000)
+		if err != nil {
+			return nil, err
+		}
+		for _, k := range resp.Contents {
+			found = true
+			if base := k.Key[len(prefix):]; validName(base) {
+				entries = append(entries, fileInfoFromKey(base, k))
+				files[base] = true
+			}
+		}
+		for _, p := range resp.CommonPrefixes {
+			found = true
+			if base := strings.TrimSuffix(p[len(prefix):], "/"); validName(base) && !files[base] {
+				entries = append(entries, &fileInfo{name: base, dir: true})
+			}
+		}
+		if !resp.IsTruncated {
+			break
+		}
+		marker = resp.NextMarker
+		if marker == "" {
+			// Fall back on the last entry if NextMarker is missing.
+			marker = lastListed(resp)
+		}
+	}
+	if !found && name != "." {
+		// Like Open, report a missing directory, or a file.
+		if _, err := f.b.Head(f.key(name), nil); err == nil {
+			return nil, errors.New("not a directory")
+		}
+		return nil, fs.ErrNotExist
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+// lastListed returns the greatest key or common prefix of a page.
+func lastListed(resp *ListResp) string {
+	last := ""
+	if n := len(resp.Contents); n > 0 {
+		last = resp.Contents[n-1].Key
+	}
+	if n := len(resp.CommonPrefixes); n > 0 && resp.CommonPrefixes[n-1] > last {
+		last = resp.CommonPrefixes[n-1]
+	}
+	return last
+}
+
+// validName reports whether name is a valid name for an entry of a
+// directory.
+func validName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.Contains(name, "/")
+}
+
+func isNotFound(err error) bool {
+	e, ok := err.(*Error)
+	return ok && e.StatusCode == http.StatusNotFound
+}
+
+// fileInfo describes a file or directory of an FS. It is also a
+// fs.DirEntry.
+type fileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+	etag    string
+}
+
+func fileInfoFromResponse(name string, resp *http.Response) *fileInfo {
+	info := &fileInfo{
+		name: name,
+		size: resp.ContentLength,
+		etag: resp.Header.Get("ETag"),
+	}
+	info.modTime, _ = http.ParseTime(resp.Header.Get("Last-Modified"))
+	return info
+}
+
+func fileInfoFromKey(name string, k Key) *fileInfo {
+	info := &fileInfo{
+		name: name,
+		size: k.Size,
+		etag: k.ETag,
+	}
+	// Keep the precision of the Last-Modified header of the object.
+	info.modTime, _ = time.Parse(time.RFC3339, k.LastModified)
+	info.modTime = info.modTime.Truncate(time.Second)
+	return info
+}
+
+func (fi *fileInfo) Name() string       { return fi.name }
+func (fi *fileInfo) Size() int64        { return fi.size }
+func (fi *fileInfo) ModTime() time.Time { return fi.modTime }
+func (fi *fileInfo) IsDir() bool        { return fi.dir }
+func (fi *fileInfo) Sys() interface{}   { return nil }
+
+func (fi *fileInfo) Mode() fs.FileMode {
+	if fi.dir {
+		return fs.ModeDir | 0555
+	}
+	return 0444
+}
+
+func (fi *fileInfo) Type() fs.FileMode          { return fi.Mode().Type() }
+func (fi *fileInfo) Info() (fs.FileInfo, error) { return fi, nil }
+
+// file is an open file of an FS. Its content is read from body, the
+// response to a GET request starting at offset, made by the first Read
+// after opening the file or seeking.
+type file struct {
+	fsys   *FS
+	info   *fileInfo
+	name   string
+	body   io.ReadCloser
+	offset int64
+}
+
+func (f *file) Stat() (fs.FileInfo, error) { return f.info, nil }
+
+func (f *file) Read(p []byte) (int, error) {
+	if f.offset >= f.info.size {
+		return 0, io.EOF
+	}
+	if f.body == nil {
+		headers := make(http.Header)
+		if f.offset > 0 {
+			headers.Set("Range", fmt.Sprintf("bytes=%d-", f.offset))
+		}
+		if f.info.etag != "" {
+			// Fail rather than mix the data of two versions of the object.
+			headers.Set("If-Match", f.info.etag)
+		}
+		resp, err := f.fsys.b.GetResponseWithHeaders(f.fsys.key(f.name), headers)
+		if err != nil {
+			return 0, &fs.PathError{Op: "read", Path: f.name, Err: err}
+		}
+		f.body = resp.Body
+	}
+	n, err := f.body.Read(p)
+	f.offset += int64(n)
+	return n, err
+}
+
+// Seek sets the offset of the next Read. Seeking elsewhere than the
+// current offset closes the response being read, and the next Read
+// requests the content from the new offset.
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.info.size
+	default:
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+	if offset != f.offset && f.body != nil {
+		f.body.Close()
+		f.body = nil
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *file) Close() error {
+	if f.body == nil {
+		return nil
+	}
+	err := f.body.Close()
+	f.body = nil
+	return err
+}
+
+// dir is an open directory of an FS. Its entries are listed on the first
+// call to ReadDir.
+type dir struct {
+	fsys    *FS
+	info    *fileInfo
+	name    string
+	entries []fs.DirEntry
+	listed  bool
+}
+
+func (d *dir) Stat() (fs.FileInfo, error) { return d.info, nil }
+
+func (d *dir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: errors.New("is a directory")}
+}
+
+func (d *dir) Close() error { return nil }
+
+// ReadDir returns the next n entries of the directory, or all the
+// remaining ones if n <= 0.
+func (d *dir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !d.listed {
+		entries, err := d.fsys.readDir(d.name)
+		if err != nil {
+			return nil, &fs.PathError{Op: "readdir", Path: d.name, Err: err}
+		}
+		d.entries, d.listed = entries, true
+	}
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(d.entries) {
+		n = len(d.entries)
+	}
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
@@ -0,0 +1,123 @@
+//go:build go1.16
+// +build go1.16
+
+package s3_test
+
+import (
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing/fstest"
+
+	"github.com/AdRoll/goamz/s3"
+	"github.com/AdRoll/goamz/testutil"
+	"gopkg.in/check.v1"
+)
+
+func (s *LocalServerSuite) TestFS(c *check.C) {
+	s.clientTests.TestFS(c)
+}
+
+func (s *LocalServerSuite) TestFSHTTPRange(c *check.C) {
+	// Serving a range costs a single GET.
+	gets := 0
+	faults := &testutil.Faults{}
+	faults.Add(testutil.FaultRule{
+		Fault: testutil.InternalErrorFault(testutil.S3Protocol),
+		Match: func(req *http.Request) bool {
+			if req.Method == "GET" && strings.HasSuffix(req.URL.Path, "/static/data.json") {
+				gets++
+			}
+			return false
+		},
+	})
+	s.srv.srv.SetFaults(faults)
+	defer s.srv.srv.SetFaults(nil)
+
+	s.clientTests.TestFSHTTPRange(c)
+	c.Assert(gets, check.Equals, 1)
+}
+
+func (s *ClientTests) TestFS(c *check.C) {
+	b := testBucket(s.s3)
+	err := b.PutBucket(s3.Private)
+	c.Assert(err, check.IsNil)
+
+	files := map[string]string{
+		"outside.txt":                  "not in the file system",
+		"site/index.html":              "<h1>hello</h1>",
+		"site/css/style.css":           "body {}",
+		"site/js/app.js":               "main();",
+		"site/js/lib/vendor.js":        "lib();",
+		"site/js/lib//skipped.js":      "not a valid path",
+		"site/templates/page.tmpl":     "{{.Title}}",
+		"site/templates/partial.tmpl":  "{{.Body}}",
+		"site/templates/partial.tmpl/": "",
+	}
+	for key, data := range files {
+		c.Assert(b.Put(key, []byte(data), "text/plain", s3.Private, s3.Options{}), check.IsNil)
+		defer b.Del(key)
+	}
+
+	fsys := b.FS("site/")
+	err = fstest.TestFS(fsys, "index.html", "css/style.css", "js/app.js", "js/lib/vendor.js", "templates/page.tmpl")
+	c.Assert(err, check.IsNil)
+
+	data, err := fs.ReadFile(fsys, "js/lib/vendor.js")
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, "lib();")
+
+	entries, err := fs.ReadDir(fsys, ".")
+	c.Assert(err, check.IsNil)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	c.Assert(names, check.DeepEquals, []string{"css", "index.html", "js", "templates"})
+
+	info, err := fs.Stat(fsys, "js")
+	c.Assert(err, check.IsNil)
+	c.Assert(info.IsDir(), check.Equals, true)
+	info, err = fs.Stat(fsys, "index.html")
+	c.Assert(err, check.IsNil)
+	c.Assert(info.Size(), check.Equals, int64(len(files["site/index.html"])))
+
+	_, err = fsys.Open("missing.txt")
+	c.Assert(err, check.ErrorMatches, "open missing.txt: file does not exist")
+	_, err = fsys.Open("/index.html")
+	c.Assert(err, check.ErrorMatches, "open /index.html: invalid argument")
+	_, err = fs.ReadDir(fsys, "index.html")
+	c.Assert(err, check.ErrorMatches, "readdir index.html: not a directory")
+}
+
+func (s *ClientTests) TestFSHTTPRange(c *check.C) {
+	b := testBucket(s.s3)
+	err := b.PutBucket(s3.Private)
+	c.Assert(err, check.IsNil)
+	c.Assert(b.Put("static/data.json", []byte("0123456789"), "application/json", s3.Private, s3.Options{}), check.IsNil)
+	defer b.Del("static/data.json")
+
+	web := httptest.NewServer(http.FileServer(b.FS("static/").HTTPFileSystem()))
+	defer web.Close()
+
+	req, err := http.NewRequest("GET", web.URL+"/data.json", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Range", "bytes=3-5")
+	resp, err := http.DefaultClient.Do(req)
+	c.Assert(err, check.IsNil)
+	defer resp.Body.Close()
+	c.Assert(resp.StatusCode, check.Equals, http.StatusPartialContent)
+	c.Assert(resp.Header.Get("Content-Range"), check.Equals, "bytes 3-5/10")
+	data, err := ioutil.ReadAll(resp.Body)
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, "345")
+
+	resp, err = http.Get(web.URL + "/missing.bin")
+	c.Assert(err, check.IsNil)
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	c.Assert(resp.StatusCode, check.Equals, http.StatusNotFound)
+}
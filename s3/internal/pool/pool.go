@@ -0,0 +1,94 @@
+// Package pool runs functions on a bounded number of goroutines, giving
+// up on those not started yet once one fails.
+package pool
+
+import (
+	"sync"
+)
+
+// Group runs the functions given to Go on a fixed number of goroutines
+// and keeps the first error. Once a function fails, Go refuses new
+// functions and those already queued are skipped.
+type Group struct {
+	mu     sync.Mutex
+	wg     sync.WaitGroup
+	err    error
+	jobs   chan func() error
+	failed chan struct{}
+}
+
+// New starts a Group of the given number of goroutines, or of one if
+// workers is not positive.
+func New(workers int) *Group {
+	if workers <= 0 {
+		workers = 1
+	}
+	g := &Group{
+		jobs:   make(chan func() error),
+		failed: make(chan struct{}),
+	}
+	for i := 0; i < workers; i++ {
+		g.wg.Add(1)
+		go g.work()
+	}
+	return g
+}
+
+func (g *Group) work() {
+	defer g.wg.Done()
+	for f := range g.jobs {
+		if g.Failed() {
+			continue
+		}
+		if err := f(); err != nil {
+			g.Fail(err)
+		}
+	}
+}
+
+// Go runs f on the next free goroutine, waiting for one. It returns
+// false without running f once a function failed, so that the caller
+// stops feeding the group.
+func (g *Group) Go(f func() error) bool {
+	// Of a failure and a free goroutine, select may pick either.
+	if g.Failed() {
+		return false
+	}
+	select {
+	case g.jobs <- f:
+		return true
+	case <-g.failed:
+		return false
+	}
+}
+
+// Fail records err as a failure of the group, if it is the first, as if
+// a function had returned it.
+func (g *Group) Fail(err error) {
+	g.mu.Lock()
+	if g.err == nil {
+		g.err = err
+		close(g.failed)
+	}
+	g.mu.Unlock()
+}
+
+// Failed reports whether a function failed.
+func (g *Group) Failed() bool {
+	select {
+	case <-g.failed:
+		return true
+	default:
+		return false
+	}
+}
+
+// Wait waits for the running functions to return and returns the first
+// error. The group cannot be used afterwards.
+func (g *Group) Wait() error {
+	close(g.jobs)
+	g.wg.Wait()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.err
+}
@@ -0,0 +1,67 @@
+package pool_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/AdRoll/goamz/s3/internal/pool"
+	"gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) {
+	check.TestingT(t)
+}
+
+type S struct{}
+
+var _ = check.Suite(&S{})
+
+func (s *S) TestRunsAll(c *check.C) {
+	var (
+		mu   sync.Mutex
+		done []int
+	)
+	g := pool.New(3)
+	for i := 0; i < 20; i++ {
+		i := i
+		ok := g.Go(func() error {
+			mu.Lock()
+			done = append(done, i)
+			mu.Unlock()
+			return nil
+		})
+		c.Assert(ok, check.Equals, true)
+	}
+	c.Assert(g.Wait(), check.IsNil)
+	c.Assert(done, check.HasLen, 20)
+	c.Assert(g.Failed(), check.Equals, false)
+}
+
+func (s *S) TestStopsAfterFailure(c *check.C) {
+	g := pool.New(1)
+	ran := 0
+	for i := 0; i < 5; i++ {
+		i := i
+		if !g.Go(func() error {
+			ran++
+			if i == 1 {
+				return errors.New("failed")
+			}
+			return nil
+		}) {
+			break
+		}
+	}
+	c.Assert(g.Wait(), check.ErrorMatches, "failed")
+	c.Assert(ran, check.Equals, 2)
+	c.Assert(g.Failed(), check.Equals, true)
+}
+
+func (s *S) TestFail(c *check.C) {
+	g := pool.New(0)
+	g.Fail(errors.New("first"))
+	g.Fail(errors.New("second"))
+	c.Assert(g.Go(func() error { return nil }), check.Equals, false)
+	c.Assert(g.Wait(), check.ErrorMatches, "first")
+}
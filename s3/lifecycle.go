@@ -17,6 +17,7 @@ const (
 	LifecycleRuleStatusDisabled = "Disabled"
 	LifecycleRuleDateFormat     = "2006-01-02"
 	StorageClassGlacier         = "GLACIER"
+	StorageClassDeepArchive     = "DEEP_ARCHIVE"
 )
 
 type Expiration struct {
@@ -49,9 +50,26 @@ type NoncurrentVersionTransition struct {
 	StorageClass string `xml:"StorageClass"`
 }
 
+// LifecycleFilter selects the objects a rule applies to by key prefix
+// and tags. Only one of Prefix, Tag and And may be set.
+type LifecycleFilter struct {
+	Prefix string              `xml:"Prefix,omitempty"`
+	Tag    *Tag                `xml:"Tag,omitempty"`
+	And    *LifecycleFilterAnd `xml:"And,omitempty"`
+}
+
+// LifecycleFilterAnd matches the objects that have the prefix and all
+// the tags.
+type LifecycleFilterAnd struct {
+	Prefix string `xml:"Prefix,omitempty"`
+	Tags   []Tag  `xml:"Tag"`
+}
+
 type LifecycleRule struct {
-	ID                          string                       `xml:"ID"`
-	Prefix                      string                       `xml:"Prefix"`
+	ID     string `xml:"ID"`
+	Prefix string `xml:"Prefix"`
+	// Filter, if set, is used instead of Prefix.
+	Filter                      *LifecycleFilter             `xml:"Filter,omitempty"`
 	Status                      string                       `xml:"Status"`
 	NoncurrentVersionTransition *NoncurrentVersionTransition `xml:"NoncurrentVersionTransition,omitempty"`
 	NoncurrentVersionExpiration *NoncurrentVersionExpiration `xml:"NoncurrentVersionExpiration,omitempty"`
@@ -59,6 +77,24 @@ type LifecycleRule struct {
 	Expiration                  *Expiration                  `xml:"Expiration,omitempty"`
 }
 
+// MarshalXML leaves out Prefix when Filter is set, as S3 rejects rules
+// with both.
+func (r LifecycleRule) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	type rule LifecycleRule
+	if r.Filter == nil {
+		return e.EncodeElement(rule(r), start)
+	}
+	return e.EncodeElement(struct {
+		ID                          string                       `xml:"ID"`
+		Filter                      *LifecycleFilter             `xml:"Filter"`
+		Status                      string                       `xml:"Status"`
+		NoncurrentVersionTransition *NoncurrentVersionTransition `xml:"NoncurrentVersionTransition,omitempty"`
+		NoncurrentVersionExpiration *NoncurrentVersionExpiration `xml:"NoncurrentVersionExpiration,omitempty"`
+		Transition                  *Transition                  `xml:"Transition,omitempty"`
+		Expiration                  *Expiration                  `xml:"Expiration,omitempty"`
+	}{r.ID, r.Filter, r.Status, r.NoncurrentVersionTransition, r.NoncurrentVersionExpiration, r.Transition, r.Expiration}, start)
+}
+
 // Create a lifecycle rule with arbitrary identifier id and object name prefix
 // for which the rules should apply.
 func NewLifecycleRule(id, prefix string) *LifecycleRule {
@@ -70,6 +106,19 @@ func NewLifecycleRule(id, prefix string) *LifecycleRule {
 	return rule
 }
 
+// Restricts the rule to objects that have all the given tags, in
+// addition to the rule prefix.  Overwrites any previous filter.
+func (r *LifecycleRule) SetTagFilter(tags ...Tag) {
+	switch {
+	case len(tags) == 0:
+		r.Filter = &LifecycleFilter{Prefix: r.Prefix}
+	case len(tags) == 1 && r.Prefix == "":
+		r.Filter = &LifecycleFilter{Tag: &tags[0]}
+	default:
+		r.Filter = &LifecycleFilter{And: &LifecycleFilterAnd{Prefix: r.Prefix, Tags: tags}}
+	}
+}
+
 // Adds a transition rule in days.  Overwrites any previous transition rule.
 func (r *LifecycleRule) SetTransitionDays(days uint) {
 	r.Transition = &Transition{
@@ -78,6 +127,15 @@ func (r *LifecycleRule) SetTransitionDays(days uint) {
 	}
 }
 
+// Adds a transition rule to storageClass, such as StorageClassDeepArchive,
+// in days.  Overwrites any previous transition rule.
+func (r *LifecycleRule) SetStorageClassTransitionDays(days uint, storageClass string) {
+	r.Transition = &Transition{
+		Days:         &days,
+		StorageClass: storageClass,
+	}
+}
+
 // Adds a transition rule as a date.  Overwrites any previous transition rule.
 func (r *LifecycleRule) SetTransitionDate(date time.Time) {
 	r.Transition = &Transition{
@@ -116,6 +116,68 @@ func (s *S) TestLifecycleConfiguration(c *check.C) {
 	s.checkLifecycleConfigurationEqual(c, conf, conf2)
 }
 
+func (s *S) TestLifecycleTagFilter(c *check.C) {
+	conf := &s3.LifecycleConfiguration{}
+
+	rule := s3.NewLifecycleRule("tag", "")
+	rule.SetTagFilter(s3.Tag{Key: "retention", Value: "30d"})
+	rule.SetExpirationDays(30)
+	conf.AddRule(rule)
+
+	rule = s3.NewLifecycleRule("prefix-and-tags", "logs/")
+	rule.SetTagFilter(s3.Tag{Key: "retention", Value: "1y"}, s3.Tag{Key: "team", Value: "ops"})
+	rule.SetExpirationDays(365)
+	conf.AddRule(rule)
+
+	doc, err := xml.MarshalIndent(conf, "", "  ")
+	c.Check(err, check.IsNil)
+
+	expectedDoc := `<LifecycleConfiguration>
+  <Rule>
+    <ID>tag</ID>
+    <Filter>
+      <Tag>
+        <Key>retention</Key>
+        <Value>30d</Value>
+      </Tag>
+    </Filter>
+    <Status>Enabled</Status>
+    <Expiration>
+      <Days>30</Days>
+    </Expiration>
+  </Rule>
+  <Rule>
+    <ID>prefix-and-tags</ID>
+    <Filter>
+      <And>
+        <Prefix>logs/</Prefix>
+        <Tag>
+          <Key>retention</Key>
+          <Value>1y</Value>
+        </Tag>
+        <Tag>
+          <Key>team</Key>
+          <Value>ops</Value>
+        </Tag>
+      </And>
+    </Filter>
+    <Status>Enabled</Status>
+    <Expiration>
+      <Days>365</Days>
+    </Expiration>
+  </Rule>
+</LifecycleConfiguration>`
+
+	c.Check(string(doc), check.Equals, expectedDoc)
+
+	conf2 := &s3.LifecycleConfiguration{}
+	err = xml.Unmarshal(doc, conf2)
+	c.Check(err, check.IsNil)
+	// Prefix is only sent inside the filter.
+	(*conf.Rules)[1].Prefix = ""
+	s.checkLifecycleConfigurationEqual(c, conf, conf2)
+}
+
 func (s *S) checkLifecycleConfigurationEqual(c *check.C, conf, conf2 *s3.LifecycleConfiguration) {
 	c.Check(len(*conf2.Rules), check.Equals, len(*conf.Rules))
 	for i, rule := range *conf2.Rules {
@@ -0,0 +1,290 @@
+package s3
+
+import (
+	"encoding/xml"
+	"strconv"
+)
+
+// ListV2Options holds the parameters of a ListV2 request.
+type ListV2Options struct {
+	// Prefix limits the response to keys that begin with it.
+	Prefix string
+
+	// Delimiter groups the keys that share a common prefix up to the
+	// next delimiter in a single entry of CommonPrefixes.
+	Delimiter string
+
+	// ContinuationToken is the NextContinuationToken of the previous
+	// page, if any.
+	ContinuationToken string
+
+	// StartAfter lists keys alphabetically greater than it. It is
+	// ignored once a ContinuationToken is given.
+	StartAfter string
+
+	// MaxKeys limits the number of keys and common prefixes returned.
+	// The default is 1000.
+	MaxKeys int
+
+	// FetchOwner requests the Owner of each key, which ListV2 does not
+	// return by default.
+	FetchOwner bool
+}
+
+// The ListV2Resp type holds the results of a ListV2 bucket operation.
+type ListV2Resp struct {
+	XMLName           xml.Name `xml:"ListBucketResult"`
+	Name              string
+	Prefix            string
+	Delimiter         string
+	StartAfter        string
+	ContinuationToken string
+	MaxKeys           int
+	KeyCount          int
+	IsTruncated       bool
+	Contents          []Key
+	CommonPrefixes    []string `xml:">Prefix"`
+	// if IsTruncated is true, pass NextContinuationToken as
+	// ContinuationToken to ListV2() to get the next set of keys
+	NextContinuationToken string
+}
+
+// ListV2 returns one page of information about objects in an S3 bucket,
+// using version 2 of the List Objects API.
+//
+// See https://docs.aws.amazon.com/AmazonS3/latest/API/API_ListObjectsV2.html
+// for details.
+func (b *Bucket) ListV2(options ListV2Options) (result *ListV2Resp, err error) {
+	params := map[string][]string{
+		"list-type": {"2"},
+		"prefix":    {options.Prefix},
+		"delimiter": {options.Delimiter},
+	}
+	if options.ContinuationToken != "" {
+		params["continuation-token"] = []string{options.ContinuationToken}
+	}
+	if options.StartAfter != "" {
+		params["start-after"] = []string{options.StartAfter}
+	}
+	if options.MaxKeys != 0 {
+		params["max-keys"] = []string{strconv.FormatInt(int64(options.MaxKeys), 10)}
+	}
+	if options.FetchOwner {
+		params["fetch-owner"] = []string{"true"}
+	}
+	req := &request{
+		bucket: b.Name,
+		params: params,
+	}
+	result = &ListV2Resp{}
+	for attempt := attempts.Start(); attempt.Next(); {
+		err = b.S3.query(req, result)
+		if !shouldRetry(err) {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// listV2Pages fetches the successive pages of a ListV2 listing.
+type listV2Pages struct {
+	b       *Bucket
+	options ListV2Options
+	done    bool
+	err     error
+}
+
+// next returns the next page, or nil once the listing is over or has
+// failed.
+func (p *listV2Pages) next() *ListV2Resp {
+	if p.done {
+		return nil
+	}
+	resp, err := p.b.ListV2(p.options)
+	if err != nil {
+		p.err = err
+		p.done = true
+		return nil
+	}
+	p.options.ContinuationToken = resp.NextContinuationToken
+	p.done = !resp.IsTruncated || resp.NextContinuationToken == ""
+	return resp
+}
+
+// ObjectIterator walks the keys of a bucket, fetching one page at a time
+// as needed. A typical loop is:
+//
+//	iter := b.ObjectIter(s3.ListV2Options{Prefix: "logs/"})
+//	for iter.Next() {
+//	    key := iter.Key()
+//	    ...
+//	}
+//	if err := iter.Err(); err != nil {
+//	    ...
+//	}
+type ObjectIterator struct {
+	pages listV2Pages
+	keys  []Key
+	key   Key
+}
+
+// ObjectIter returns an iterator over the keys selected by options.
+// Keys grouped under a common prefix by options.Delimiter are skipped;
+// use PrefixIter to walk the prefixes themselves.
+func (b *Bucket) ObjectIter(options ListV2Options) *ObjectIterator {
+	return &ObjectIterator{pages: listV2Pages{b: b, options: options}}
+}
+
+// Next advances the iterator to the next key, fetching a new page if
+// needed. It returns false when there are no more keys or an error
+// occurred.
+func (it *ObjectIterator) Next() bool {
+	for len(it.keys) == 0 {
+		page := it.pages.next()
+		if page == nil {
+			return false
+		}
+		it.keys = page.Contents
+	}
+	it.key = it.keys[0]
+	it.keys = it.keys[1:]
+	return true
+}
+
+// Key returns the key the iterator is positioned at.
+func (it *ObjectIterator) Key() Key {
+	return it.key
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *ObjectIterator) Err() error {
+	return it.pages.err
+}
+
+// PrefixIterator walks the common prefixes of a bucket listing, fetching
+// one page at a time as needed.
+type PrefixIterator struct {
+	pages    listV2Pages
+	prefixes []string
+	prefix   string
+}
+
+// PrefixIter returns an iterator over the common prefixes selected by
+// options, which should set a Delimiter.
+func (b *Bucket) PrefixIter(options ListV2Options) *PrefixIterator {
+	return &PrefixIterator{pages: listV2Pages{b: b, options: options}}
+}
+
+// Next advances the iterator to the next common prefix, fetching a new
+// page if needed. It returns false when there are no more prefixes or an
+// error occurred.
+func (it *PrefixIterator) Next() bool {
+	for len(it.prefixes) == 0 {
+		page := it.pages.next()
+		if page == nil {
+			return false
+		}
+		it.prefixes = page.CommonPrefixes
+	}
+	it.prefix = it.prefixes[0]
+	it.prefixes = it.prefixes[1:]
+	return true
+}
+
+// Prefix returns the common prefix the iterator is positioned at.
+func (it *PrefixIterator) Prefix() string {
+	return it.prefix
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *PrefixIterator) Err() error {
+	return it.pages.err
+}
+
+// VersionIterator walks the object versions and delete markers of a
+// bucket, fetching one page at a time as needed.
+type VersionIterator struct {
+	b                          *Bucket
+	prefix, delim              string
+	keyMarker, versionIdMarker string
+	max                        int
+	done                       bool
+	err                        error
+	versions                   []Version
+	version                    Version
+	prefixes                   []string
+}
+
+// VersionIter returns an iterator over the versions and delete markers of
+// the keys that begin with prefix, in the order S3 lists them: by key,
+// then newest first. Delete markers have IsDeleteMarker set. The delim
+// parameter has the same meaning as in Versions: the keys it groups
+// under a common prefix are skipped, and the prefixes are returned by
+// Prefixes instead. max is the page size (1000 if zero).
+func (b *Bucket) VersionIter(prefix, delim string, max int) *VersionIterator {
+	return &VersionIterator{b: b, prefix: prefix, delim: delim, max: max}
+}
+
+// Next advances the iterator to the next version or delete marker,
+// fetching a new page if needed. It returns false when there are no more
+// versions or an error occurred.
+func (it *VersionIterator) Next() bool {
+	for len(it.versions) == 0 {
+		if it.done {
+			return false
+		}
+		resp, err := it.b.Versions(it.prefix, it.delim, it.keyMarker, it.versionIdMarker, it.max)
+		if err != nil {
+			it.err = err
+			it.done = true
+			return false
+		}
+		it.versions = mergeVersions(resp.Versions, resp.DeleteMarkers)
+		it.prefixes = append(it.prefixes, resp.CommonPrefixes...)
+		it.keyMarker, it.versionIdMarker = resp.NextKeyMarker, resp.NextVersionIdMarker
+		it.done = !resp.IsTruncated || resp.NextKeyMarker == ""
+	}
+	it.version = it.versions[0]
+	it.versions = it.versions[1:]
+	return true
+}
+
+// mergeVersions returns the versions and delete markers of a page of a
+// Versions listing in the order S3 sent them, which XML decoding loses.
+func mergeVersions(versions, markers []Version) []Version {
+	merged := make([]Version, 0, len(versions)+len(markers))
+	for len(versions) > 0 || len(markers) > 0 {
+		if len(markers) == 0 || len(versions) > 0 &&
+			(versions[0].Key < markers[0].Key ||
+				versions[0].Key == markers[0].Key && versions[0].LastModified >= markers[0].LastModified) {
+			merged = append(merged, versions[0])
+			versions = versions[1:]
+		} else {
+			m := markers[0]
+			m.IsDeleteMarker = true
+			merged = append(merged, m)
+			markers = markers[1:]
+		}
+	}
+	return merged
+}
+
+// Version returns the version or delete marker the iterator is
+// positioned at.
+func (it *VersionIterator) Version() Version {
+	return it.version
+}
+
+// Prefixes returns the common prefixes of the pages fetched so far. Once
+// Next has returned false, these are all the prefixes of the listing.
+func (it *VersionIterator) Prefixes() []string {
+	return it.prefixes
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *VersionIterator) Err() error {
+	return it.err
+}
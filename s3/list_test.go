@@ -0,0 +1,112 @@
+package s3_test
+
+import (
+	"github.com/AdRoll/goamz/s3"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestListV2(c *check.C) {
+	testServer.Response(200, nil, ListV2ResultDump1)
+
+	b := s.s3.Bucket("quotes")
+
+	data, err := b.ListV2(s3.ListV2Options{Prefix: "N", StartAfter: "M", MaxKeys: 1, FetchOwner: true})
+	c.Assert(err, check.IsNil)
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Method, check.Equals, "GET")
+	c.Assert(req.URL.Path, check.Equals, "/quotes/")
+	c.Assert(req.Form["list-type"], check.DeepEquals, []string{"2"})
+	c.Assert(req.Form["prefix"], check.DeepEquals, []string{"N"})
+	c.Assert(req.Form["start-after"], check.DeepEquals, []string{"M"})
+	c.Assert(req.Form["max-keys"], check.DeepEquals, []string{"1"})
+	c.Assert(req.Form["fetch-owner"], check.DeepEquals, []string{"true"})
+	c.Assert(req.Form["continuation-token"], check.IsNil)
+
+	c.Assert(data.Name, check.Equals, "quotes")
+	c.Assert(data.StartAfter, check.Equals, "M")
+	c.Assert(data.KeyCount, check.Equals, 1)
+	c.Assert(data.IsTruncated, check.Equals, true)
+	c.Assert(data.NextContinuationToken, check.Equals, "1ueGcxLPRx1Tr/XYExHnhbYLgveDs2J/wm36Hy4vbOwM=")
+	c.Assert(data.Contents, check.HasLen, 1)
+	c.Assert(data.Contents[0].Key, check.Equals, "Nelson")
+	c.Assert(data.Contents[0].Owner.DisplayName, check.Equals, "webfile")
+}
+
+func (s *S) TestObjectIterFollowsContinuationToken(c *check.C) {
+	testServer.Response(200, nil, ListV2ResultDump1)
+	testServer.Response(200, nil, ListV2ResultDump2)
+
+	b := s.s3.Bucket("quotes")
+
+	var names []string
+	iter := b.ObjectIter(s3.ListV2Options{Prefix: "N", MaxKeys: 1})
+	for iter.Next() {
+		names = append(names, iter.Key().Key)
+	}
+	c.Assert(iter.Err(), check.IsNil)
+	c.Assert(names, check.DeepEquals, []string{"Nelson", "Neo"})
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Form["continuation-token"], check.IsNil)
+	req = testServer.WaitRequest()
+	c.Assert(req.Form["continuation-token"], check.DeepEquals, []string{"1ueGcxLPRx1Tr/XYExHnhbYLgveDs2J/wm36Hy4vbOwM="})
+}
+
+func (s *S) TestObjectIterError(c *check.C) {
+	s.DisableRetries()
+	testServer.Response(200, nil, ListV2ResultDump1)
+	testServer.Response(500, nil, InternalErrorDump)
+
+	b := s.s3.Bucket("quotes")
+
+	iter := b.ObjectIter(s3.ListV2Options{})
+	c.Assert(iter.Next(), check.Equals, true)
+	c.Assert(iter.Next(), check.Equals, false)
+	c.Assert(iter.Err(), check.ErrorMatches, "Not relevant")
+	c.Assert(iter.Next(), check.Equals, false)
+	testServer.WaitRequests(2)
+}
+
+func (s *S) TestVersionIter(c *check.C) {
+	testServer.Response(200, nil, ListVersionsResultDump1)
+	testServer.Response(200, nil, ListVersionsResultDump2)
+
+	b := s.s3.Bucket("bucket")
+
+	var ids []string
+	iter := b.VersionIter("my", "", 2)
+	for iter.Next() {
+		ids = append(ids, iter.Version().VersionId)
+	}
+	c.Assert(iter.Err(), check.IsNil)
+	c.Assert(ids, check.DeepEquals, []string{"3/L4kqtJl40Nr8X8gdRQBpUMLUo", "QUpfdndhfd8438MNFDN93jdnJFkdmqnh893"})
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Form["versions"], check.DeepEquals, []string{""})
+	c.Assert(req.Form["key-marker"], check.IsNil)
+	req = testServer.WaitRequest()
+	c.Assert(req.Form["key-marker"], check.DeepEquals, []string{"my-image.jpg"})
+	c.Assert(req.Form["version-id-marker"], check.DeepEquals, []string{"3/L4kqtJl40Nr8X8gdRQBpUMLUo"})
+}
+
+func (s *S) TestVersionIterDeleteMarkers(c *check.C) {
+	testServer.Response(200, nil, ListVersionsWithDeleteMarkersDump)
+
+	b := s.s3.Bucket("bucket")
+
+	var ids []string
+	var markers []bool
+	iter := b.VersionIter("", "/", 0)
+	for iter.Next() {
+		ids = append(ids, iter.Version().VersionId)
+		markers = append(markers, iter.Version().IsDeleteMarker)
+	}
+	c.Assert(iter.Err(), check.IsNil)
+	c.Assert(ids, check.DeepEquals, []string{"a3", "a2", "a1", "b1"})
+	c.Assert(markers, check.DeepEquals, []bool{true, false, true, false})
+	c.Assert(iter.Prefixes(), check.DeepEquals, []string{"photos/"})
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Form["delimiter"], check.DeepEquals, []string{"/"})
+}
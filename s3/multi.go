@@ -7,11 +7,14 @@ import (
 	"encoding/hex"
 	"encoding/xml"
 	"errors"
+	"fmt"
 	"io"
-	"net/url"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+
+	"github.com/AdRoll/goamz/s3/internal/pool"
 )
 
 // Multi represents an unfinished multipart upload.
@@ -25,6 +28,7 @@ type Multi struct {
 	Bucket   *Bucket
 	Key      string
 	UploadId string
+	kms      bool // The object is encrypted with KMS.
 }
 
 // That's the default. Here just for testing.
@@ -97,6 +101,7 @@ func (b *Bucket) Multi(key, contType string, perm ACL, options Options) (*Multi,
 	}
 	for _, m := range multis {
 		if m.Key == key {
+			m.kms = options.SSEKMS || options.SSEKMSKeyId != ""
 			return m, nil
 		}
 	}
@@ -137,12 +142,30 @@ func (b *Bucket) InitMulti(key string, contType string, perm ACL, options Option
 	if err != nil {
 		return nil, err
 	}
-	return &Multi{Bucket: b, Key: key, UploadId: resp.UploadId}, nil
+	return &Multi{Bucket: b, Key: key, UploadId: resp.UploadId, kms: usesKMS(headers)}, nil
 }
 
+// PutPartCopy copies part n of the multipart upload from source,
+// "bucket/key". The part is the range options.CopySourceOptions of the
+// source, "bytes=first-last", or else the whole source object, whose size
+// is first asked to S3 with a HEAD request.
 func (m *Multi) PutPartCopy(n int, options CopyOptions, source string) (*CopyObjectResult, Part, error) {
+	if size, ok := copyRangeSize(options.CopySourceOptions); ok {
+		return m.putPartCopy(n, options, source, size)
+	}
+	sourceBucket := m.Bucket.S3.Bucket(strings.TrimRight(strings.SplitAfterN(source, "/", 2)[0], "/"))
+	sourceMeta, err := headCopySource(sourceBucket, strings.SplitAfterN(source, "/", 2)[1], options)
+	if err != nil {
+		return nil, Part{}, err
+	}
+	return m.putPartCopy(n, options, source, sourceMeta.ContentLength)
+}
+
+// putPartCopy copies part n of the multipart upload from source, "bucket/key",
+// and reports size as the size of the part.
+func (m *Multi) putPartCopy(n int, options CopyOptions, source string, size int64) (*CopyObjectResult, Part, error) {
 	headers := map[string][]string{
-		"x-amz-copy-source": {url.QueryEscape(source)},
+		"x-amz-copy-source": {copySourceHeader(source, options.SourceVersionId)},
 	}
 	options.addHeaders(headers)
 	params := map[string][]string{
@@ -150,12 +173,6 @@ func (m *Multi) PutPartCopy(n int, options CopyOptions, source string) (*CopyObj
 		"partNumber": {strconv.FormatInt(int64(n), 10)},
 	}
 
-	sourceBucket := m.Bucket.S3.Bucket(strings.TrimRight(strings.SplitAfterN(source, "/", 2)[0], "/"))
-	sourceMeta, err := sourceBucket.Head(strings.SplitAfterN(source, "/", 2)[1], nil)
-	if err != nil {
-		return nil, Part{}, err
-	}
-
 	for attempt := attempts.Start(); attempt.Next(); {
 		req := &request{
 			method:  "PUT",
@@ -163,9 +180,10 @@ func (m *Multi) PutPartCopy(n int, options CopyOptions, source string) (*CopyObj
 			path:    m.Key,
 			headers: headers,
 			params:  params,
+			signV4:  m.kms || m.Bucket.KMSEncrypted,
 		}
 		resp := &CopyObjectResult{}
-		err = m.Bucket.S3.query(req, resp)
+		err := m.Bucket.S3.query(req, resp)
 		if shouldRetry(err) && attempt.HasNext() {
 			continue
 		}
@@ -175,7 +193,7 @@ func (m *Multi) PutPartCopy(n int, options CopyOptions, source string) (*CopyObj
 		if resp.ETag == "" {
 			return nil, Part{}, errors.New("part upload succeeded with no ETag")
 		}
-		return resp, Part{n, resp.ETag, sourceMeta.ContentLength}, nil
+		return resp, Part{n, resp.ETag, size}, nil
 	}
 	panic("unreachable")
 }
@@ -213,6 +231,7 @@ func (m *Multi) putPart(n int, r io.ReadSeeker, partSize int64, md5b64 string) (
 			headers: headers,
 			params:  params,
 			payload: r,
+			signV4:  m.kms || m.Bucket.KMSEncrypted,
 		}
 		err = m.Bucket.S3.prepare(req)
 		if err != nil {
@@ -378,6 +397,267 @@ NextSection:
 	return result, nil
 }
 
+const (
+	// MinPartSize is the minimum size of every part of a multipart
+	// upload but the last one.
+	MinPartSize = 5 * 1024 * 1024
+
+	// MaxParts is the maximum number of parts in a multipart upload.
+	MaxParts = 10000
+)
+
+// minPartSize is the smallest part size accepted by PutAllConcurrent and
+// PutStream. It is only lowered by the tests.
+var minPartSize int64 = MinPartSize
+
+// minPartSizeFor returns the smallest valid part size that fits an object
+// of size bytes in MaxParts parts.
+func minPartSizeFor(size int64) int64 {
+	if min := (size + MaxParts - 1) / MaxParts; min > minPartSize {
+		return min
+	}
+	return minPartSize
+}
+
+// PutAllOptions controls a PutAllConcurrent upload.
+type PutAllOptions struct {
+	// PartSize is the size of every part but the last one. It defaults
+	// to MinPartSize, or to the smallest size that fits the object in
+	// MaxParts parts. It may not be less than MinPartSize.
+	PartSize int64
+
+	// Concurrency is the number of parts sent in parallel. It defaults
+	// to 1. Parts are read straight from the io.ReaderAt, so memory use
+	// does not grow with Concurrency or PartSize.
+	Concurrency int
+
+	// Retries is the number of times a part is sent again after its
+	// upload failed, on top of the retries done for transient errors.
+	Retries int
+
+	// PartDone, if set, is called with each part once it is uploaded or
+	// reused, before Progress. An error fails the upload as if the part
+	// had failed. Calls are serialized.
+	PartDone func(Part) error
+
+	// Progress, if set, is called after each part is uploaded or
+	// reused. Calls are serialized.
+	Progress func(PutAllProgress)
+}
+
+// PutAllProgress reports the state of a PutAllConcurrent upload.
+type PutAllProgress struct {
+	Part       Part // The part that was just handled.
+	Reused     bool // Whether Part was already uploaded and was reused.
+	PartsDone  int
+	PartsTotal int
+	BytesDone  int64
+	BytesTotal int64
+}
+
+// PutAllConcurrent sends size bytes of r via a multipart upload, sending
+// up to options.Concurrency parts in parallel.
+// As with PutAll, parts previously uploaded are reused if their checksum
+// and size match the new part. A part that fails is retried on its own;
+// if it still fails, the remaining parts are cancelled, the multipart
+// upload is aborted and the error is returned.
+// PutAllConcurrent returns all the parts of m (reused or not) ordered by
+// part number.
+func (m *Multi) PutAllConcurrent(r io.ReaderAt, size int64, options PutAllOptions) ([]Part, error) {
+	partSize := options.PartSize
+	if partSize <= 0 {
+		partSize = minPartSizeFor(size)
+	} else if partSize < minPartSize {
+		return nil, fmt.Errorf("s3: part size of %d bytes is below the minimum of %d bytes", partSize, minPartSize)
+	}
+	if _, err := partCount(size, partSize); err != nil {
+		return nil, err
+	}
+	old, err := m.ListParts()
+	if err != nil && !hasCode(err, "NoSuchUpload") {
+		return nil, err
+	}
+	parts, err := m.putParts(r, size, partSize, old, nil, options)
+	if err != nil {
+		m.Abort()
+		return nil, err
+	}
+	return parts, nil
+}
+
+// partCount returns the number of parts of partSize bytes holding size
+// bytes, which is at least one.
+func partCount(size, partSize int64) (int, error) {
+	// Must send at least one empty part if the file is empty.
+	nparts := int((size + partSize - 1) / partSize)
+	if nparts == 0 {
+		nparts = 1
+	}
+	if nparts > MaxParts {
+		return 0, fmt.Errorf("s3: %d bytes in parts of %d bytes need %d parts; the maximum is %d", size, partSize, nparts, MaxParts)
+	}
+	return nparts, nil
+}
+
+// putParts sends size bytes of r in parts of partSize bytes, as
+// PutAllConcurrent does, reusing the parts of old with the same content.
+// The parts of done are taken as they are, without reading r, and
+// reported to options.Progress first. If a part fails, the remaining
+// ones are cancelled and the error is returned.
+func (m *Multi) putParts(r io.ReaderAt, size, partSize int64, old, done []Part, options PutAllOptions) ([]Part, error) {
+	nparts, err := partCount(size, partSize)
+	if err != nil {
+		return nil, err
+	}
+	uploaded := make(map[int]Part)
+	for _, p := range old {
+		uploaded[p.N] = p
+	}
+
+	var (
+		mu       sync.Mutex
+		result   = make([]Part, nparts)
+		progress = PutAllProgress{PartsTotal: nparts, BytesTotal: size}
+	)
+	report := func(part Part, reused bool) {
+		progress.Part = part
+		progress.Reused = reused
+		progress.PartsDone++
+		progress.BytesDone += part.Size
+		if options.Progress != nil {
+			options.Progress(progress)
+		}
+	}
+	for _, p := range done {
+		result[p.N-1] = p
+		report(p, true)
+	}
+
+	g := pool.New(options.Concurrency)
+	for n := 1; n <= nparts; n++ {
+		if result[n-1].N != 0 {
+			continue
+		}
+		n := n
+		ok := g.Go(func() error {
+			offset := int64(n-1) * partSize
+			length := partSize
+			if offset+length > size {
+				length = size - offset
+			}
+			section := io.NewSectionReader(r, offset, length)
+			part, reused, err := m.putSection(n, section, uploaded[n], options.Retries)
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if options.PartDone != nil {
+				if err := options.PartDone(part); err != nil {
+					return err
+				}
+			}
+			result[n-1] = part
+			report(part, reused)
+			return nil
+		})
+		if !ok {
+			break
+		}
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// putSection sends section as part n, unless old already holds the same
+// content. The part is sent again up to retries times if it fails.
+func (m *Multi) putSection(n int, section *io.SectionReader, old Part, retries int) (part Part, reused bool, err error) {
+	_, md5hex, md5b64, err := seekerInfo(section)
+	if err != nil {
+		return Part{}, false, err
+	}
+	if old.N == n && old.Size == section.Size() && old.ETag == `"`+md5hex+`"` {
+		return old, true, nil
+	}
+	for try := 0; ; try++ {
+		part, err = m.putPart(n, section, section.Size(), md5b64)
+		if err == nil || try >= retries {
+			return part, false, err
+		}
+	}
+}
+
+// PutStream inserts an object into the S3 bucket by consuming data from r
+// until EOF, without knowing its length in advance. Data is buffered one
+// part of partSize bytes (MinPartSize if zero) at a time. If r holds no
+// more than one part the object is sent with a single PUT; otherwise a
+// multipart upload is used and completed once r is exhausted. Every
+// request carries the MD5 of its content, so options.ContentMD5 is
+// ignored. If any part fails, the multipart upload is aborted and the
+// error is returned.
+//
+// As the length of r is unknown, the part size cannot be chosen to fit
+// it: r may hold at most MaxParts parts, about 48.8 GiB with the default
+// part size. Callers streaming more must pass a larger partSize.
+func (b *Bucket) PutStream(path string, r io.Reader, partSize int64, contType string, perm ACL, options Options) error {
+	if partSize <= 0 {
+		partSize = MinPartSize
+	} else if partSize < minPartSize {
+		return fmt.Errorf("s3: part size of %d bytes is below the minimum of %d bytes", partSize, minPartSize)
+	}
+	// One byte past the part tells whether another part follows.
+	buf := make([]byte, partSize+1)
+	n, err := io.ReadFull(r, buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		digest := md5.Sum(buf[:n])
+		options.ContentMD5 = base64.StdEncoding.EncodeToString(digest[:])
+		return b.PutReader(path, bytes.NewReader(buf[:n]), int64(n), contType, perm, options)
+	}
+	if err != nil {
+		return err
+	}
+
+	options.ContentMD5 = ""
+	m, err := b.InitMulti(path, contType, perm, options)
+	if err != nil {
+		return err
+	}
+	var parts []Part
+	for n > 0 {
+		if len(parts) == MaxParts {
+			m.Abort()
+			return fmt.Errorf("s3: stream does not fit in %d parts of %d bytes", MaxParts, partSize)
+		}
+		size := n
+		if int64(size) > partSize {
+			size = int(partSize)
+		}
+		digest := md5.Sum(buf[:size])
+		part, err := m.putPart(len(parts)+1, bytes.NewReader(buf[:size]), int64(size), base64.StdEncoding.EncodeToString(digest[:]))
+		if err != nil {
+			m.Abort()
+			return err
+		}
+		parts = append(parts, part)
+
+		// Keep the bytes read past the part and fill up the buffer.
+		kept := copy(buf, buf[size:n])
+		n, err = io.ReadFull(r, buf[kept:])
+		n += kept
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			m.Abort()
+			return err
+		}
+	}
+	if err := m.Complete(parts); err != nil {
+		m.Abort()
+		return err
+	}
+	return nil
+}
+
 type completeUpload struct {
 	XMLName xml.Name      `xml:"CompleteMultipartUpload"`
 	Parts   completeParts `xml:"Part"`
@@ -399,6 +679,13 @@ func (p completeParts) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
 //
 // See http://goo.gl/2Z7Tw for details.
 func (m *Multi) Complete(parts []Part) error {
+	_, err := m.complete(parts)
+	return err
+}
+
+// complete completes the upload like Complete and returns the ETag and
+// version of the new object.
+func (m *Multi) complete(parts []Part) (*CopyObjectResult, error) {
 	params := map[string][]string{
 		"uploadId": {m.UploadId},
 	}
@@ -409,7 +696,7 @@ func (m *Multi) Complete(parts []Part) error {
 	sort.Sort(c.Parts)
 	data, err := xml.Marshal(&c)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	for attempt := attempts.Start(); attempt.Next(); {
 		req := &request{
@@ -418,12 +705,27 @@ func (m *Multi) Complete(parts []Part) error {
 			path:    m.Key,
 			params:  params,
 			payload: bytes.NewReader(data),
+			signV4:  m.kms || m.Bucket.KMSEncrypted,
+		}
+		if err := m.Bucket.S3.prepare(req); err != nil {
+			return nil, err
+		}
+		resp := &CopyObjectResult{}
+		hresp, err := m.Bucket.S3.run(req, nil)
+		if hresp != nil {
+			// Only the ETag is read from the body, which may be empty.
+			xml.NewDecoder(hresp.Body).Decode(resp)
+			resp.VersionId = hresp.Header.Get("x-amz-version-id")
+			resp.Encryption = ResponseEncryption(hresp)
+			hresp.Body.Close()
 		}
-		err := m.Bucket.S3.query(req, nil)
 		if shouldRetry(err) && attempt.HasNext() {
 			continue
 		}
-		return err
+		if err != nil {
+			return nil, err
+		}
+		return resp, nil
 	}
 	panic("unreachable")
 }
@@ -6,6 +6,8 @@ import (
 	"gopkg.in/check.v1"
 	"io"
 	"io/ioutil"
+	"path/filepath"
+	"strconv"
 	"strings"
 )
 
@@ -192,6 +194,55 @@ func (s *S) TestPutPartCopy(c *check.C) {
 	c.Assert(req.Header["X-Amz-Copy-Source"], check.DeepEquals, []string{`source-bucket%2F%C3%BCber-fil%C3%A9.jpg`})
 }
 
+func (s *S) TestPutPartCopyRange(c *check.C) {
+	testServer.Response(200, nil, InitMultiResultDump)
+	testServer.Response(200, nil, PutCopyResultDump)
+
+	b := s.s3.Bucket("sample")
+	multi, err := b.InitMulti("multi", "text/plain", s3.Private, s3.Options{})
+	c.Assert(err, check.IsNil)
+
+	// The size of a range is known without a HEAD request.
+	_, part, err := multi.PutPartCopy(1, s3.CopyOptions{CopySourceOptions: "bytes=100-199"}, "source-bucket/source")
+	c.Assert(err, check.IsNil)
+	c.Assert(part.Size, check.Equals, int64(100))
+
+	testServer.WaitRequest()
+	req := testServer.WaitRequest()
+	c.Assert(req.Method, check.Equals, "PUT")
+	c.Assert(req.Header["X-Amz-Copy-Source-Range"], check.DeepEquals, []string{"bytes=100-199"})
+}
+
+func (s *S) TestPutPartCopySourceVersion(c *check.C) {
+	testServer.Response(200, nil, InitMultiResultDump)
+	testServer.Response(200, nil, "content")
+	testServer.Response(200, nil, PutCopyResultDump)
+
+	b := s.s3.Bucket("sample")
+	multi, err := b.InitMulti("multi", "text/plain", s3.Private, s3.Options{})
+	c.Assert(err, check.IsNil)
+
+	options := s3.CopyOptions{
+		SourceVersionId:                "v1",
+		CopySourceSSECustomerAlgorithm: "AES256",
+		CopySourceSSECustomerKey:       "key",
+		CopySourceSSECustomerKeyMD5:    "md5",
+	}
+	_, part, err := multi.PutPartCopy(1, options, "source-bucket/source")
+	c.Assert(err, check.IsNil)
+	c.Assert(part.Size, check.Equals, int64(7))
+
+	// The HEAD request asks for the version copied, with its key.
+	testServer.WaitRequest()
+	req := testServer.WaitRequest()
+	c.Assert(req.Method, check.Equals, "HEAD")
+	c.Assert(req.Form.Get("versionId"), check.Equals, "v1")
+	c.Assert(req.Header.Get("x-amz-server-side-encryption-customer-algorithm"), check.Equals, "AES256")
+	c.Assert(req.Header.Get("x-amz-server-side-encryption-customer-key"), check.Equals, "key")
+	req = testServer.WaitRequest()
+	c.Assert(req.Header["X-Amz-Copy-Source"], check.DeepEquals, []string{"source-bucket%2Fsource?versionId=v1"})
+}
+
 func readAll(r io.Reader) string {
 	data, err := ioutil.ReadAll(r)
 	if err != nil {
@@ -341,6 +392,253 @@ func (s *S) TestPutAllResume(c *check.C) {
 	c.Assert(readAll(req.Body), check.Equals, "partX")
 }
 
+func (s *S) TestPutAllConcurrent(c *check.C) {
+	s3.SetMinPartSize(5)
+	// Don't retry the NoSuchUpload error.
+	s.DisableRetries()
+
+	testServer.Response(200, nil, InitMultiResultDump)
+	testServer.Response(404, nil, NoSuchUploadErrorDump)
+	testServer.Responses(3, 200, map[string]string{"ETag": `"etag"`}, "")
+
+	b := s.s3.Bucket("sample")
+
+	multi, err := b.InitMulti("multi", "text/plain", s3.Private, s3.Options{})
+	c.Assert(err, check.IsNil)
+
+	var (
+		done     []s3.Part
+		progress []s3.PutAllProgress
+	)
+	options := s3.PutAllOptions{
+		PartSize:    5,
+		Concurrency: 3,
+		PartDone: func(p s3.Part) error {
+			done = append(done, p)
+			return nil
+		},
+		Progress: func(p s3.PutAllProgress) { progress = append(progress, p) },
+	}
+	parts, err := multi.PutAllConcurrent(strings.NewReader("part1part2last"), 14, options)
+	c.Assert(err, check.IsNil)
+	c.Assert(parts, check.DeepEquals, []s3.Part{{1, `"etag"`, 5}, {2, `"etag"`, 5}, {3, `"etag"`, 4}})
+
+	c.Assert(progress, check.HasLen, 3)
+	c.Assert(done, check.HasLen, 3)
+	for i, p := range progress {
+		c.Assert(p.Part, check.Equals, done[i])
+	}
+	last := progress[2]
+	c.Assert(last.PartsDone, check.Equals, 3)
+	c.Assert(last.PartsTotal, check.Equals, 3)
+	c.Assert(last.BytesDone, check.Equals, int64(14))
+	c.Assert(last.BytesTotal, check.Equals, int64(14))
+
+	// Init and list old parts.
+	testServer.WaitRequest()
+	req := testServer.WaitRequest()
+	c.Assert(req.Method, check.Equals, "GET")
+
+	// Parts may arrive in any order.
+	sent := map[string]string{}
+	for _, req := range testServer.WaitRequests(3) {
+		c.Assert(req.Method, check.Equals, "PUT")
+		c.Assert(req.URL.Path, check.Equals, "/sample/multi")
+		sent[req.Form.Get("partNumber")] = readAll(req.Body)
+	}
+	c.Assert(sent, check.DeepEquals, map[string]string{"1": "part1", "2": "part2", "3": "last"})
+}
+
+func (s *S) TestPutAllConcurrentResume(c *check.C) {
+	s3.SetMinPartSize(5)
+	testServer.Response(200, nil, InitMultiResultDump)
+	testServer.Response(200, nil, ListPartsResultDump1)
+	testServer.Response(200, nil, ListPartsResultDump2)
+	testServer.Response(200, map[string]string{"ETag": `"etag2"`}, "")
+
+	b := s.s3.Bucket("sample")
+
+	multi, err := b.InitMulti("multi", "text/plain", s3.Private, s3.Options{})
+	c.Assert(err, check.IsNil)
+
+	reused := 0
+	options := s3.PutAllOptions{
+		PartSize:    5,
+		Concurrency: 2,
+		Progress: func(p s3.PutAllProgress) {
+			if p.Reused {
+				reused++
+			}
+		},
+	}
+	// "part1" and "part3" match the checksums in ResultDump1.
+	parts, err := multi.PutAllConcurrent(strings.NewReader("part1partXpart3"), 15, options)
+	c.Assert(err, check.IsNil)
+	c.Assert(parts, check.HasLen, 3)
+	c.Assert(parts[0].ETag, check.Equals, `"ffc88b4ca90a355f8ddba6b2c3b2af5c"`)
+	c.Assert(parts[1].ETag, check.Equals, `"etag2"`)
+	c.Assert(parts[2].ETag, check.Equals, `"49dcd91231f801159e893fb5c6674985"`)
+	c.Assert(reused, check.Equals, 2)
+
+	testServer.WaitRequests(3)
+	req := testServer.WaitRequest()
+	c.Assert(req.Method, check.Equals, "PUT")
+	c.Assert(req.Form["partNumber"], check.DeepEquals, []string{"2"})
+	c.Assert(readAll(req.Body), check.Equals, "partX")
+}
+
+func (s *S) TestPutAllConcurrentAbortsOnError(c *check.C) {
+	s3.SetMinPartSize(5)
+	s.DisableRetries()
+
+	accessDenied := `<Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>`
+	testServer.Response(200, nil, InitMultiResultDump)
+	testServer.Response(404, nil, NoSuchUploadErrorDump)
+	testServer.Response(403, nil, accessDenied)
+	testServer.Response(403, nil, accessDenied)
+	testServer.Response(204, nil, "")
+
+	b := s.s3.Bucket("sample")
+
+	multi, err := b.InitMulti("multi", "text/plain", s3.Private, s3.Options{})
+	c.Assert(err, check.IsNil)
+
+	options := s3.PutAllOptions{PartSize: 5, Retries: 1}
+	parts, err := multi.PutAllConcurrent(strings.NewReader("part1part2"), 10, options)
+	c.Assert(parts, check.IsNil)
+	c.Assert(err, check.ErrorMatches, "Access Denied")
+
+	testServer.WaitRequests(2)
+	// Part 1 is sent twice, then the upload is aborted.
+	for i := 0; i < 2; i++ {
+		req := testServer.WaitRequest()
+		c.Assert(req.Method, check.Equals, "PUT")
+		c.Assert(req.Form["partNumber"], check.DeepEquals, []string{"1"})
+	}
+	req := testServer.WaitRequest()
+	c.Assert(req.Method, check.Equals, "DELETE")
+	c.Assert(req.Form.Get("uploadId"), check.Matches, "JNbR_[A-Za-z0-9.]+QQ--")
+}
+
+func (s *S) TestPutAllConcurrentPartSize(c *check.C) {
+	b := s.s3.Bucket("sample")
+	multi := &s3.Multi{Bucket: b, Key: "multi", UploadId: "id"}
+
+	options := s3.PutAllOptions{PartSize: s3.MinPartSize - 1}
+	_, err := multi.PutAllConcurrent(strings.NewReader("data"), 4, options)
+	c.Assert(err, check.ErrorMatches, `s3: part size of 5242879 bytes is below the minimum of 5242880 bytes`)
+}
+
+func (s *S) TestPutStreamSmall(c *check.C) {
+	s3.SetMinPartSize(5)
+	testServer.Response(200, nil, "")
+
+	b := s.s3.Bucket("sample")
+
+	err := b.PutStream("name", strings.NewReader("tiny"), 5, "text/plain", s3.Private, s3.Options{})
+	c.Assert(err, check.IsNil)
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Method, check.Equals, "PUT")
+	c.Assert(req.URL.Path, check.Equals, "/sample/name")
+	c.Assert(req.Form["uploadId"], check.IsNil)
+	c.Assert(req.Header["Content-Md5"], check.DeepEquals, []string{"1gyt8aQcZR4fCt5QE2utQw=="})
+	c.Assert(readAll(req.Body), check.Equals, "tiny")
+}
+
+func (s *S) TestPutStreamExactPart(c *check.C) {
+	s3.SetMinPartSize(5)
+	testServer.Response(200, nil, "")
+
+	b := s.s3.Bucket("sample")
+
+	err := b.PutStream("name", strings.NewReader("part1"), 5, "text/plain", s3.Private, s3.Options{})
+	c.Assert(err, check.IsNil)
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Method, check.Equals, "PUT")
+	c.Assert(req.Form["uploadId"], check.IsNil)
+	c.Assert(readAll(req.Body), check.Equals, "part1")
+}
+
+func (s *S) TestPutStreamMultipart(c *check.C) {
+	s3.SetMinPartSize(5)
+	testServer.Response(200, nil, InitMultiResultDump)
+	testServer.Response(200, map[string]string{"ETag": `"etag1"`}, "")
+	testServer.Response(200, map[string]string{"ETag": `"etag2"`}, "")
+	testServer.Response(200, map[string]string{"ETag": `"etag3"`}, "")
+	testServer.Response(200, nil, "")
+
+	b := s.s3.Bucket("sample")
+
+	// A reader of unknown length, such as a pipe.
+	pr, pw := io.Pipe()
+	go func() {
+		pw.Write([]byte("part1part2"))
+		pw.Write([]byte("last"))
+		pw.Close()
+	}()
+	// The MD5 of the whole object does not apply to the upload.
+	err := b.PutStream("multi", pr, 5, "text/plain", s3.Private, s3.Options{ContentMD5: "bogus"})
+	c.Assert(err, check.IsNil)
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Method, check.Equals, "POST")
+	c.Assert(req.Form["uploads"], check.DeepEquals, []string{""})
+	c.Assert(req.Header["Content-Md5"], check.IsNil)
+
+	for i, body := range []string{"part1", "part2", "last"} {
+		req = testServer.WaitRequest()
+		c.Assert(req.Method, check.Equals, "PUT")
+		c.Assert(req.Form.Get("partNumber"), check.Equals, strconv.Itoa(i+1))
+		c.Assert(req.Header["Content-Md5"], check.HasLen, 1)
+		c.Assert(readAll(req.Body), check.Equals, body)
+	}
+
+	req = testServer.WaitRequest()
+	c.Assert(req.Method, check.Equals, "POST")
+	c.Assert(req.Form.Get("uploadId"), check.Matches, "JNbR_[A-Za-z0-9.]+QQ--")
+	body := readAll(req.Body)
+	c.Assert(strings.Count(body, "<Part>"), check.Equals, 3)
+}
+
+func (s *S) TestPutStreamAbortsOnError(c *check.C) {
+	s3.SetMinPartSize(5)
+	testServer.Response(200, nil, InitMultiResultDump)
+	testServer.Response(403, nil, `<Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>`)
+	testServer.Response(204, nil, "")
+
+	b := s.s3.Bucket("sample")
+
+	err := b.PutStream("multi", strings.NewReader("part1part2"), 5, "text/plain", s3.Private, s3.Options{})
+	c.Assert(err, check.ErrorMatches, "Access Denied")
+
+	testServer.WaitRequests(2)
+	req := testServer.WaitRequest()
+	c.Assert(req.Method, check.Equals, "DELETE")
+	c.Assert(req.Form.Get("uploadId"), check.Matches, "JNbR_[A-Za-z0-9.]+QQ--")
+}
+
+func (s *S) TestPutStreamRejectsSmallParts(c *check.C) {
+	b := s.s3.Bucket("sample")
+
+	err := b.PutStream("name", strings.NewReader("data"), s3.MinPartSize-1, "text/plain", s3.Private, s3.Options{})
+	c.Assert(err, check.ErrorMatches, `s3: part size of 5242879 bytes is below the minimum of 5242880 bytes`)
+}
+
+func (s *S) TestPutResumableRejectsBadPartSizes(c *check.C) {
+	b := s.s3.Bucket("sample")
+	store := s3.NewFileCheckpointStore(filepath.Join(c.MkDir(), "upload.json"))
+
+	// Neither starts an upload.
+	options := s3.ResumableOptions{PartSize: s3.MinPartSize - 1}
+	err := b.PutResumable("name", strings.NewReader("data"), 4, s3.Private, store, options)
+	c.Assert(err, check.ErrorMatches, `s3: part size of 5242879 bytes is below the minimum of 5242880 bytes`)
+	options.PartSize = s3.MinPartSize
+	err = b.PutResumable("name", strings.NewReader("data"), s3.MinPartSize*(s3.MaxParts+1), s3.Private, store, options)
+	c.Assert(err, check.ErrorMatches, `s3: .* bytes in parts of 5242880 bytes need 10001 parts; the maximum is 10000`)
+}
+
 func (s *S) TestMultiComplete(c *check.C) {
 	testServer.Response(200, nil, InitMultiResultDump)
 	// Note the 200 response. Completing will hold the connection on some
@@ -0,0 +1,196 @@
+package s3
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"net/url"
+	"time"
+)
+
+// Event types that can trigger a bucket notification.
+//
+// See https://docs.aws.amazon.com/AmazonS3/latest/dev/NotificationHowTo.html
+// for details.
+const (
+	ObjectCreatedAll                     = "s3:ObjectCreated:*"
+	ObjectCreatedPut                     = "s3:ObjectCreated:Put"
+	ObjectCreatedPost                    = "s3:ObjectCreated:Post"
+	ObjectCreatedCopy                    = "s3:ObjectCreated:Copy"
+	ObjectCreatedCompleteMultipartUpload = "s3:ObjectCreated:CompleteMultipartUpload"
+	ObjectRemovedAll                     = "s3:ObjectRemoved:*"
+	ObjectRemovedDelete                  = "s3:ObjectRemoved:Delete"
+	ObjectRemovedDeleteMarkerCreated     = "s3:ObjectRemoved:DeleteMarkerCreated"
+	ObjectRestorePost                    = "s3:ObjectRestore:Post"
+	ObjectRestoreCompleted               = "s3:ObjectRestore:Completed"
+	ReducedRedundancyLostObject          = "s3:ReducedRedundancyLostObject"
+)
+
+// NotificationConfiguration holds the event notification targets of a
+// bucket. An empty configuration turns notifications off.
+type NotificationConfiguration struct {
+	XMLName                      xml.Name                      `xml:"NotificationConfiguration"`
+	TopicConfigurations          []TopicConfiguration          `xml:"TopicConfiguration"`
+	QueueConfigurations          []QueueConfiguration          `xml:"QueueConfiguration"`
+	LambdaFunctionConfigurations []LambdaFunctionConfiguration `xml:"CloudFunctionConfiguration"`
+}
+
+// TopicConfiguration publishes the given events to an SNS topic.
+type TopicConfiguration struct {
+	Id       string              `xml:"Id,omitempty"`
+	Filter   *NotificationFilter `xml:"Filter,omitempty"`
+	TopicArn string              `xml:"Topic"`
+	Events   []string            `xml:"Event"`
+}
+
+// QueueConfiguration sends the given events to an SQS queue.
+type QueueConfiguration struct {
+	Id       string              `xml:"Id,omitempty"`
+	Filter   *NotificationFilter `xml:"Filter,omitempty"`
+	QueueArn string              `xml:"Queue"`
+	Events   []string            `xml:"Event"`
+}
+
+// LambdaFunctionConfiguration invokes a Lambda function on the given
+// events.
+type LambdaFunctionConfiguration struct {
+	Id                string              `xml:"Id,omitempty"`
+	Filter            *NotificationFilter `xml:"Filter,omitempty"`
+	LambdaFunctionArn string              `xml:"CloudFunction"`
+	Events            []string            `xml:"Event"`
+}
+
+// NotificationFilter restricts a notification to the keys matching its
+// rules.
+type NotificationFilter struct {
+	FilterRules []FilterRule `xml:"S3Key>FilterRule"`
+}
+
+// FilterRule matches keys by "prefix" or "suffix".
+type FilterRule struct {
+	Name  string
+	Value string
+}
+
+// NewKeyFilter returns a filter matching the keys that begin with prefix
+// and end with suffix. Either may be empty.
+func NewKeyFilter(prefix, suffix string) *NotificationFilter {
+	f := &NotificationFilter{}
+	if prefix != "" {
+		f.FilterRules = append(f.FilterRules, FilterRule{"prefix", prefix})
+	}
+	if suffix != "" {
+		f.FilterRules = append(f.FilterRules, FilterRule{"suffix", suffix})
+	}
+	return f
+}
+
+// GetBucketNotification returns the event notification configuration of
+// the bucket.
+func (b *Bucket) GetBucketNotification() (*NotificationConfiguration, error) {
+	conf := &NotificationConfiguration{}
+	if err := b.getSubresourceXML("/", "notification", conf); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+// PutBucketNotification replaces the event notification configuration
+// of the bucket. S3 checks that it may publish to every target, sending
+// an s3:TestEvent to each of them.
+//
+// See https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketNotificationConfiguration.html
+// for details.
+func (b *Bucket) PutBucketNotification(conf NotificationConfiguration) error {
+	return b.putSubresourceXML("/", "notification", conf)
+}
+
+// Event is the message S3 sends to notification targets.
+type Event struct {
+	Records []EventRecord
+}
+
+// EventRecord describes a single event on an object.
+//
+// See https://docs.aws.amazon.com/AmazonS3/latest/dev/notification-content-structure.html
+// for details.
+type EventRecord struct {
+	EventVersion      string            `json:"eventVersion"`
+	EventSource       string            `json:"eventSource"`
+	AwsRegion         string            `json:"awsRegion"`
+	EventTime         time.Time         `json:"eventTime"`
+	EventName         string            `json:"eventName"`
+	UserIdentity      EventIdentity     `json:"userIdentity"`
+	RequestParameters EventRequest      `json:"requestParameters"`
+	ResponseElements  map[string]string `json:"responseElements"`
+	S3                EventS3           `json:"s3"`
+}
+
+// EventIdentity identifies the principal behind an event.
+type EventIdentity struct {
+	PrincipalId string `json:"principalId"`
+}
+
+// EventRequest holds the parameters of the request that caused an
+// event.
+type EventRequest struct {
+	SourceIPAddress string `json:"sourceIPAddress"`
+}
+
+// EventS3 names the bucket and object an event is about.
+type EventS3 struct {
+	SchemaVersion   string      `json:"s3SchemaVersion"`
+	ConfigurationId string      `json:"configurationId"`
+	Bucket          EventBucket `json:"bucket"`
+	Object          EventObject `json:"object"`
+}
+
+// EventBucket describes the bucket of an event.
+type EventBucket struct {
+	Name          string        `json:"name"`
+	OwnerIdentity EventIdentity `json:"ownerIdentity"`
+	Arn           string        `json:"arn"`
+}
+
+// EventObject describes the object of an event. Key is URL-encoded as
+// sent by S3; see DecodedKey.
+type EventObject struct {
+	Key       string `json:"key"`
+	Size      int64  `json:"size"`
+	ETag      string `json:"eTag"`
+	VersionId string `json:"versionId"`
+	Sequencer string `json:"sequencer"`
+}
+
+// DecodedKey returns the object key with its URL encoding removed.
+func (o EventObject) DecodedKey() (string, error) {
+	return url.QueryUnescape(o.Key)
+}
+
+var errNotEvent = errors.New("s3: message is not an S3 event")
+
+// ParseEvent decodes an S3 event message. The message may be the event
+// JSON itself, as delivered to SQS queues and Lambda functions, or an SNS
+// notification wrapping it, as delivered to HTTP endpoints or to SQS
+// queues subscribed to a topic. The s3:TestEvent sent when notifications
+// are configured yields an Event with no records.
+func ParseEvent(data []byte) (*Event, error) {
+	var msg struct {
+		Records []EventRecord
+		Type    string // "Notification" for SNS.
+		Message *string
+		Event   string // "s3:TestEvent" for test events.
+	}
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, err
+	}
+	switch {
+	case msg.Records != nil:
+		return &Event{Records: msg.Records}, nil
+	case msg.Type == "Notification" && msg.Message != nil:
+		return ParseEvent([]byte(*msg.Message))
+	case msg.Event == "s3:TestEvent":
+		return &Event{}, nil
+	}
+	return nil, errNotEvent
+}
@@ -0,0 +1,104 @@
+package s3_test
+
+import (
+	"encoding/json"
+	"github.com/AdRoll/goamz/s3"
+	"gopkg.in/check.v1"
+	"time"
+)
+
+func (s *S) TestPutBucketNotification(c *check.C) {
+	testServer.Response(200, nil, "")
+
+	b := s.s3.Bucket("bucket")
+	err := b.PutBucketNotification(s3.NotificationConfiguration{
+		QueueConfigurations: []s3.QueueConfiguration{{
+			Id:       "images",
+			Filter:   s3.NewKeyFilter("images/", ".jpg"),
+			QueueArn: "arn:aws:sqs:us-east-1:123456789012:images",
+			Events:   []string{s3.ObjectCreatedAll},
+		}},
+		LambdaFunctionConfigurations: []s3.LambdaFunctionConfiguration{{
+			LambdaFunctionArn: "arn:aws:lambda:us-east-1:123456789012:function:cleanup",
+			Events:            []string{s3.ObjectRemovedDelete, s3.ObjectRemovedDeleteMarkerCreated},
+		}},
+	})
+	c.Assert(err, check.IsNil)
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Method, check.Equals, "PUT")
+	c.Assert(req.URL.RawQuery, check.Equals, "notification=")
+	c.Assert(readAll(req.Body), check.Equals, `<?xml version="1.0" encoding="UTF-8"?>
+<NotificationConfiguration>`+
+		`<QueueConfiguration><Id>images</Id><Filter><S3Key>`+
+		`<FilterRule><Name>prefix</Name><Value>images/</Value></FilterRule>`+
+		`<FilterRule><Name>suffix</Name><Value>.jpg</Value></FilterRule>`+
+		`</S3Key></Filter><Queue>arn:aws:sqs:us-east-1:123456789012:images</Queue><Event>s3:ObjectCreated:*</Event></QueueConfiguration>`+
+		`<CloudFunctionConfiguration><CloudFunction>arn:aws:lambda:us-east-1:123456789012:function:cleanup</CloudFunction>`+
+		`<Event>s3:ObjectRemoved:Delete</Event><Event>s3:ObjectRemoved:DeleteMarkerCreated</Event></CloudFunctionConfiguration>`+
+		`</NotificationConfiguration>`)
+}
+
+var eventJSON = `{"Records":[{
+  "eventVersion":"2.0",
+  "eventSource":"aws:s3",
+  "awsRegion":"us-east-1",
+  "eventTime":"2016-09-20T14:23:12.345Z",
+  "eventName":"ObjectCreated:Put",
+  "userIdentity":{"principalId":"AWS:AIDAJDPLRKLG7UEXAMPLE"},
+  "requestParameters":{"sourceIPAddress":"127.0.0.1"},
+  "responseElements":{"x-amz-request-id":"C3D13FE58DE4C810","x-amz-id-2":"FMyUVURIY8/IgAtTv8xRjskZQpcIZ9KG4V5Wp6S7S/JRWeUWerMUE5JgHvANOjpD"},
+  "s3":{
+    "s3SchemaVersion":"1.0",
+    "configurationId":"images",
+    "bucket":{"name":"photos","ownerIdentity":{"principalId":"A3NL1KOZZKExample"},"arn":"arn:aws:s3:::photos"},
+    "object":{"key":"images/summer+holiday%2C+2016.jpg","size":1024,"eTag":"d41d8cd98f00b204e9800998ecf8427e","versionId":"096fKKXTRTtl3on89fVO.nfljtsv6qko","sequencer":"0055AED6DCD90281E5"}
+  }
+}]}`
+
+func (s *S) checkEvent(c *check.C, event *s3.Event) {
+	c.Assert(event.Records, check.HasLen, 1)
+	r := event.Records[0]
+	c.Assert(r.EventName, check.Equals, "ObjectCreated:Put")
+	c.Assert(r.EventTime.Equal(time.Date(2016, 9, 20, 14, 23, 12, 345e6, time.UTC)), check.Equals, true)
+	c.Assert(r.ResponseElements["x-amz-request-id"], check.Equals, "C3D13FE58DE4C810")
+	c.Assert(r.S3.ConfigurationId, check.Equals, "images")
+	c.Assert(r.S3.Bucket.Name, check.Equals, "photos")
+	c.Assert(r.S3.Object.Size, check.Equals, int64(1024))
+	c.Assert(r.S3.Object.VersionId, check.Equals, "096fKKXTRTtl3on89fVO.nfljtsv6qko")
+	key, err := r.S3.Object.DecodedKey()
+	c.Assert(err, check.IsNil)
+	c.Assert(key, check.Equals, "images/summer holiday, 2016.jpg")
+}
+
+func (s *S) TestParseEvent(c *check.C) {
+	event, err := s3.ParseEvent([]byte(eventJSON))
+	c.Assert(err, check.IsNil)
+	s.checkEvent(c, event)
+}
+
+func (s *S) TestParseEventInSNSNotification(c *check.C) {
+	envelope, err := json.Marshal(map[string]string{
+		"Type":      "Notification",
+		"MessageId": "22b80b92-fdea-4c2c-8f9d-bdfb0c7bf324",
+		"TopicArn":  "arn:aws:sns:us-east-1:123456789012:uploads",
+		"Subject":   "Amazon S3 Notification",
+		"Message":   eventJSON,
+	})
+	c.Assert(err, check.IsNil)
+
+	event, err := s3.ParseEvent(envelope)
+	c.Assert(err, check.IsNil)
+	s.checkEvent(c, event)
+}
+
+func (s *S) TestParseTestEvent(c *check.C) {
+	event, err := s3.ParseEvent([]byte(`{"Service":"Amazon S3","Event":"s3:TestEvent","Time":"2016-09-20T14:23:12.345Z","Bucket":"photos","RequestId":"5582815E1AEA5ADF","HostId":"8cLeGAmw098X5cv4Zkwcmo8vvZa3eH3eKxsPzbB9wrR+YstdA6Knx4Ip8EXAMPLE"}`))
+	c.Assert(err, check.IsNil)
+	c.Assert(event.Records, check.HasLen, 0)
+}
+
+func (s *S) TestParseEventRejectsOtherMessages(c *check.C) {
+	_, err := s3.ParseEvent([]byte(`{"hello":"world"}`))
+	c.Assert(err, check.ErrorMatches, "s3: message is not an S3 event")
+}
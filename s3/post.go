@@ -0,0 +1,142 @@
+package s3
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AdRoll/goamz/aws"
+)
+
+// PostPolicy holds the conditions a browser-based POST upload must meet.
+// Create one with NewPostPolicy, add conditions with its methods and
+// pass it to Bucket.PostFormArgsV4.
+//
+// See https://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-HTTPPOSTConstructPolicy.html
+// for details.
+type PostPolicy struct {
+	expiration time.Time
+	conditions []interface{}
+	fields     map[string]string
+	hasKey     bool
+}
+
+// NewPostPolicy returns an empty policy that expires at expiration.
+func NewPostPolicy(expiration time.Time) *PostPolicy {
+	return &PostPolicy{expiration: expiration, fields: make(map[string]string)}
+}
+
+// Equals requires the form field to be value, and sets it in the form.
+func (p *PostPolicy) Equals(field, value string) {
+	p.conditions = append(p.conditions, []string{"eq", "$" + field, value})
+	p.fields[field] = value
+	if field == "key" {
+		p.hasKey = true
+	}
+}
+
+// StartsWith requires the form field to begin with prefix. The form
+// must then be given the field by the browser.
+func (p *PostPolicy) StartsWith(field, prefix string) {
+	p.conditions = append(p.conditions, []string{"starts-with", "$" + field, prefix})
+	if field == "key" {
+		p.hasKey = true
+	}
+}
+
+// ContentLengthRange limits the size of the uploaded file to between min
+// and max bytes.
+func (p *PostPolicy) ContentLengthRange(min, max int64) {
+	p.conditions = append(p.conditions, []interface{}{"content-length-range", min, max})
+}
+
+// SetKey stores the upload at key. A key ending in "${filename}" has
+// that replaced by the name of the uploaded file.
+func (p *PostPolicy) SetKey(key string) {
+	if strings.HasSuffix(key, "${filename}") {
+		p.StartsWith("key", strings.TrimSuffix(key, "${filename}"))
+		p.fields["key"] = key
+	} else {
+		p.Equals("key", key)
+	}
+}
+
+// SetContentType sets the content type of the upload.
+func (p *PostPolicy) SetContentType(contType string) {
+	p.Equals("Content-Type", contType)
+}
+
+// SetACL sets the canned ACL of the upload.
+func (p *PostPolicy) SetACL(perm ACL) {
+	p.Equals("acl", string(perm))
+}
+
+// SetMeta sets the user metadata name of the upload.
+func (p *PostPolicy) SetMeta(name, value string) {
+	p.Equals("x-amz-meta-"+name, value)
+}
+
+// SetSuccessActionStatus sets the status S3 responds with after a
+// successful upload: 200, 201 or, by default, 204.
+func (p *PostPolicy) SetSuccessActionStatus(status int) {
+	p.Equals("success_action_status", strconv.Itoa(status))
+}
+
+// SetSuccessActionRedirect makes S3 redirect the browser to url after a
+// successful upload.
+func (p *PostPolicy) SetSuccessActionRedirect(url string) {
+	p.Equals("success_action_redirect", url)
+}
+
+const postPolicyExpirationFormat = "2006-01-02T15:04:05.000Z"
+
+// PostFormArgsV4 returns the action and input fields of an HTML form
+// allowing browsers to upload to the bucket as policy permits, signed
+// with signature version 4. The policy must set a key condition.
+//
+// See https://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-UsingHTTPPOST.html
+// for details.
+func (b *Bucket) PostFormArgsV4(policy *PostPolicy) (action string, fields map[string]string, err error) {
+	if !policy.hasKey {
+		return "", nil, errors.New("s3: POST policy has no key condition")
+	}
+	t := time.Now().UTC()
+	signer := aws.NewV4Signer(b.Auth, "s3", b.Region)
+
+	fields = make(map[string]string, len(policy.fields)+6)
+	for k, v := range policy.fields {
+		fields[k] = v
+	}
+	fields["x-amz-algorithm"] = "AWS4-HMAC-SHA256"
+	fields["x-amz-credential"] = signer.Credential(t)
+	fields["x-amz-date"] = t.Format(aws.ISO8601BasicFormat)
+	if token := b.Auth.Token(); token != "" {
+		fields["x-amz-security-token"] = token
+	}
+
+	conditions := append([]interface{}{}, policy.conditions...)
+	conditions = append(conditions, map[string]string{"bucket": b.Name})
+	for _, k := range []string{"x-amz-algorithm", "x-amz-credential", "x-amz-date", "x-amz-security-token"} {
+		if v, ok := fields[k]; ok {
+			conditions = append(conditions, map[string]string{k: v})
+		}
+	}
+	doc, err := json.Marshal(map[string]interface{}{
+		"expiration": policy.expiration.UTC().Format(postPolicyExpirationFormat),
+		"conditions": conditions,
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	fields["policy"] = base64.StdEncoding.EncodeToString(doc)
+	fields["x-amz-signature"] = signer.SignPolicy(t, fields["policy"])
+
+	action, err = b.S3.bucketURL(b.Name)
+	if err != nil {
+		return "", nil, err
+	}
+	return action, fields, nil
+}
@@ -0,0 +1,166 @@
+package s3_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"time"
+
+	"github.com/AdRoll/goamz/aws"
+	"github.com/AdRoll/goamz/s3"
+	"github.com/AdRoll/goamz/s3/s3test"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestPostFormArgsV4(c *check.C) {
+	b := s.s3.Bucket("bucket")
+	expiration := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	policy := s3.NewPostPolicy(expiration)
+	policy.SetKey("uploads/${filename}")
+	policy.SetContentType("image/png")
+	policy.SetMeta("uuid", "14365123651274")
+	policy.SetSuccessActionStatus(201)
+	policy.ContentLengthRange(1, 1048576)
+
+	action, fields, err := b.PostFormArgsV4(policy)
+	c.Assert(err, check.IsNil)
+	c.Assert(action, check.Equals, testServer.URL+"/bucket/")
+
+	c.Assert(fields["key"], check.Equals, "uploads/${filename}")
+	c.Assert(fields["Content-Type"], check.Equals, "image/png")
+	c.Assert(fields["x-amz-meta-uuid"], check.Equals, "14365123651274")
+	c.Assert(fields["success_action_status"], check.Equals, "201")
+	c.Assert(fields["x-amz-algorithm"], check.Equals, "AWS4-HMAC-SHA256")
+	t, err := time.Parse(aws.ISO8601BasicFormat, fields["x-amz-date"])
+	c.Assert(err, check.IsNil)
+	c.Assert(fields["x-amz-credential"], check.Equals, "abc/"+t.Format(aws.ISO8601BasicFormatShort)+"/faux-region-1/s3/aws4_request")
+
+	signer := aws.NewV4Signer(s.s3.Auth, "s3", s.s3.Region)
+	c.Assert(fields["x-amz-signature"], check.Equals, signer.SignPolicy(t, fields["policy"]))
+
+	doc, err := base64.StdEncoding.DecodeString(fields["policy"])
+	c.Assert(err, check.IsNil)
+	var decoded struct {
+		Expiration string
+		Conditions []interface{}
+	}
+	c.Assert(json.Unmarshal(doc, &decoded), check.IsNil)
+	c.Assert(decoded.Expiration, check.Equals, "2030-01-02T03:04:05.000Z")
+	c.Assert(decoded.Conditions, check.DeepEquals, []interface{}{
+		[]interface{}{"starts-with", "$key", "uploads/"},
+		[]interface{}{"eq", "$Content-Type", "image/png"},
+		[]interface{}{"eq", "$x-amz-meta-uuid", "14365123651274"},
+		[]interface{}{"eq", "$success_action_status", "201"},
+		[]interface{}{"content-length-range", 1.0, 1048576.0},
+		map[string]interface{}{"bucket": "bucket"},
+		map[string]interface{}{"x-amz-algorithm": "AWS4-HMAC-SHA256"},
+		map[string]interface{}{"x-amz-credential": fields["x-amz-credential"]},
+		map[string]interface{}{"x-amz-date": fields["x-amz-date"]},
+	})
+}
+
+func (s *S) TestPostFormArgsV4NoKey(c *check.C) {
+	b := s.s3.Bucket("bucket")
+	_, _, err := b.PostFormArgsV4(s3.NewPostPolicy(time.Now().Add(time.Hour)))
+	c.Assert(err, check.ErrorMatches, "s3: POST policy has no key condition")
+}
+
+// postForm uploads data as a browser would with the given form.
+func postForm(c *check.C, action string, fields map[string]string, filename string, data []byte) *http.Response {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for k, v := range fields {
+		c.Assert(w.WriteField(k, v), check.IsNil)
+	}
+	fw, err := w.CreateFormFile("file", filename)
+	c.Assert(err, check.IsNil)
+	fw.Write(data)
+	c.Assert(w.Close(), check.IsNil)
+	resp, err := http.Post(action, w.FormDataContentType(), &body)
+	c.Assert(err, check.IsNil)
+	return resp
+}
+
+func checkPostError(c *check.C, resp *http.Response, status int, code string) {
+	defer resp.Body.Close()
+	c.Assert(resp.StatusCode, check.Equals, status)
+	var e s3.Error
+	c.Assert(xml.NewDecoder(resp.Body).Decode(&e), check.IsNil)
+	c.Assert(e.Code, check.Equals, code)
+}
+
+func (s *S) TestPostFormUpload(c *check.C) {
+	srv, err := s3test.NewServer(&s3test.Config{SecretKey: "123"})
+	c.Assert(err, check.IsNil)
+	defer srv.Quit()
+	region := aws.Region{Name: "faux-region-1", S3Endpoint: srv.URL(), S3LocationConstraint: true}
+	b := s3.New(s.s3.Auth, region).Bucket("bucket")
+	c.Assert(b.PutBucket(s3.Private), check.IsNil)
+
+	policy := s3.NewPostPolicy(time.Now().Add(time.Hour))
+	policy.SetKey("uploads/${filename}")
+	policy.SetContentType("image/png")
+	policy.SetMeta("uuid", "1234")
+	policy.SetSuccessActionStatus(201)
+	policy.ContentLengthRange(1, 10)
+	action, fields, err := b.PostFormArgsV4(policy)
+	c.Assert(err, check.IsNil)
+
+	resp := postForm(c, action, fields, "pic.png", []byte("png data"))
+	c.Assert(resp.StatusCode, check.Equals, 201)
+	var result struct {
+		Bucket string
+		Key    string
+		ETag   string
+	}
+	c.Assert(xml.NewDecoder(resp.Body).Decode(&result), check.IsNil)
+	resp.Body.Close()
+	c.Assert(result.Bucket, check.Equals, "bucket")
+	c.Assert(result.Key, check.Equals, "uploads/pic.png")
+
+	get, err := b.GetResponse("uploads/pic.png")
+	c.Assert(err, check.IsNil)
+	data, err := ioutil.ReadAll(get.Body)
+	get.Body.Close()
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, "png data")
+	c.Assert(get.Header.Get("Content-Type"), check.Equals, "image/png")
+	c.Assert(get.Header.Get("x-amz-meta-uuid"), check.Equals, "1234")
+
+	resp = postForm(c, action, fields, "big.png", []byte("more than ten bytes"))
+	checkPostError(c, resp, 400, "EntityTooLarge")
+
+	fields["x-amz-meta-uuid"] = "5678"
+	resp = postForm(c, action, fields, "pic.png", []byte("png data"))
+	checkPostError(c, resp, 403, "AccessDenied")
+	fields["x-amz-meta-uuid"] = "1234"
+
+	fields["x-amz-meta-extra"] = "1"
+	resp = postForm(c, action, fields, "pic.png", []byte("png data"))
+	checkPostError(c, resp, 403, "AccessDenied")
+	delete(fields, "x-amz-meta-extra")
+
+	// Extra fields are allowed if they begin with x-ignore-.
+	fields["x-ignore-extra"] = "1"
+	resp = postForm(c, action, fields, "pic.png", []byte("png data"))
+	resp.Body.Close()
+	c.Assert(resp.StatusCode, check.Equals, 201)
+	delete(fields, "x-ignore-extra")
+
+	forged := s3.New(aws.Auth{AccessKey: "abc", SecretKey: "456"}, region).Bucket("bucket")
+	action, fields, err = forged.PostFormArgsV4(policy)
+	c.Assert(err, check.IsNil)
+	resp = postForm(c, action, fields, "pic.png", []byte("png data"))
+	checkPostError(c, resp, 403, "SignatureDoesNotMatch")
+
+	expired := s3.NewPostPolicy(time.Now().Add(-time.Minute))
+	expired.SetKey("uploads/${filename}")
+	action, fields, err = b.PostFormArgsV4(expired)
+	c.Assert(err, check.IsNil)
+	resp = postForm(c, action, fields, "pic.png", []byte("png data"))
+	checkPostError(c, resp, 403, "AccessDenied")
+}
@@ -0,0 +1,93 @@
+package s3
+
+import (
+	"encoding/xml"
+	"net/http"
+)
+
+// Replication rule and delete marker replication states.
+const (
+	ReplicationEnabled  = "Enabled"
+	ReplicationDisabled = "Disabled"
+)
+
+// Replication states of an object, as returned by ResponseReplicationStatus.
+// Objects in the source bucket are PENDING, then COMPLETED or FAILED;
+// their copies in the destination bucket are REPLICA.
+const (
+	ReplicationStatusPending   = "PENDING"
+	ReplicationStatusCompleted = "COMPLETED"
+	ReplicationStatusFailed    = "FAILED"
+	ReplicationStatusReplica   = "REPLICA"
+)
+
+// ReplicationConfiguration holds the rules copying the objects of a
+// bucket to other buckets, using the IAM role Role. Both the source and
+// destination buckets must have versioning enabled.
+//
+// See https://docs.aws.amazon.com/AmazonS3/latest/userguide/replication-add-config.html
+// for details.
+type ReplicationConfiguration struct {
+	XMLName xml.Name          `xml:"ReplicationConfiguration"`
+	Role    string            `xml:"Role"`
+	Rules   []ReplicationRule `xml:"Rule"`
+}
+
+// ReplicationRule replicates the objects matching Filter to Destination.
+// An empty Filter matches every object. When rules overlap, the one with
+// the highest Priority wins.
+type ReplicationRule struct {
+	ID                      string                   `xml:"ID,omitempty"`
+	Priority                int                      `xml:"Priority,omitempty"`
+	Filter                  *LifecycleFilter         `xml:"Filter"`
+	Status                  string                   `xml:"Status"`
+	Destination             ReplicationDestination   `xml:"Destination"`
+	DeleteMarkerReplication *DeleteMarkerReplication `xml:"DeleteMarkerReplication,omitempty"`
+}
+
+// ReplicationDestination names the bucket, by ARN, objects are replicated
+// to. StorageClass, if set, overrides the storage class of the copies.
+type ReplicationDestination struct {
+	Bucket       string `xml:"Bucket"`
+	Account      string `xml:"Account,omitempty"`
+	StorageClass string `xml:"StorageClass,omitempty"`
+}
+
+// DeleteMarkerReplication tells whether the delete markers added to the
+// source bucket are replicated too. S3 requires it on rules with a Filter.
+type DeleteMarkerReplication struct {
+	Status string
+}
+
+// GetBucketReplication returns the replication configuration of the
+// bucket. S3 returns a ReplicationConfigurationNotFoundError error if
+// there is none.
+func (b *Bucket) GetBucketReplication() (*ReplicationConfiguration, error) {
+	conf := &ReplicationConfiguration{}
+	if err := b.getSubresourceXML("/", "replication", conf); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+// PutBucketReplication replaces the replication configuration of the
+// bucket.
+//
+// See https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketReplication.html
+// for details.
+func (b *Bucket) PutBucketReplication(conf ReplicationConfiguration) error {
+	return b.putSubresourceXML("/", "replication", conf)
+}
+
+// DeleteBucketReplication removes the replication configuration of the
+// bucket.
+func (b *Bucket) DeleteBucketReplication() error {
+	return b.DelBucketSubresource("replication")
+}
+
+// ResponseReplicationStatus returns the replication state of an object,
+// one of the ReplicationStatus constants, from the response to a Head or
+// Get request on it. It is empty for objects no rule applies to.
+func ResponseReplicationStatus(resp *http.Response) string {
+	return resp.Header.Get("x-amz-replication-status")
+}
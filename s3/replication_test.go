@@ -0,0 +1,101 @@
+package s3_test
+
+import (
+	"net/http"
+
+	"github.com/AdRoll/goamz/s3"
+	"gopkg.in/check.v1"
+)
+
+var GetReplicationDump = `<?xml version="1.0" encoding="UTF-8"?>
+<ReplicationConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <Role>arn:aws:iam::123456789012:role/replication</Role>
+  <Rule>
+    <ID>critical</ID>
+    <Priority>1</Priority>
+    <Filter>
+      <And>
+        <Prefix>critical/</Prefix>
+        <Tag><Key>replicate</Key><Value>yes</Value></Tag>
+      </And>
+    </Filter>
+    <Status>Enabled</Status>
+    <Destination>
+      <Bucket>arn:aws:s3:::backup</Bucket>
+      <StorageClass>STANDARD_IA</StorageClass>
+    </Destination>
+    <DeleteMarkerReplication><Status>Disabled</Status></DeleteMarkerReplication>
+  </Rule>
+</ReplicationConfiguration>`
+
+func (s *S) TestGetBucketReplication(c *check.C) {
+	testServer.Response(200, nil, GetReplicationDump)
+
+	b := s.s3.Bucket("bucket")
+	conf, err := b.GetBucketReplication()
+	c.Assert(err, check.IsNil)
+	c.Assert(conf.Role, check.Equals, "arn:aws:iam::123456789012:role/replication")
+	c.Assert(conf.Rules, check.DeepEquals, []s3.ReplicationRule{{
+		ID:       "critical",
+		Priority: 1,
+		Filter: &s3.LifecycleFilter{And: &s3.LifecycleFilterAnd{
+			Prefix: "critical/",
+			Tags:   []s3.Tag{{Key: "replicate", Value: "yes"}},
+		}},
+		Status: s3.ReplicationEnabled,
+		Destination: s3.ReplicationDestination{
+			Bucket:       "arn:aws:s3:::backup",
+			StorageClass: "STANDARD_IA",
+		},
+		DeleteMarkerReplication: &s3.DeleteMarkerReplication{Status: s3.ReplicationDisabled},
+	}})
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Method, check.Equals, "GET")
+	c.Assert(req.URL.Path, check.Equals, "/bucket/")
+	c.Assert(req.URL.RawQuery, check.Equals, "replication=")
+}
+
+func (s *S) TestPutBucketReplication(c *check.C) {
+	testServer.Response(200, nil, "")
+
+	b := s.s3.Bucket("bucket")
+	err := b.PutBucketReplication(s3.ReplicationConfiguration{
+		Role: "arn:aws:iam::123456789012:role/replication",
+		Rules: []s3.ReplicationRule{{
+			Filter:                  &s3.LifecycleFilter{Prefix: "logs/"},
+			Status:                  s3.ReplicationEnabled,
+			Destination:             s3.ReplicationDestination{Bucket: "arn:aws:s3:::backup"},
+			DeleteMarkerReplication: &s3.DeleteMarkerReplication{Status: s3.ReplicationEnabled},
+		}},
+	})
+	c.Assert(err, check.IsNil)
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Method, check.Equals, "PUT")
+	c.Assert(req.URL.RawQuery, check.Equals, "replication=")
+	c.Assert(req.Header.Get("Content-MD5"), check.Not(check.Equals), "")
+	c.Assert(readAll(req.Body), check.Equals, `<?xml version="1.0" encoding="UTF-8"?>
+<ReplicationConfiguration><Role>arn:aws:iam::123456789012:role/replication</Role><Rule><Filter><Prefix>logs/</Prefix></Filter><Status>Enabled</Status><Destination><Bucket>arn:aws:s3:::backup</Bucket></Destination><DeleteMarkerReplication><Status>Enabled</Status></DeleteMarkerReplication></Rule></ReplicationConfiguration>`)
+}
+
+func (s *S) TestDeleteBucketReplication(c *check.C) {
+	testServer.Response(204, nil, "")
+
+	b := s.s3.Bucket("bucket")
+	c.Assert(b.DeleteBucketReplication(), check.IsNil)
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Method, check.Equals, "DELETE")
+	c.Assert(req.URL.RawQuery, check.Equals, "replication=")
+}
+
+func (s *S) TestResponseReplicationStatus(c *check.C) {
+	testServer.Response(200, map[string]string{"x-amz-replication-status": "PENDING"}, "")
+
+	b := s.s3.Bucket("bucket")
+	resp, err := b.Head("name", nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(s3.ResponseReplicationStatus(resp), check.Equals, s3.ReplicationStatusPending)
+	c.Assert(s3.ResponseReplicationStatus(&http.Response{Header: http.Header{}}), check.Equals, "")
+}
@@ -0,0 +1,108 @@
+package s3
+
+import (
+	"bytes"
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Retrieval tiers of an archived object restore, from the fastest to the
+// cheapest.
+const (
+	RestoreTierExpedited = "Expedited"
+	RestoreTierStandard  = "Standard"
+	RestoreTierBulk      = "Bulk"
+)
+
+// RestoreRequest asks S3 to make a temporary copy of an archived object
+// readable for Days days.
+type RestoreRequest struct {
+	XMLName              xml.Name              `xml:"RestoreRequest"`
+	Days                 int                   `xml:"Days"`
+	GlacierJobParameters *GlacierJobParameters `xml:"GlacierJobParameters,omitempty"`
+}
+
+// GlacierJobParameters selects the retrieval tier of a restore.
+type GlacierJobParameters struct {
+	Tier string
+}
+
+// RestoreObject starts restoring the object at path from the GLACIER or
+// DEEP_ARCHIVE storage class, keeping the restored copy for days days.
+// An empty tier uses RestoreTierStandard. Restoring an object already
+// restored only extends its expiry; S3 returns a RestoreAlreadyInProgress
+// error while a restore is ongoing. Follow its progress with
+// ResponseRestore on Head responses.
+//
+// See https://docs.aws.amazon.com/AmazonS3/latest/API/API_RestoreObject.html
+// for details.
+func (b *Bucket) RestoreObject(path string, days int, tier string) error {
+	restore := RestoreRequest{Days: days}
+	if tier != "" {
+		restore.GlacierJobParameters = &GlacierJobParameters{Tier: tier}
+	}
+	doc, err := xml.Marshal(restore)
+	if err != nil {
+		return err
+	}
+	req := &request{
+		method:  "POST",
+		bucket:  b.Name,
+		path:    path,
+		headers: map[string][]string{"Content-Length": {strconv.Itoa(len(doc))}},
+		params:  url.Values{"restore": {""}},
+		payload: bytes.NewReader(doc),
+	}
+	return b.S3.query(req, nil)
+}
+
+// RestoreStatus is the state of the restore of an archived object, as
+// given by the x-amz-restore header.
+type RestoreStatus struct {
+	// Ongoing is true until the restored copy is readable.
+	Ongoing bool
+
+	// ExpiryDate is when the restored copy will be removed. It is zero
+	// while the restore is ongoing.
+	ExpiryDate time.Time
+}
+
+// ResponseRestore extracts the restore status from the response to a
+// Head or Get request on an object. It returns nil if no restore was
+// requested.
+func ResponseRestore(resp *http.Response) *RestoreStatus {
+	h := resp.Header.Get("x-amz-restore")
+	if h == "" {
+		return nil
+	}
+	status := &RestoreStatus{}
+	// The header looks like:
+	// ongoing-request="false", expiry-date="Fri, 21 Dec 2012 00:00:00 GMT"
+	for h != "" {
+		i := strings.Index(h, `="`)
+		if i < 0 {
+			break
+		}
+		name := strings.TrimLeft(h[:i], ", ")
+		h = h[i+2:]
+		j := strings.Index(h, `"`)
+		if j < 0 {
+			break
+		}
+		value := h[:j]
+		h = h[j+1:]
+		switch name {
+		case "ongoing-request":
+			status.Ongoing = value == "true"
+		case "expiry-date":
+			if t, err := time.Parse(http.TimeFormat, value); err == nil {
+				status.ExpiryDate = t
+			}
+		}
+	}
+	return status
+}
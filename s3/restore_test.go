@@ -0,0 +1,108 @@
+package s3_test
+
+import (
+	"encoding/xml"
+	"net/http"
+	"time"
+
+	"github.com/AdRoll/goamz/aws"
+	"github.com/AdRoll/goamz/s3"
+	"github.com/AdRoll/goamz/s3/s3test"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestRestoreObject(c *check.C) {
+	testServer.Response(202, nil, "")
+
+	b := s.s3.Bucket("bucket")
+	err := b.RestoreObject("name", 7, s3.RestoreTierBulk)
+	c.Assert(err, check.IsNil)
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Method, check.Equals, "POST")
+	c.Assert(req.URL.Path, check.Equals, "/bucket/name")
+	c.Assert(req.URL.RawQuery, check.Equals, "restore=")
+	c.Assert(readAll(req.Body), check.Equals, `<RestoreRequest><Days>7</Days><GlacierJobParameters><Tier>Bulk</Tier></GlacierJobParameters></RestoreRequest>`)
+}
+
+func (s *S) TestAcceptedOnlyAnswersRestore(c *check.C) {
+	s.DisableRetries()
+	testServer.Response(202, nil, "")
+
+	b := s.s3.Bucket("bucket")
+	err := b.Put("name", []byte("content"), "text/plain", s3.Private, s3.Options{})
+	c.Assert(err, check.NotNil)
+	c.Assert(err.(*s3.Error).StatusCode, check.Equals, 202)
+	testServer.WaitRequest()
+}
+
+func (s *S) TestResponseRestore(c *check.C) {
+	resp := &http.Response{Header: http.Header{}}
+	c.Assert(s3.ResponseRestore(resp), check.IsNil)
+
+	resp.Header.Set("x-amz-restore", `ongoing-request="true"`)
+	c.Assert(s3.ResponseRestore(resp), check.DeepEquals, &s3.RestoreStatus{Ongoing: true})
+
+	resp.Header.Set("x-amz-restore", `ongoing-request="false", expiry-date="Fri, 21 Dec 2012 00:00:00 GMT"`)
+	c.Assert(s3.ResponseRestore(resp), check.DeepEquals, &s3.RestoreStatus{
+		ExpiryDate: time.Date(2012, 12, 21, 0, 0, 0, 0, time.UTC),
+	})
+}
+
+func (s *S) TestLifecycleDeepArchiveTransition(c *check.C) {
+	rule := s3.NewLifecycleRule("archive", "logs/")
+	rule.SetStorageClassTransitionDays(90, s3.StorageClassDeepArchive)
+
+	doc, err := xml.Marshal(rule.Transition)
+	c.Assert(err, check.IsNil)
+	c.Assert(string(doc), check.Equals, `<Transition><Days>90</Days><StorageClass>DEEP_ARCHIVE</StorageClass></Transition>`)
+}
+
+func (s *S) TestRestoreArchivedObject(c *check.C) {
+	delay := 200 * time.Millisecond
+	srv, err := s3test.NewServer(&s3test.Config{RestoreDelay: delay})
+	c.Assert(err, check.IsNil)
+	defer srv.Quit()
+	region := aws.Region{Name: "faux-region-1", S3Endpoint: srv.URL(), S3LocationConstraint: true}
+	b := s3.New(s.s3.Auth, region).Bucket("bucket")
+	c.Assert(b.PutBucket(s3.Private), check.IsNil)
+
+	err = b.Put("cold", []byte("data"), "text/plain", s3.Private, s3.Options{StorageClass: s3.GlacierStorage})
+	c.Assert(err, check.IsNil)
+
+	_, err = b.Get("cold")
+	c.Assert(err, check.NotNil)
+	c.Assert(err.(*s3.Error).Code, check.Equals, "InvalidObjectState")
+
+	resp, err := b.Head("cold", nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(resp.Header.Get("x-amz-storage-class"), check.Equals, "GLACIER")
+	c.Assert(s3.ResponseRestore(resp), check.IsNil)
+
+	c.Assert(b.RestoreObject("cold", 2, s3.RestoreTierExpedited), check.IsNil)
+	resp, err = b.Head("cold", nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(s3.ResponseRestore(resp), check.DeepEquals, &s3.RestoreStatus{Ongoing: true})
+
+	err = b.RestoreObject("cold", 2, "")
+	c.Assert(err, check.NotNil)
+	c.Assert(err.(*s3.Error).Code, check.Equals, "RestoreAlreadyInProgress")
+
+	time.Sleep(delay)
+	data, err := b.Get("cold")
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, "data")
+
+	resp, err = b.Head("cold", nil)
+	c.Assert(err, check.IsNil)
+	status := s3.ResponseRestore(resp)
+	c.Assert(status, check.NotNil)
+	c.Assert(status.Ongoing, check.Equals, false)
+	c.Assert(status.ExpiryDate.After(time.Now().Add(47*time.Hour)), check.Equals, true)
+
+	// Standard objects need no restore and cannot be restored.
+	c.Assert(b.Put("hot", []byte("data"), "text/plain", s3.Private, s3.Options{}), check.IsNil)
+	err = b.RestoreObject("hot", 1, "")
+	c.Assert(err, check.NotNil)
+	c.Assert(err.(*s3.Error).Code, check.Equals, "InvalidObjectState")
+}
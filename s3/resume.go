@@ -0,0 +1,230 @@
+package s3
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Checkpoint records the progress of a resumable upload: the multipart
+// upload holding it and the parts already sent.
+type Checkpoint struct {
+	Bucket   string
+	Key      string
+	UploadId string
+	Size     int64 // The size of the object.
+	PartSize int64
+	Parts    []Part // The parts sent, in the order they completed.
+}
+
+// CheckpointStore keeps the Checkpoint of a resumable upload between
+// runs of PutResumable.
+type CheckpointStore interface {
+	// Load returns the saved checkpoint, or nil if there is none.
+	Load() (*Checkpoint, error)
+
+	// Save replaces the saved checkpoint with cp.
+	Save(cp *Checkpoint) error
+
+	// Delete removes the saved checkpoint, once the upload is complete.
+	Delete() error
+}
+
+// FileCheckpointStore is a CheckpointStore saving the checkpoint as JSON
+// in the file at Path. The file is replaced only once fully written, so a
+// crash while saving leaves the previous checkpoint in place.
+type FileCheckpointStore struct {
+	Path string
+}
+
+// NewFileCheckpointStore returns a FileCheckpointStore saving the
+// checkpoint in the file at path.
+func NewFileCheckpointStore(path string) *FileCheckpointStore {
+	return &FileCheckpointStore{Path: path}
+}
+
+func (s *FileCheckpointStore) Load() (*Checkpoint, error) {
+	data, err := ioutil.ReadFile(s.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	cp := &Checkpoint{}
+	if err := json.Unmarshal(data, cp); err != nil {
+		return nil, fmt.Errorf("s3: cannot decode checkpoint %s: %v", s.Path, err)
+	}
+	return cp, nil
+}
+
+func (s *FileCheckpointStore) Save(cp *Checkpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(s.Path), filepath.Base(s.Path)+".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), s.Path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+func (s *FileCheckpointStore) Delete() error {
+	err := os.Remove(s.Path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// ResumableOptions controls a PutResumable upload.
+type ResumableOptions struct {
+	// Options and ContentType describe the new object. They are only
+	// used when the multipart upload is initiated.
+	Options
+	ContentType string
+
+	// PartSize is the size of every part but the last one. It defaults
+	// to MinPartSize, or to the smallest size that fits the object in
+	// MaxParts parts. A resumed upload keeps the part size it started
+	// with.
+	PartSize int64
+
+	// Concurrency is the number of parts sent in parallel. It defaults
+	// to 1.
+	Concurrency int
+
+	// Retries is the number of times a part is sent again after its
+	// upload failed, on top of the retries done for transient errors.
+	Retries int
+
+	// Progress, if set, is called after each part is uploaded or
+	// reused. When resuming, the parts of the checkpoint are reported
+	// first, as reused. Calls are serialized.
+	Progress func(PutAllProgress)
+}
+
+// PutResumable sends size bytes of r to path via a multipart upload that
+// survives the process: the upload ID, part size and parts sent are saved
+// to store as the upload progresses, and a later call with the same store
+// resumes the upload instead of starting over.
+//
+// When resuming, the parts of the saved checkpoint are checked against
+// the parts S3 lists for the upload. Parts listed with the ETag recorded
+// in the checkpoint are reused without reading r again; the other parts
+// are sent again, unless the part S3 holds has the checksum of the data
+// read from r. If the saved upload no longer exists, having been aborted
+// or expired, a new one is started.
+//
+// If a part fails, the remaining parts are cancelled and the error is
+// returned, leaving the multipart upload and the checkpoint in place so
+// that the upload can be resumed. Once the upload is complete, the
+// checkpoint is deleted from store.
+func (b *Bucket) PutResumable(path string, r io.ReaderAt, size int64, perm ACL, store CheckpointStore, options ResumableOptions) error {
+	cp, err := store.Load()
+	if err != nil {
+		return err
+	}
+	var m *Multi
+	if cp != nil {
+		if cp.Bucket != b.Name || cp.Key != path || cp.Size != size {
+			return fmt.Errorf("s3: checkpoint is for %d bytes to %s/%s, not %d bytes to %s/%s", cp.Size, cp.Bucket, cp.Key, size, b.Name, path)
+		}
+		if m, err = b.findMulti(path, cp.UploadId); err != nil {
+			return err
+		}
+		if m != nil {
+			m.kms = options.SSEKMS || options.SSEKMSKeyId != ""
+		}
+	}
+	if m == nil {
+		partSize := options.PartSize
+		if partSize <= 0 {
+			partSize = minPartSizeFor(size)
+		} else if partSize < minPartSize {
+			return fmt.Errorf("s3: part size of %d bytes is below the minimum of %d bytes", partSize, minPartSize)
+		}
+		if _, err := partCount(size, partSize); err != nil {
+			return err
+		}
+		if m, err = b.InitMulti(path, options.ContentType, perm, options.Options); err != nil {
+			return err
+		}
+		cp = &Checkpoint{Bucket: b.Name, Key: path, UploadId: m.UploadId, Size: size, PartSize: partSize}
+		if err := store.Save(cp); err != nil {
+			return err
+		}
+	}
+
+	partSize := cp.PartSize
+	nparts, err := partCount(size, partSize)
+	if err != nil {
+		return err
+	}
+	listed, err := m.ListParts()
+	if err != nil {
+		return err
+	}
+	uploaded := make(map[int]Part)
+	for _, p := range listed {
+		uploaded[p.N] = p
+	}
+	// The parts of the checkpoint that S3 still holds are not read again.
+	var reused []Part
+	for _, p := range cp.Parts {
+		if p.N >= 1 && p.N <= nparts && uploaded[p.N] == p {
+			reused = append(reused, p)
+		}
+	}
+	cp.Parts = append([]Part(nil), reused...)
+
+	putOptions := PutAllOptions{
+		Concurrency: options.Concurrency,
+		Retries:     options.Retries,
+		PartDone: func(part Part) error {
+			cp.Parts = append(cp.Parts, part)
+			return store.Save(cp)
+		},
+		Progress: options.Progress,
+	}
+	result, err := m.putParts(r, size, partSize, listed, reused, putOptions)
+	if err != nil {
+		return err
+	}
+	if err := m.Complete(result); err != nil {
+		return err
+	}
+	return store.Delete()
+}
+
+// findMulti returns the unfinished multipart upload of key with the given
+// ID, or nil if there is none.
+func (b *Bucket) findMulti(key, uploadId string) (*Multi, error) {
+	multis, _, err := b.ListMulti(key, "")
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range multis {
+		if m.Key == key && m.UploadId == uploadId {
+			return m, nil
+		}
+	}
+	return nil, nil
+}
@@ -42,13 +42,32 @@ type S3 struct {
 	ConnectTimeout time.Duration
 	ReadTimeout    time.Duration
 	Signature      int
-	private        byte // Reserve the right of using private data.
+	// Transport, if set, is used to send requests instead of a transport
+	// dialing with ConnectTimeout and ReadTimeout.
+	Transport http.RoundTripper
+	// Addressing is how buckets are named in request URLs, one of
+	// AddressingAuto, AddressingPathStyle or AddressingVirtualHosted.
+	Addressing int
+	// Accelerate sends object requests to the transfer acceleration
+	// endpoint, which must be enabled on the bucket. Requests fail if
+	// the region has a non-AWS S3Endpoint.
+	Accelerate bool
+	// DualStack sends requests to the endpoints answering over both
+	// IPv4 and IPv6. Requests fail if the region has a non-AWS
+	// S3Endpoint.
+	DualStack bool
+	private   byte // Reserve the right of using private data.
 }
 
 // The Bucket type encapsulates operations with an S3 bucket.
 type Bucket struct {
 	*S3
 	Name string
+	// KMSEncrypted is set when the objects of the bucket are encrypted
+	// with KMS. S3 only serves them to requests signed with signature
+	// version 4, so reading, heading and copying objects are then signed
+	// with it whatever S3.Signature is.
+	KMSEncrypted bool
 }
 
 // The Owner type represents the owner of the object in an S3 bucket.
@@ -72,6 +91,18 @@ type Options struct {
 	ContentDisposition   string
 	Range                string
 	StorageClass         StorageClass
+	// SSEKMS encrypts the object with a KMS key: SSEKMSKeyId if set,
+	// the account's default S3 key otherwise. SSEKMSContext is the
+	// optional encryption context. Requests using KMS are always signed
+	// with signature version 4.
+	SSEKMS        bool
+	SSEKMSKeyId   string
+	SSEKMSContext map[string]string
+	// Grants, if set, are sent as x-amz-grant-* headers in place of
+	// the canned ACL.
+	Grants []Grant
+	// Tags are attached to the new object.
+	Tags []Tag
 	// What else?
 }
 
@@ -80,12 +111,29 @@ type CopyOptions struct {
 	CopySourceOptions string
 	MetadataDirective string
 	ContentType       string
+	// TaggingDirective is TaggingDirectiveCopy, the default, to keep the
+	// tags of the source object or TaggingDirectiveReplace to use Tags.
+	TaggingDirective string
+	// SourceVersionId, if set, copies that version of the source object
+	// rather than the current one.
+	SourceVersionId string
+	// The CopySourceSSECustomer fields give the key of a source object
+	// encrypted with SSE-C.
+	CopySourceSSECustomerAlgorithm string
+	CopySourceSSECustomerKey       string
+	CopySourceSSECustomerKeyMD5    string
 }
 
 // CopyObjectResult is the output from a Copy request
 type CopyObjectResult struct {
 	ETag         string
 	LastModified string
+	// VersionId is the version of the new object and SourceVersionId the
+	// version that was copied, when the buckets are versioned.
+	VersionId       string `xml:"-"`
+	SourceVersionId string `xml:"-"`
+	// Encryption is how the new object is encrypted.
+	Encryption ServerSideEncryption `xml:"-"`
 }
 
 var attempts = aws.AttemptStrategy{
@@ -96,7 +144,7 @@ var attempts = aws.AttemptStrategy{
 
 // New creates a new S3.
 func New(auth aws.Auth, region aws.Region) *S3 {
-	return &S3{auth, region, 0, 0, aws.V2Signature, 0}
+	return &S3{Auth: auth, Region: region, Signature: aws.V2Signature}
 }
 
 // Bucket returns a Bucket with the given name.
@@ -104,7 +152,7 @@ func (s3 *S3) Bucket(name string) *Bucket {
 	if s3.Region.S3BucketEndpoint != "" || s3.Region.S3LowercaseBucket {
 		name = strings.ToLower(name)
 	}
-	return &Bucket{s3, name}
+	return &Bucket{S3: s3, Name: name}
 }
 
 type BucketInfo struct {
@@ -167,8 +215,10 @@ const (
 type StorageClass string
 
 const (
-	ReducedRedundancy = StorageClass("REDUCED_REDUNDANCY")
-	StandardStorage   = StorageClass("STANDARD")
+	ReducedRedundancy  = StorageClass("REDUCED_REDUNDANCY")
+	StandardStorage    = StorageClass("STANDARD")
+	GlacierStorage     = StorageClass("GLACIER")
+	DeepArchiveStorage = StorageClass("DEEP_ARCHIVE")
 )
 
 // PutBucket creates a new bucket.
@@ -246,10 +296,16 @@ func (b *Bucket) GetResponse(path string) (resp *http.Response, err error) {
 // It is the caller's responsibility to call Close on rc when
 // finished reading
 func (b *Bucket) GetResponseWithHeaders(path string, headers map[string][]string) (resp *http.Response, err error) {
+	return b.getResponse(path, nil, headers)
+}
+
+func (b *Bucket) getResponse(path string, params url.Values, headers map[string][]string) (resp *http.Response, err error) {
 	req := &request{
 		bucket:  b.Name,
 		path:    path,
+		params:  params,
 		headers: headers,
+		signV4:  b.KMSEncrypted,
 	}
 	err = b.S3.prepare(req)
 	if err != nil {
@@ -274,6 +330,7 @@ func (b *Bucket) Exists(path string) (exists bool, err error) {
 		method: "HEAD",
 		bucket: b.Name,
 		path:   path,
+		signV4: b.KMSEncrypted,
 	}
 	err = b.S3.prepare(req)
 	if err != nil {
@@ -308,11 +365,17 @@ func (b *Bucket) Exists(path string) (exists bool, err error) {
 // Head HEADs an object in the S3 bucket, returns the response with
 // no body see http://bit.ly/17K1ylI
 func (b *Bucket) Head(path string, headers map[string][]string) (*http.Response, error) {
+	return b.head(path, nil, headers)
+}
+
+func (b *Bucket) head(path string, params url.Values, headers map[string][]string) (*http.Response, error) {
 	req := &request{
 		method:  "HEAD",
 		bucket:  b.Name,
 		path:    path,
+		params:  params,
 		headers: headers,
+		signV4:  b.KMSEncrypted,
 	}
 	err := b.S3.prepare(req)
 	if err != nil {
@@ -340,11 +403,28 @@ func (b *Bucket) Put(path string, data []byte, contType string, perm ACL, option
 	return b.PutReader(path, body, int64(len(data)), contType, perm, options)
 }
 
+// PutObjectResult describes the object written by a PUT.
+type PutObjectResult struct {
+	ETag string
+	// VersionId is the version of the new object, when the bucket is
+	// versioned.
+	VersionId string
+	// Encryption is how the new object is encrypted.
+	Encryption ServerSideEncryption
+}
+
+// PutWithResult inserts an object into the S3 bucket like Put and returns
+// the ETag, version and encryption of the new object.
+func (b *Bucket) PutWithResult(path string, data []byte, contType string, perm ACL, options Options) (*PutObjectResult, error) {
+	body := bytes.NewBuffer(data)
+	return b.PutReaderWithResult(path, body, int64(len(data)), contType, perm, options)
+}
+
 // PutCopy puts a copy of an object given by the key path into bucket b using b.Path as the target key
 func (b *Bucket) PutCopy(path string, perm ACL, options CopyOptions, source string) (*CopyObjectResult, error) {
 	headers := map[string][]string{
 		"x-amz-acl":         {string(perm)},
-		"x-amz-copy-source": {url.QueryEscape(source)},
+		"x-amz-copy-source": {copySourceHeader(source, options.SourceVersionId)},
 	}
 	options.addHeaders(headers)
 	req := &request{
@@ -352,9 +432,20 @@ func (b *Bucket) PutCopy(path string, perm ACL, options CopyOptions, source stri
 		bucket:  b.Name,
 		path:    path,
 		headers: headers,
+		signV4:  b.KMSEncrypted,
 	}
 	resp := &CopyObjectResult{}
-	err := b.S3.query(req, resp)
+	err := b.S3.prepare(req)
+	if err != nil {
+		return resp, err
+	}
+	hresp, err := b.S3.run(req, resp)
+	if hresp != nil {
+		resp.VersionId = hresp.Header.Get("x-amz-version-id")
+		resp.SourceVersionId = hresp.Header.Get("x-amz-copy-source-version-id")
+		resp.Encryption = ResponseEncryption(hresp)
+		hresp.Body.Close()
+	}
 	if err != nil {
 		return resp, err
 	}
@@ -364,6 +455,13 @@ func (b *Bucket) PutCopy(path string, perm ACL, options CopyOptions, source stri
 // PutReader inserts an object into the S3 bucket by consuming data
 // from r until EOF.
 func (b *Bucket) PutReader(path string, r io.Reader, length int64, contType string, perm ACL, options Options) error {
+	_, err := b.PutReaderWithResult(path, r, length, contType, perm, options)
+	return err
+}
+
+// PutReaderWithResult inserts an object into the S3 bucket like PutReader
+// and returns the ETag, version and encryption of the new object.
+func (b *Bucket) PutReaderWithResult(path string, r io.Reader, length int64, contType string, perm ACL, options Options) (*PutObjectResult, error) {
 	headers := map[string][]string{
 		"Content-Length": {strconv.FormatInt(length, 10)},
 		"Content-Type":   {contType},
@@ -377,13 +475,28 @@ func (b *Bucket) PutReader(path string, r io.Reader, length int64, contType stri
 		headers: headers,
 		payload: r,
 	}
-	return b.S3.query(req, nil)
+	err := b.S3.prepare(req)
+	if err != nil {
+		return nil, err
+	}
+	hresp, err := b.S3.run(req, nil)
+	if err != nil {
+		return nil, err
+	}
+	hresp.Body.Close()
+	return &PutObjectResult{
+		ETag:       hresp.Header.Get("ETag"),
+		VersionId:  hresp.Header.Get("x-amz-version-id"),
+		Encryption: ResponseEncryption(hresp),
+	}, nil
 }
 
 // addHeaders adds o's specified fields to headers
 func (o Options) addHeaders(headers map[string][]string) {
-	if o.SSE {
-		headers["x-amz-server-side-encryption"] = []string{"AES256"}
+	if o.SSEKMS || len(o.SSEKMSKeyId) != 0 {
+		addKMSHeaders(headers, o.SSEKMSKeyId, o.SSEKMSContext)
+	} else if o.SSE {
+		headers["x-amz-server-side-encryption"] = []string{SSEAlgorithmAES256}
 	} else if len(o.SSECustomerAlgorithm) != 0 && len(o.SSECustomerKey) != 0 && len(o.SSECustomerKeyMD5) != 0 {
 		// Amazon-managed keys and customer-managed keys are mutually exclusive
 		headers["x-amz-server-side-encryption-customer-algorithm"] = []string{o.SSECustomerAlgorithm}
@@ -415,6 +528,14 @@ func (o Options) addHeaders(headers map[string][]string) {
 	for k, v := range o.Meta {
 		headers["x-amz-meta-"+k] = v
 	}
+	if len(o.Grants) != 0 {
+		// S3 rejects requests with both a canned ACL and explicit grants.
+		delete(headers, "x-amz-acl")
+		addGrantHeaders(headers, o.Grants)
+	}
+	if len(o.Tags) != 0 {
+		headers["x-amz-tagging"] = []string{encodeTags(o.Tags)}
+	}
 }
 
 // addHeaders adds o's specified fields to headers
@@ -423,6 +544,14 @@ func (o CopyOptions) addHeaders(headers map[string][]string) {
 	if len(o.MetadataDirective) != 0 {
 		headers["x-amz-metadata-directive"] = []string{o.MetadataDirective}
 	}
+	if len(o.TaggingDirective) != 0 {
+		headers["x-amz-tagging-directive"] = []string{o.TaggingDirective}
+	}
+	if len(o.CopySourceSSECustomerAlgorithm) != 0 && len(o.CopySourceSSECustomerKey) != 0 && len(o.CopySourceSSECustomerKeyMD5) != 0 {
+		headers["x-amz-copy-source-server-side-encryption-customer-algorithm"] = []string{o.CopySourceSSECustomerAlgorithm}
+		headers["x-amz-copy-source-server-side-encryption-customer-key"] = []string{o.CopySourceSSECustomerKey}
+		headers["x-amz-copy-source-server-side-encryption-customer-key-MD5"] = []string{o.CopySourceSSECustomerKeyMD5}
+	}
 	if len(o.CopySourceOptions) != 0 {
 		headers["x-amz-copy-source-range"] = []string{o.CopySourceOptions}
 	}
@@ -495,6 +624,55 @@ func (b *Bucket) PutBucketSubresource(subresource string, r io.Reader, length in
 	return b.S3.query(req, nil)
 }
 
+// GetBucketSubresource returns the raw document held by a subresource
+// of the bucket, such as "cors" or "policy".
+func (b *Bucket) GetBucketSubresource(subresource string) ([]byte, error) {
+	req := &request{
+		bucket: b.Name,
+		path:   "/",
+		params: url.Values{subresource: {""}},
+		signV4: b.KMSEncrypted,
+	}
+	err := b.S3.prepare(req)
+	if err != nil {
+		return nil, err
+	}
+	for attempt := attempts.Start(); attempt.Next(); {
+		var resp *http.Response
+		resp, err = b.S3.run(req, nil)
+		if err == nil {
+			var data []byte
+			data, err = ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err == nil {
+				return data, nil
+			}
+		}
+		if !shouldRetry(err) {
+			break
+		}
+	}
+	return nil, err
+}
+
+// DelBucketSubresource deletes a subresource of the bucket.
+func (b *Bucket) DelBucketSubresource(subresource string) error {
+	req := &request{
+		method: "DELETE",
+		bucket: b.Name,
+		path:   "/",
+		params: url.Values{subresource: {""}},
+	}
+	var err error
+	for attempt := attempts.Start(); attempt.Next(); {
+		err = b.S3.query(req, nil)
+		if !shouldRetry(err) {
+			break
+		}
+	}
+	return err
+}
+
 // Del removes an object from the S3 bucket.
 //
 // See http://goo.gl/APeTt for details.
@@ -521,6 +699,46 @@ type Object struct {
 //
 // See http://goo.gl/jx6cWK for details.
 func (b *Bucket) DelMulti(objects Delete) error {
+	return b.delMulti(objects, nil)
+}
+
+// DeleteResult holds the outcome of a DelMultiWithResult request.
+// Unless the request was Quiet, Deleted lists every object removed.
+type DeleteResult struct {
+	Deleted []DeletedObject `xml:"Deleted"`
+	Errors  []DeleteError   `xml:"Error"`
+}
+
+// DeletedObject describes an object removed by DelMultiWithResult.
+// DeleteMarker is true when the deletion created a delete marker,
+// or removed one, in a versioned bucket.
+type DeletedObject struct {
+	Key                   string
+	VersionId             string
+	DeleteMarker          bool
+	DeleteMarkerVersionId string
+}
+
+// DeleteError describes an object DelMultiWithResult failed to remove.
+type DeleteError struct {
+	Key       string
+	VersionId string
+	Code      string
+	Message   string
+}
+
+// DelMultiWithResult removes up to 1000 objects from the S3 bucket, like
+// DelMulti, and returns the per-object result sent by S3.
+func (b *Bucket) DelMultiWithResult(objects Delete) (*DeleteResult, error) {
+	result := &DeleteResult{}
+	err := b.delMulti(objects, result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (b *Bucket) delMulti(objects Delete, result interface{}) error {
 	doc, err := xml.Marshal(objects)
 	if err != nil {
 		return err
@@ -547,7 +765,7 @@ func (b *Bucket) DelMulti(objects Delete) error {
 		payload: buf,
 	}
 
-	return b.S3.query(req, nil)
+	return b.S3.query(req, result)
 }
 
 // The ListResp type holds the results of a List bucket operation.
@@ -681,7 +899,13 @@ type VersionsResp struct {
 	Delimiter       string
 	IsTruncated     bool
 	Versions        []Version `xml:"Version"`
+	DeleteMarkers   []Version `xml:"DeleteMarker"`
 	CommonPrefixes  []string  `xml:">Prefix"`
+	// if IsTruncated is true, pass NextKeyMarker and NextVersionIdMarker
+	// as keyMarker and versionIdMarker to Versions() to get the next set
+	// of versions
+	NextKeyMarker       string
+	NextVersionIdMarker string
 }
 
 // The Version type represents an object version stored in an S3 bucket.
@@ -696,6 +920,10 @@ type Version struct {
 	Size         int64
 	Owner        Owner
 	StorageClass string
+
+	// IsDeleteMarker is set on the delete markers returned by a
+	// VersionIterator, which are listed in VersionsResp.DeleteMarkers.
+	IsDeleteMarker bool `xml:"-"`
 }
 
 func (b *Bucket) Versions(prefix, delim, keyMarker string, versionIdMarker string, max int) (result *VersionsResp, err error) {
@@ -757,22 +985,39 @@ func (b *Bucket) Location() (string, error) {
 
 // URL returns a non-signed URL that allows retriving the
 // object at path. It only works if the object is publicly
-// readable (see SignedURL).
+// readable (see SignedURL). Unlike ObjectURL, it does not check the
+// bucket name or the endpoint against the addressing options of S3.
 func (b *Bucket) URL(path string) string {
+	u, err := b.url(path, true)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
+
+// ObjectURL returns a non-signed URL that allows retriving the object
+// at path, like URL. It returns an error if the bucket cannot be
+// addressed as set up by S3.Addressing, S3.Accelerate and S3.DualStack.
+func (b *Bucket) ObjectURL(path string) (string, error) {
+	return b.url(path, false)
+}
+
+func (b *Bucket) url(path string, unchecked bool) (string, error) {
 	req := &request{
-		bucket: b.Name,
-		path:   path,
+		bucket:    b.Name,
+		path:      path,
+		unchecked: unchecked,
 	}
 	err := b.S3.prepare(req)
 	if err != nil {
-		panic(err)
+		return "", err
 	}
 	u, err := req.url()
 	if err != nil {
-		panic(err)
+		return "", err
 	}
 	u.RawQuery = ""
-	return u.String()
+	return u.String(), nil
 }
 
 // SignedURL returns a signed URL that allows anyone holding the URL
@@ -789,7 +1034,25 @@ func (b *Bucket) SignedURLWithArgs(path string, expires time.Time, params url.Va
 
 // SignedURLWithMethod returns a signed URL that allows anyone holding the URL
 // to either retrieve the object at path or make a HEAD request against it. The signature is valid until expires.
+// Unlike SignURL, it does not check the bucket name or the endpoint
+// against the addressing options of S3.
 func (b *Bucket) SignedURLWithMethod(method, path string, expires time.Time, params url.Values, headers http.Header) string {
+	u, err := b.signedURL(method, path, expires, params, headers, true)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
+
+// SignURL returns a signed URL that allows anyone holding the URL to
+// make a method request against the object at path, like
+// SignedURLWithMethod. It returns an error if the bucket cannot be
+// addressed as set up by S3.Addressing, S3.Accelerate and S3.DualStack.
+func (b *Bucket) SignURL(method, path string, expires time.Time, params url.Values, headers http.Header) (string, error) {
+	return b.signedURL(method, path, expires, params, headers, false)
+}
+
+func (b *Bucket) signedURL(method, path string, expires time.Time, params url.Values, headers http.Header, unchecked bool) (string, error) {
 	var uv = url.Values{}
 
 	if params != nil {
@@ -803,24 +1066,25 @@ func (b *Bucket) SignedURLWithMethod(method, path string, expires time.Time, par
 	}
 
 	req := &request{
-		method:  method,
-		bucket:  b.Name,
-		path:    path,
-		params:  uv,
-		headers: headers,
+		method:    method,
+		bucket:    b.Name,
+		path:      path,
+		params:    uv,
+		headers:   headers,
+		unchecked: unchecked,
 	}
 	err := b.S3.prepare(req)
 	if err != nil {
-		panic(err)
+		return "", err
 	}
 	u, err := req.url()
 	if err != nil {
-		panic(err)
+		return "", err
 	}
 	if b.S3.Auth.Token() != "" && b.S3.Signature == aws.V2Signature {
-		return u.String() + "&x-amz-security-token=" + url.QueryEscape(req.headers["X-Amz-Security-Token"][0])
+		return u.String() + "&x-amz-security-token=" + url.QueryEscape(req.headers["X-Amz-Security-Token"][0]), nil
 	} else {
-		return u.String()
+		return u.String(), nil
 	}
 }
 
@@ -899,7 +1163,10 @@ func (b *Bucket) PostFormArgsEx(path string, expires time.Time, redirect string,
 	signer.Write([]byte(policy64))
 	fields["signature"] = base64.StdEncoding.EncodeToString(signer.Sum(nil))
 
-	action = fmt.Sprintf("%s/%s/", b.S3.Region.S3Endpoint, b.Name)
+	action, err := b.S3.bucketURL(b.Name)
+	if err != nil {
+		action = fmt.Sprintf("%s/%s/", b.S3.Region.S3Endpoint, b.Name)
+	}
 	return
 }
 
@@ -918,6 +1185,15 @@ type request struct {
 	baseurl  string
 	payload  io.Reader
 	prepared bool
+	// signV4 forces a signature version 4 whatever S3.Signature is.
+	signV4 bool
+	// hostBucket is set when the bucket is in the host name rather than
+	// in path.
+	hostBucket bool
+	// unchecked skips the checks of the bucket name and the endpoint
+	// against the addressing options, except the one against injection,
+	// for the URL methods that cannot return an error.
+	unchecked bool
 }
 
 func (req *request) url() (*url.URL, error) {
@@ -974,28 +1250,6 @@ func (s3 *S3) queryV4Sign(req *request, resp interface{}) error {
 	return err
 }
 
-// Sets baseurl on req from bucket name and the region endpoint
-func (s3 *S3) setBaseURL(req *request) error {
-	if req.bucket == "" {
-		req.baseurl = s3.Region.S3Endpoint
-	} else {
-		req.baseurl = s3.Region.S3BucketEndpoint
-		if req.baseurl == "" {
-			// Use the path method to address the bucket.
-			req.baseurl = s3.Region.S3Endpoint
-			req.path = "/" + req.bucket + req.path
-		} else {
-			// Just in case, prevent injection.
-			if strings.IndexAny(req.bucket, "/:@") >= 0 {
-				return fmt.Errorf("bad S3 bucket: %q", req.bucket)
-			}
-			req.baseurl = strings.Replace(req.baseurl, "${bucket}", req.bucket, -1)
-		}
-	}
-
-	return nil
-}
-
 // partiallyEscapedPath partially escapes the S3 path allowing for all S3 REST API calls.
 //
 // Some commands including:
@@ -1052,13 +1306,15 @@ func (s3 *S3) prepare(req *request) error {
 		}
 	}
 
-	if s3.Signature == aws.V2Signature && s3.Auth.Token() != "" {
+	signV2 := s3.Signature == aws.V2Signature && !req.signV4 && !usesKMS(req.headers)
+
+	if signV2 && s3.Auth.Token() != "" {
 		req.headers["X-Amz-Security-Token"] = []string{s3.Auth.Token()}
 	} else if s3.Auth.Token() != "" {
 		req.params.Set("X-Amz-Security-Token", s3.Auth.Token())
 	}
 
-	if s3.Signature == aws.V2Signature {
+	if signV2 {
 		// Always sign again as it's not clear how far the
 		// server has handled a previous attempt.
 		u, err := url.Parse(req.baseurl)
@@ -1066,7 +1322,11 @@ func (s3 *S3) prepare(req *request) error {
 			return err
 		}
 
-		signpathPatiallyEscaped := partiallyEscapedPath(req.path)
+		signpath := req.path
+		if req.hostBucket {
+			signpath = "/" + req.bucket + signpath
+		}
+		signpathPatiallyEscaped := partiallyEscapedPath(signpath)
 		req.headers["Host"] = []string{u.Host}
 		req.headers["Date"] = []string{time.Now().In(time.UTC).Format(time.RFC1123)}
 
@@ -1130,8 +1390,9 @@ func (s3 *S3) setupHttpRequest(req *request) (*http.Request, error) {
 // If resp is not nil, the XML data contained in the response
 // body will be unmarshalled on it.
 func (s3 *S3) doHttpRequest(hreq *http.Request, resp interface{}) (*http.Response, error) {
-	c := http.Client{
-		Transport: &http.Transport{
+	c := http.Client{Transport: s3.Transport}
+	if c.Transport == nil {
+		c.Transport = &http.Transport{
 			Dial: func(netw, addr string) (c net.Conn, err error) {
 				deadline := time.Now().Add(s3.ReadTimeout)
 				if s3.ConnectTimeout > 0 {
@@ -1148,7 +1409,7 @@ func (s3 *S3) doHttpRequest(hreq *http.Request, resp interface{}) (*http.Respons
 				return
 			},
 			Proxy: http.ProxyFromEnvironment,
-		},
+		}
 	}
 
 	hresp, err := c.Do(hreq)
@@ -1159,7 +1420,9 @@ func (s3 *S3) doHttpRequest(hreq *http.Request, resp interface{}) (*http.Respons
 		dump, _ := httputil.DumpResponse(hresp, true)
 		log.Printf("} -> %s\n", dump)
 	}
-	if hresp.StatusCode != 200 && hresp.StatusCode != 204 && hresp.StatusCode != 206 {
+	// S3 answers a restore request with 202 Accepted, and no other.
+	restore := hreq.Method == "POST" && hreq.URL.Query()["restore"] != nil
+	if hresp.StatusCode != 200 && hresp.StatusCode != 204 && hresp.StatusCode != 206 && !(hresp.StatusCode == 202 && restore) {
 		return nil, buildError(hresp)
 	}
 	if resp != nil {
@@ -45,6 +45,7 @@ func (s *S) SetUpTest(c *check.C) {
 
 func (s *S) TearDownTest(c *check.C) {
 	testServer.Flush()
+	s3.SetMinPartSize(0)
 }
 
 func (s *S) DisableRetries() {
@@ -139,6 +140,46 @@ func (s *S) TestGet(c *check.C) {
 	c.Assert(string(data), check.Equals, "content")
 }
 
+func (s *S) TestGetThroughRecorder(c *check.C) {
+	testServer.Response(200, nil, "content")
+
+	path := c.MkDir() + "/get.json"
+	rec, err := testutil.NewRecorder(path, testutil.ModeRecord)
+	c.Assert(err, check.IsNil)
+	s3c := *s.s3
+	s3c.Transport = rec
+	data, err := s3c.Bucket("bucket").Get("name")
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, "content")
+	testServer.WaitRequest()
+	c.Assert(rec.Stop(), check.IsNil)
+
+	rec, err = testutil.NewRecorder(path, testutil.ModeReplay)
+	c.Assert(err, check.IsNil)
+	s3c.Transport = rec
+	data, err = s3c.Bucket("bucket").Get("name")
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, "content")
+
+	in := rec.Cassette().Interactions[0]
+	c.Assert(in.Request.URL, check.Equals, testServer.URL+"/bucket/name")
+	c.Assert(in.Request.Header.Get("Authorization"), check.Equals, "SCRUBBED")
+}
+
+func (s *S) TestGetRetriesAfterReset(c *check.C) {
+	faults := &testutil.Faults{}
+	testServer.SetFaults(faults)
+	defer testServer.SetFaults(nil)
+	faults.Add(testutil.FaultRule{Fault: testutil.Fault{Reset: true}, Nth: 1})
+	testServer.Response(200, nil, "content")
+
+	data, err := s.s3.Bucket("bucket").Get("name")
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, "content")
+	c.Assert(faults.Fired(), check.Equals, 1)
+	testServer.WaitRequest()
+}
+
 func (s *S) TestGetWithPlus(c *check.C) {
 	testServer.Response(200, nil, "content")
 
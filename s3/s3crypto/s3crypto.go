@@ -0,0 +1,287 @@
+// Package s3crypto encrypts S3 objects on the client before they are
+// uploaded, using envelope encryption with data keys generated by KMS.
+//
+// Objects are encrypted with AES-GCM under a fresh 256-bit data key. The
+// data key, wrapped by KMS, and the nonce are stored in the object
+// metadata in the format of version 2 of the AWS S3 encryption clients,
+// so objects written here can be read by them and the other way around.
+//
+// The standard library's AES-GCM seals and opens whole messages, so each
+// object is held in memory while it is encrypted or decrypted, and its
+// tag is checked before any of it is returned.
+//
+// See https://docs.aws.amazon.com/general/latest/gr/aws_sdk_cryptography.html
+// for details.
+package s3crypto
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/AdRoll/goamz/kms"
+	"github.com/AdRoll/goamz/s3"
+)
+
+// Object metadata names, without the x-amz-meta- prefix S3 adds.
+const (
+	metaKey               = "x-amz-key-v2"
+	metaIV                = "x-amz-iv"
+	metaMatDesc           = "x-amz-matdesc"
+	metaWrapAlg           = "x-amz-wrap-alg"
+	metaCEKAlg            = "x-amz-cek-alg"
+	metaTagLen            = "x-amz-tag-len"
+	metaUnencryptedLength = "x-amz-unencrypted-content-length"
+)
+
+const (
+	// wrapAlgKMSContext wraps the data key with KMS, using the material
+	// description as encryption context.
+	wrapAlgKMSContext = "kms+context"
+	// wrapAlgKMS is the version 1 equivalent, only used for reading.
+	wrapAlgKMS = "kms"
+
+	cekAlgGCM = "AES/GCM/NoPadding"
+
+	// cekAlgContextKey is the encryption context entry binding the data
+	// key to the content encryption algorithm.
+	cekAlgContextKey = "aws:x-amz-cek-alg"
+)
+
+const (
+	gcmNonceSize = 12
+	gcmTagSize   = 16
+
+	// gcmMaxSize is the most plaintext a single nonce may encrypt.
+	gcmMaxSize = (1<<32 - 2) * 16
+)
+
+var (
+	errNotEncrypted   = errors.New("s3crypto: object is not client-side encrypted")
+	errTooLarge       = errors.New("s3crypto: plaintext too large for AES-GCM")
+	errAuthentication = errors.New("s3crypto: message authentication failed")
+)
+
+// KMSClient generates and unwraps data keys. It is implemented by
+// *kms.KMS.
+type KMSClient interface {
+	GenerateDataKey(info kms.GenerateDataKeyInfo) (kms.GenerateDataKeyResp, error)
+	Decrypt(info kms.DecryptInfo) (kms.DecryptResp, error)
+}
+
+// Bucket reads and writes client-side encrypted objects in an S3 bucket.
+type Bucket struct {
+	Bucket *s3.Bucket
+	KMS    KMSClient
+
+	// KeyId is the KMS key wrapping the data keys, in any of the forms
+	// KMS accepts.
+	KeyId string
+
+	// Context, if set, is added to the encryption context of the data
+	// keys and stored with each object.
+	Context map[string]string
+}
+
+// New returns a Bucket encrypting the objects of b with data keys
+// wrapped by the KMS key keyId.
+func New(b *s3.Bucket, k KMSClient, keyId string) *Bucket {
+	return &Bucket{Bucket: b, KMS: k, KeyId: keyId}
+}
+
+// seal generates a data key and nonce for a new object and adds the
+// metadata describing them to options.
+func (b *Bucket) seal(options *s3.Options) (cipher.AEAD, []byte, error) {
+	context := make(map[string]string, len(b.Context)+1)
+	for k, v := range b.Context {
+		context[k] = v
+	}
+	// Set last so that b.Context cannot override it.
+	context[cekAlgContextKey] = cekAlgGCM
+	info := kms.GenerateDataKeyInfo{KeyId: b.KeyId, KeySpec: "AES_256"}
+	info.EncryptionContext = context
+	key, err := b.KMS.GenerateDataKey(info)
+	if err != nil {
+		return nil, nil, err
+	}
+	aead, err := newGCM(key.Plaintext)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce := make([]byte, gcmNonceSize)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, nil, err
+	}
+	matdesc, err := json.Marshal(context)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	meta := make(map[string][]string, len(options.Meta)+6)
+	for k, v := range options.Meta {
+		meta[k] = v
+	}
+	meta[metaKey] = []string{base64.StdEncoding.EncodeToString(key.CiphertextBlob)}
+	meta[metaIV] = []string{base64.StdEncoding.EncodeToString(nonce)}
+	meta[metaMatDesc] = []string{string(matdesc)}
+	meta[metaWrapAlg] = []string{wrapAlgKMSContext}
+	meta[metaCEKAlg] = []string{cekAlgGCM}
+	meta[metaTagLen] = []string{strconv.Itoa(gcmTagSize * 8)}
+	options.Meta = meta
+	// The digest S3 checks is that of the ciphertext.
+	options.ContentMD5 = ""
+	return aead, nonce, nil
+}
+
+// open unwraps the data key of an object given the headers of a
+// response to a request on it.
+func (b *Bucket) open(header http.Header) (cipher.AEAD, []byte, error) {
+	meta := func(name string) string {
+		return header.Get("x-amz-meta-" + name)
+	}
+	if meta(metaKey) == "" {
+		return nil, nil, errNotEncrypted
+	}
+	if alg := meta(metaWrapAlg); alg != wrapAlgKMSContext && alg != wrapAlgKMS {
+		return nil, nil, fmt.Errorf("s3crypto: unsupported key wrapping algorithm %q", alg)
+	}
+	if alg := meta(metaCEKAlg); alg != cekAlgGCM {
+		return nil, nil, fmt.Errorf("s3crypto: unsupported content encryption algorithm %q", alg)
+	}
+	if tagLen := meta(metaTagLen); tagLen != strconv.Itoa(gcmTagSize*8) {
+		return nil, nil, fmt.Errorf("s3crypto: unsupported tag length %q", tagLen)
+	}
+	var context map[string]string
+	if err := json.Unmarshal([]byte(meta(metaMatDesc)), &context); err != nil {
+		return nil, nil, fmt.Errorf("s3crypto: bad material description: %v", err)
+	}
+	if meta(metaWrapAlg) == wrapAlgKMSContext && context[cekAlgContextKey] != cekAlgGCM {
+		return nil, nil, errors.New("s3crypto: material description does not match the content encryption algorithm")
+	}
+	wrapped, err := base64.StdEncoding.DecodeString(meta(metaKey))
+	if err != nil {
+		return nil, nil, fmt.Errorf("s3crypto: bad wrapped key: %v", err)
+	}
+	nonce, err := base64.StdEncoding.DecodeString(meta(metaIV))
+	if err != nil || len(nonce) != gcmNonceSize {
+		return nil, nil, errors.New("s3crypto: bad IV")
+	}
+	info := kms.DecryptInfo{CiphertextBlob: wrapped}
+	info.EncryptionContext = context
+	key, err := b.KMS.Decrypt(info)
+	if err != nil {
+		return nil, nil, err
+	}
+	aead, err := newGCM(key.Plaintext)
+	if err != nil {
+		return nil, nil, err
+	}
+	return aead, nonce, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Put encrypts data and stores it as the object at path.
+func (b *Bucket) Put(path string, data []byte, contType string, perm s3.ACL, options s3.Options) error {
+	return b.PutReader(path, bytes.NewReader(data), int64(len(data)), contType, perm, options)
+}
+
+// PutReader encrypts the length bytes read from r and stores them as the
+// object at path.
+func (b *Bucket) PutReader(path string, r io.Reader, length int64, contType string, perm s3.ACL, options s3.Options) error {
+	if length > gcmMaxSize {
+		return errTooLarge
+	}
+	data := make([]byte, length, length+gcmTagSize)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return err
+	}
+	sealed, err := b.encrypt(data, &options)
+	if err != nil {
+		return err
+	}
+	return b.Bucket.PutReader(path, bytes.NewReader(sealed), int64(len(sealed)), contType, perm, options)
+}
+
+// PutStream encrypts the data read from r until EOF and stores it as the
+// object at path, using a multipart upload with parts of partSize bytes
+// if it does not fit in one. The whole of r is read into memory first,
+// as it is sealed at once. See s3.Bucket.PutStream.
+func (b *Bucket) PutStream(path string, r io.Reader, partSize int64, contType string, perm s3.ACL, options s3.Options) error {
+	data, err := ioutil.ReadAll(io.LimitReader(r, gcmMaxSize+1))
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) > gcmMaxSize {
+		return errTooLarge
+	}
+	sealed, err := b.encrypt(data, &options)
+	if err != nil {
+		return err
+	}
+	return b.Bucket.PutStream(path, bytes.NewReader(sealed), partSize, contType, perm, options)
+}
+
+// encrypt seals data, overwriting it, under a new data key described in
+// options.
+func (b *Bucket) encrypt(data []byte, options *s3.Options) ([]byte, error) {
+	aead, nonce, err := b.seal(options)
+	if err != nil {
+		return nil, err
+	}
+	options.Meta[metaUnencryptedLength] = []string{strconv.Itoa(len(data))}
+	return aead.Seal(data[:0], nonce, data, nil), nil
+}
+
+// Get retrieves and decrypts the object at path.
+func (b *Bucket) Get(path string) ([]byte, error) {
+	resp, err := b.Bucket.GetResponse(path)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	aead, nonce, err := b.open(resp.Header)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	data, err := aead.Open(sealed[:0], nonce, sealed, nil)
+	if err != nil {
+		return nil, errAuthentication
+	}
+	// Objects written by the AWS clients with an unknown length lack it.
+	if v := resp.Header.Get("x-amz-meta-" + metaUnencryptedLength); v != "" && v != strconv.Itoa(len(data)) {
+		return nil, fmt.Errorf("s3crypto: object has %d bytes of plaintext, not the %s of its metadata", len(data), v)
+	}
+	return data, nil
+}
+
+// GetReader retrieves the object at path and returns a reader of its
+// plaintext. Like Get, it decrypts the whole object before returning, so
+// no data is read unless all of it is genuine. It is the caller's
+// responsibility to call Close on the reader when finished reading.
+func (b *Bucket) GetReader(path string) (io.ReadCloser, error) {
+	data, err := b.Get(path)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
@@ -0,0 +1,290 @@
+package s3crypto_test
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"strconv"
+	"testing"
+
+	"github.com/AdRoll/goamz/aws"
+	"github.com/AdRoll/goamz/kms"
+	"github.com/AdRoll/goamz/s3"
+	"github.com/AdRoll/goamz/s3/s3crypto"
+	"github.com/AdRoll/goamz/testutil"
+	"gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) {
+	check.TestingT(t)
+}
+
+type S struct {
+	bucket *s3crypto.Bucket
+	kms    *fakeKMS
+}
+
+var _ = check.Suite(&S{})
+
+var testServer = testutil.NewHTTPServer()
+
+var (
+	testKey   = []byte("0123456789abcdef0123456789abcdef")
+	testNonce = []byte("fedcba987654")
+)
+
+func testData(size int) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	return data
+}
+
+func (s *S) SetUpSuite(c *check.C) {
+	testServer.Start()
+	auth := aws.Auth{AccessKey: "abc", SecretKey: "123"}
+	b := s3.New(auth, aws.Region{Name: "faux-region-1", S3Endpoint: testServer.URL}).Bucket("bucket")
+	s.kms = &fakeKMS{}
+	s.bucket = s3crypto.New(b, s.kms, "alias/test")
+}
+
+func (s *S) SetUpTest(c *check.C) {
+	s.kms.context = nil
+}
+
+func (s *S) TearDownTest(c *check.C) {
+	testServer.Flush()
+}
+
+// fakeKMS wraps data keys by XORing them with a constant, and checks the
+// encryption context matches when unwrapping.
+type fakeKMS struct {
+	context map[string]string
+}
+
+func wrap(key []byte) []byte {
+	wrapped := make([]byte, len(key))
+	for i := range key {
+		wrapped[i] = key[i] ^ 0x5c
+	}
+	return wrapped
+}
+
+func (k *fakeKMS) GenerateDataKey(info kms.GenerateDataKeyInfo) (kms.GenerateDataKeyResp, error) {
+	if info.KeySpec != "AES_256" {
+		return kms.GenerateDataKeyResp{}, errors.New("bad key spec")
+	}
+	k.context = info.EncryptionContext
+	return kms.GenerateDataKeyResp{
+		CiphertextBlob: wrap(testKey),
+		KeyId:          info.KeyId,
+		Plaintext:      testKey,
+	}, nil
+}
+
+func (k *fakeKMS) Decrypt(info kms.DecryptInfo) (kms.DecryptResp, error) {
+	if len(info.EncryptionContext) != len(k.context) {
+		return kms.DecryptResp{}, errors.New("InvalidCiphertextException")
+	}
+	for key, v := range k.context {
+		if info.EncryptionContext[key] != v {
+			return kms.DecryptResp{}, errors.New("InvalidCiphertextException")
+		}
+	}
+	return kms.DecryptResp{Plaintext: wrap(info.CiphertextBlob)}, nil
+}
+
+// openSealed decrypts sealed as the AWS clients do, from the metadata
+// headers of the request that stored it.
+func openSealed(c *check.C, header map[string][]string, sealed []byte) []byte {
+	get := func(name string) string {
+		v := header["X-Amz-Meta-"+name]
+		c.Assert(v, check.HasLen, 1, check.Commentf(name))
+		return v[0]
+	}
+	c.Assert(get("X-Amz-Wrap-Alg"), check.Equals, "kms+context")
+	c.Assert(get("X-Amz-Cek-Alg"), check.Equals, "AES/GCM/NoPadding")
+	c.Assert(get("X-Amz-Tag-Len"), check.Equals, "128")
+	var matdesc map[string]string
+	c.Assert(json.Unmarshal([]byte(get("X-Amz-Matdesc")), &matdesc), check.IsNil)
+	c.Assert(matdesc["aws:x-amz-cek-alg"], check.Equals, "AES/GCM/NoPadding")
+
+	wrapped, err := base64.StdEncoding.DecodeString(get("X-Amz-Key-V2"))
+	c.Assert(err, check.IsNil)
+	nonce, err := base64.StdEncoding.DecodeString(get("X-Amz-Iv"))
+	c.Assert(err, check.IsNil)
+	block, err := aes.NewCipher(wrap(wrapped))
+	c.Assert(err, check.IsNil)
+	aead, err := cipher.NewGCM(block)
+	c.Assert(err, check.IsNil)
+	data, err := aead.Open(nil, nonce, sealed, nil)
+	c.Assert(err, check.IsNil)
+	return data
+}
+
+func (s *S) TestPut(c *check.C) {
+	testServer.Response(200, nil, "")
+
+	s.bucket.Context = map[string]string{"team": "data"}
+	defer func() { s.bucket.Context = nil }()
+	options := s3.Options{Meta: map[string][]string{"owner": {"joe"}}, ContentMD5: "ignored"}
+	err := s.bucket.Put("name", []byte("secret"), "text/plain", s3.Private, options)
+	c.Assert(err, check.IsNil)
+	c.Assert(s.kms.context, check.DeepEquals, map[string]string{"aws:x-amz-cek-alg": "AES/GCM/NoPadding", "team": "data"})
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Method, check.Equals, "PUT")
+	c.Assert(req.Header["Content-Length"], check.DeepEquals, []string{"22"})
+	c.Assert(req.Header["Content-Md5"], check.IsNil)
+	c.Assert(req.Header["X-Amz-Meta-Owner"], check.DeepEquals, []string{"joe"})
+	c.Assert(req.Header["X-Amz-Meta-X-Amz-Unencrypted-Content-Length"], check.DeepEquals, []string{"6"})
+	body, err := ioutil.ReadAll(req.Body)
+	c.Assert(err, check.IsNil)
+	c.Assert(openSealed(c, req.Header, body), check.DeepEquals, []byte("secret"))
+}
+
+func (s *S) TestPutKeepsReservedContext(c *check.C) {
+	testServer.Response(200, nil, "")
+
+	s.bucket.Context = map[string]string{"aws:x-amz-cek-alg": "AES/CBC/PKCS5Padding"}
+	defer func() { s.bucket.Context = nil }()
+	err := s.bucket.Put("name", []byte("secret"), "text/plain", s3.Private, s3.Options{})
+	c.Assert(err, check.IsNil)
+	c.Assert(s.kms.context, check.DeepEquals, map[string]string{"aws:x-amz-cek-alg": "AES/GCM/NoPadding"})
+
+	req := testServer.WaitRequest()
+	body, err := ioutil.ReadAll(req.Body)
+	c.Assert(err, check.IsNil)
+	c.Assert(openSealed(c, req.Header, body), check.DeepEquals, []byte("secret"))
+}
+
+func (s *S) TestPutStreamMultipart(c *check.C) {
+	testServer.Response(200, nil, InitMultiResultDump)
+	testServer.Responses(2, 200, map[string]string{"ETag": `"26f90efd10d614f100252ff56d88dad8"`}, "")
+	testServer.Response(200, nil, "")
+
+	data := testData(s3.MinPartSize + 50)
+	err := s.bucket.PutStream("multi", bytes.NewReader(data), 0, "text/plain", s3.Private, s3.Options{})
+	c.Assert(err, check.IsNil)
+
+	init := testServer.WaitRequest()
+	c.Assert(init.URL.RawQuery, check.Equals, "uploads=")
+	c.Assert(init.Header["X-Amz-Meta-X-Amz-Unencrypted-Content-Length"], check.DeepEquals, []string{strconv.Itoa(len(data))})
+	var sealed []byte
+	for i := 0; i < 2; i++ {
+		req := testServer.WaitRequest()
+		c.Assert(req.Method, check.Equals, "PUT")
+		part, err := ioutil.ReadAll(req.Body)
+		c.Assert(err, check.IsNil)
+		sealed = append(sealed, part...)
+	}
+	c.Assert(testServer.WaitRequest().Method, check.Equals, "POST")
+	c.Assert(sealed, check.HasLen, s3.MinPartSize+66)
+	c.Assert(openSealed(c, init.Header, sealed), check.DeepEquals, data)
+}
+
+// sealHeaders returns the response headers of an object sealing data as
+// the AWS clients do.
+func sealHeaders(c *check.C, data []byte) (map[string]string, string) {
+	block, err := aes.NewCipher(testKey)
+	c.Assert(err, check.IsNil)
+	aead, err := cipher.NewGCM(block)
+	c.Assert(err, check.IsNil)
+	headers := map[string]string{
+		"x-amz-meta-x-amz-key-v2":   base64.StdEncoding.EncodeToString(wrap(testKey)),
+		"x-amz-meta-x-amz-iv":       base64.StdEncoding.EncodeToString(testNonce),
+		"x-amz-meta-x-amz-matdesc":  `{"aws:x-amz-cek-alg":"AES/GCM/NoPadding"}`,
+		"x-amz-meta-x-amz-wrap-alg": "kms+context",
+		"x-amz-meta-x-amz-cek-alg":  "AES/GCM/NoPadding",
+		"x-amz-meta-x-amz-tag-len":  "128",
+	}
+	return headers, string(aead.Seal(nil, testNonce, data, nil))
+}
+
+func (s *S) TestGet(c *check.C) {
+	s.kms.context = map[string]string{"aws:x-amz-cek-alg": "AES/GCM/NoPadding"}
+	headers, body := sealHeaders(c, []byte("secret"))
+	testServer.Response(200, headers, body)
+
+	data, err := s.bucket.Get("name")
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, "secret")
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Method, check.Equals, "GET")
+	c.Assert(req.URL.Path, check.Equals, "/bucket/name")
+}
+
+func (s *S) TestGetTampered(c *check.C) {
+	s.kms.context = map[string]string{"aws:x-amz-cek-alg": "AES/GCM/NoPadding"}
+	headers, body := sealHeaders(c, []byte("secret"))
+	testServer.Response(200, headers, "S"+body[1:])
+
+	_, err := s.bucket.Get("name")
+	c.Assert(err, check.ErrorMatches, "s3crypto: message authentication failed")
+}
+
+func (s *S) TestGetWrongUnencryptedLength(c *check.C) {
+	s.kms.context = map[string]string{"aws:x-amz-cek-alg": "AES/GCM/NoPadding"}
+	headers, body := sealHeaders(c, []byte("secret"))
+	headers["x-amz-meta-x-amz-unencrypted-content-length"] = "5"
+	testServer.Response(200, headers, body)
+
+	_, err := s.bucket.Get("name")
+	c.Assert(err, check.ErrorMatches, "s3crypto: object has 6 bytes of plaintext, not the 5 of its metadata")
+}
+
+func (s *S) TestGetReader(c *check.C) {
+	s.kms.context = map[string]string{"aws:x-amz-cek-alg": "AES/GCM/NoPadding"}
+	data := testData(100000)
+	headers, body := sealHeaders(c, data)
+	testServer.Response(200, headers, body)
+
+	rc, err := s.bucket.GetReader("name")
+	c.Assert(err, check.IsNil)
+	got, err := ioutil.ReadAll(rc)
+	c.Assert(err, check.IsNil)
+	c.Assert(rc.Close(), check.IsNil)
+	c.Assert(got, check.DeepEquals, data)
+}
+
+func (s *S) TestGetReaderTampered(c *check.C) {
+	s.kms.context = map[string]string{"aws:x-amz-cek-alg": "AES/GCM/NoPadding"}
+	headers, body := sealHeaders(c, testData(100000))
+	testServer.Response(200, headers, body[:len(body)-1]+"x")
+
+	// No data is returned before the tag is checked.
+	rc, err := s.bucket.GetReader("name")
+	c.Assert(err, check.ErrorMatches, "s3crypto: message authentication failed")
+	c.Assert(rc, check.IsNil)
+}
+
+func (s *S) TestGetWrongContext(c *check.C) {
+	s.kms.context = map[string]string{"aws:x-amz-cek-alg": "AES/GCM/NoPadding"}
+	headers, body := sealHeaders(c, []byte("secret"))
+	headers["x-amz-meta-x-amz-matdesc"] = `{"aws:x-amz-cek-alg":"AES/GCM/NoPadding","team":"data"}`
+	testServer.Response(200, headers, body)
+
+	_, err := s.bucket.Get("name")
+	c.Assert(err, check.ErrorMatches, "InvalidCiphertextException")
+}
+
+func (s *S) TestGetNotEncrypted(c *check.C) {
+	testServer.Response(200, nil, "plain")
+
+	_, err := s.bucket.Get("name")
+	c.Assert(err, check.ErrorMatches, "s3crypto: object is not client-side encrypted")
+}
+
+var InitMultiResultDump = `<?xml version="1.0" encoding="UTF-8"?>
+<InitiateMultipartUploadResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <Bucket>bucket</Bucket>
+  <Key>multi</Key>
+  <UploadId>JNbR_cMdwnGiD12jKAd6WK2PUkfj2VxA7i4nCwjE6t71nI9Tl3eVDPFlU0nOixhftH7I17ZPGkV3QA.l7ZD.QQ--</UploadId>
+</InitiateMultipartUploadResult>
+`
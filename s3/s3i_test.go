@@ -3,6 +3,7 @@ package s3_test
 import (
 	"bytes"
 	"crypto/md5"
+	"errors"
 	"fmt"
 	"github.com/AdRoll/goamz/aws"
 	"github.com/AdRoll/goamz/s3"
@@ -11,6 +12,8 @@ import (
 	"io/ioutil"
 	"net"
 	"net/http"
+	"os"
+	"path/filepath"
 	"sort"
 	"strings"
 	"time"
@@ -84,6 +87,17 @@ func (s *AmazonDomainClientSuite) TearDownTest(c *check.C) {
 type ClientTests struct {
 	s3           *s3.S3
 	authIsBroken bool
+
+	// partSize is the smallest part size the server accepts, if it is
+	// not s3.MinPartSize.
+	partSize int64
+}
+
+func (s *ClientTests) minPartSize() int64 {
+	if s.partSize > 0 {
+		return s.partSize
+	}
+	return s3.MinPartSize
 }
 
 func (s *ClientTests) Cleanup() {
@@ -132,6 +146,12 @@ func killBucket(b *s3.Bucket) {
 			for _, m := range multis {
 				_ = m.Abort()
 			}
+			// Versioned buckets keep old versions and delete markers.
+			if vs, err := b.Versions("", "", "", "", 1000); err == nil {
+				for _, v := range append(vs.Versions, vs.DeleteMarkers...) {
+					_, _ = b.DelVersion(v.Key, v.VersionId)
+				}
+			}
 		}
 	}
 	message := "cannot delete test bucket"
@@ -388,6 +408,563 @@ func (s *ClientTests) TestBucketList(c *check.C) {
 	}
 }
 
+func (s *ClientTests) TestObjectIter(c *check.C) {
+	b := testBucket(s.s3)
+	err := b.PutBucket(s3.Private)
+	c.Assert(err, check.IsNil)
+
+	for _, path := range objectNames {
+		err := b.Put(path, nil, "text/plain", s3.Private, s3.Options{})
+		c.Assert(err, check.IsNil)
+		defer b.Del(path)
+	}
+
+	// Small pages force the iterators to follow continuation tokens.
+	var names []string
+	iter := b.ObjectIter(s3.ListV2Options{MaxKeys: 2})
+	for iter.Next() {
+		names = append(names, iter.Key().Key)
+	}
+	c.Assert(iter.Err(), check.IsNil)
+	c.Assert(names, check.DeepEquals, objectNames)
+
+	names = nil
+	iter = b.ObjectIter(s3.ListV2Options{MaxKeys: 1, Delimiter: "/"})
+	for iter.Next() {
+		names = append(names, iter.Key().Key)
+	}
+	c.Assert(iter.Err(), check.IsNil)
+	c.Assert(names, check.DeepEquals, []string{"index.html", "index2.html"})
+
+	var prefixes []string
+	piter := b.PrefixIter(s3.ListV2Options{MaxKeys: 1, Delimiter: "/", Prefix: "photos/2006/"})
+	for piter.Next() {
+		prefixes = append(prefixes, piter.Prefix())
+	}
+	c.Assert(piter.Err(), check.IsNil)
+	c.Assert(prefixes, check.DeepEquals, []string{"photos/2006/February/", "photos/2006/January/"})
+
+	resp, err := b.ListV2(s3.ListV2Options{StartAfter: "photos/2006/January/sample.jpg", Delimiter: "/"})
+	c.Assert(err, check.IsNil)
+	c.Assert(resp.KeyCount, check.Equals, 1)
+	c.Assert(resp.CommonPrefixes, check.DeepEquals, []string{"test/"})
+}
+
+func (s *ClientTests) TestBucketConfiguration(c *check.C) {
+	b := testBucket(s.s3)
+	err := b.PutBucket(s3.Private)
+	c.Assert(err, check.IsNil)
+
+	_, err = b.GetBucketCORS()
+	c.Assert(err, check.FitsTypeOf, new(s3.Error))
+	c.Assert(err.(*s3.Error).Code, check.Equals, "NoSuchCORSConfiguration")
+
+	_, err = b.GetBucketReplication()
+	c.Assert(err, check.FitsTypeOf, new(s3.Error))
+	c.Assert(err.(*s3.Error).Code, check.Equals, "ReplicationConfigurationNotFoundError")
+
+	cors := s3.CORSConfiguration{CORSRules: []s3.CORSRule{{
+		AllowedOrigins: []string{"https://example.com"},
+		AllowedMethods: []string{"GET", "HEAD"},
+		MaxAgeSeconds:  300,
+	}}}
+	c.Assert(b.PutBucketCORS(cors), check.IsNil)
+	gotCORS, err := b.GetBucketCORS()
+	c.Assert(err, check.IsNil)
+	c.Assert(gotCORS.CORSRules, check.DeepEquals, cors.CORSRules)
+	c.Assert(b.DeleteBucketCORS(), check.IsNil)
+
+	tagging := s3.Tagging{TagSet: []s3.Tag{{Key: "team", Value: "storage"}}}
+	c.Assert(b.PutBucketTagging(tagging), check.IsNil)
+	gotTagging, err := b.GetBucketTagging()
+	c.Assert(err, check.IsNil)
+	c.Assert(gotTagging.TagSet, check.DeepEquals, tagging.TagSet)
+	c.Assert(b.DeleteBucketTagging(), check.IsNil)
+
+	policy := `{"Version":"2012-10-17","Statement":[{"Effect":"Deny","Principal":"*","Action":"s3:DeleteBucket","Resource":"arn:aws:s3:::` + b.Name + `"}]}`
+	c.Assert(b.PutBucketPolicy([]byte(policy)), check.IsNil)
+	gotPolicy, err := b.GetBucketPolicy()
+	c.Assert(err, check.IsNil)
+	c.Assert(string(gotPolicy), check.Matches, `.*"Action":"s3:DeleteBucket".*`)
+	c.Assert(b.DeleteBucketPolicy(), check.IsNil)
+	_, err = b.GetBucketPolicy()
+	c.Assert(err, check.FitsTypeOf, new(s3.Error))
+	c.Assert(err.(*s3.Error).Code, check.Equals, "NoSuchBucketPolicy")
+}
+
+func (s *ClientTests) TestACL(c *check.C) {
+	b := testBucket(s.s3)
+	err := b.PutBucket(s3.Private)
+	c.Assert(err, check.IsNil)
+
+	policy, err := b.GetBucketACL()
+	c.Assert(err, check.IsNil)
+	c.Assert(policy.Owner.ID, check.Not(check.Equals), "")
+	c.Assert(policy.Grants, check.HasLen, 1)
+	c.Assert(policy.Grants[0].Grantee.ID, check.Equals, policy.Owner.ID)
+	c.Assert(policy.Grants[0].Permission, check.Equals, s3.PermFullControl)
+	owner := policy.Owner
+
+	err = b.Put("name", []byte("yo!"), "text/plain", s3.PublicRead, s3.Options{})
+	c.Assert(err, check.IsNil)
+	defer b.Del("name")
+	policy, err = b.GetObjectACL("name")
+	c.Assert(err, check.IsNil)
+	c.Assert(policy.Grants, check.HasLen, 2)
+	c.Assert(policy.Grants[1].Grantee.URI, check.Equals, s3.AllUsersGroup)
+	c.Assert(policy.Grants[1].Permission, check.Equals, s3.PermRead)
+
+	// Replace the grants: the owner keeps full control and the
+	// authenticated users group may read the ACL.
+	policy = &s3.AccessControlPolicy{Owner: owner, Grants: []s3.Grant{
+		{Grantee: s3.CanonicalUserGrantee(owner.ID), Permission: s3.PermFullControl},
+		{Grantee: s3.GroupGrantee(s3.AuthenticatedUsersGroup), Permission: s3.PermReadACP},
+	}}
+	err = b.PutObjectACL("name", *policy)
+	c.Assert(err, check.IsNil)
+	policy, err = b.GetObjectACL("name")
+	c.Assert(err, check.IsNil)
+	c.Assert(policy.Grants, check.HasLen, 2)
+	c.Assert(policy.Grants[1].Grantee, check.Equals, s3.GroupGrantee(s3.AuthenticatedUsersGroup))
+	c.Assert(policy.Grants[1].Permission, check.Equals, s3.PermReadACP)
+
+	options := s3.Options{Grants: []s3.Grant{
+		{Grantee: s3.CanonicalUserGrantee(owner.ID), Permission: s3.PermFullControl},
+		{Grantee: s3.GroupGrantee(s3.AllUsersGroup), Permission: s3.PermRead},
+	}}
+	err = b.Put("granted", []byte("yo!"), "text/plain", s3.Private, options)
+	c.Assert(err, check.IsNil)
+	defer b.Del("granted")
+	policy, err = b.GetObjectACL("granted")
+	c.Assert(err, check.IsNil)
+	c.Assert(policy.Grants, check.HasLen, 2)
+	c.Assert(policy.Grants[0].Grantee.ID, check.Equals, owner.ID)
+	c.Assert(policy.Grants[0].Permission, check.Equals, s3.PermFullControl)
+	c.Assert(policy.Grants[1].Grantee.URI, check.Equals, s3.AllUsersGroup)
+}
+
+func (s *ClientTests) TestObjectTagging(c *check.C) {
+	b := testBucket(s.s3)
+	err := b.PutBucket(s3.Private)
+	c.Assert(err, check.IsNil)
+
+	options := s3.Options{Tags: []s3.Tag{
+		{Key: "retention", Value: "30d"},
+		{Key: "team", Value: "data eng"},
+	}}
+	err = b.Put("name", []byte("yo!"), "text/plain", s3.Private, options)
+	c.Assert(err, check.IsNil)
+	defer b.Del("name")
+
+	resp, err := b.Head("name", nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(resp.Header.Get("x-amz-tagging-count"), check.Equals, "2")
+
+	tagging, err := b.GetObjectTagging("name")
+	c.Assert(err, check.IsNil)
+	c.Assert(tagging.TagSet, check.DeepEquals, options.Tags)
+
+	tags := []s3.Tag{{Key: "retention", Value: "1y"}}
+	err = b.PutObjectTagging("name", s3.Tagging{TagSet: tags})
+	c.Assert(err, check.IsNil)
+	tagging, err = b.GetObjectTagging("name")
+	c.Assert(err, check.IsNil)
+	c.Assert(tagging.TagSet, check.DeepEquals, tags)
+
+	err = b.DeleteObjectTagging("name")
+	c.Assert(err, check.IsNil)
+	tagging, err = b.GetObjectTagging("name")
+	c.Assert(err, check.IsNil)
+	c.Assert(tagging.TagSet, check.HasLen, 0)
+
+	_, err = b.GetObjectTagging("non-existent")
+	c.Assert(err, check.NotNil)
+	c.Assert(err.(*s3.Error).Code, check.Equals, "NoSuchKey")
+}
+
+func (s *ClientTests) TestConditionalGet(c *check.C) {
+	b := testBucket(s.s3)
+	err := b.PutBucket(s3.Private)
+	c.Assert(err, check.IsNil)
+
+	data := []byte("0123456789")
+	err = b.Put("name", data, "text/plain", s3.Private, s3.Options{})
+	c.Assert(err, check.IsNil)
+	defer b.Del("name")
+
+	resp, err := b.Head("name", nil)
+	c.Assert(err, check.IsNil)
+	tag := resp.Header.Get("ETag")
+	c.Assert(tag, check.Equals, etag(data))
+	lastModified, err := http.ParseTime(resp.Header.Get("Last-Modified"))
+	c.Assert(err, check.IsNil)
+
+	status := func(headers map[string][]string) int {
+		resp, err := b.GetResponseWithHeaders("name", headers)
+		if err != nil {
+			c.Assert(err, check.FitsTypeOf, new(s3.Error))
+			return err.(*s3.Error).StatusCode
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	c.Check(status(map[string][]string{"If-Match": {tag}}), check.Equals, 200)
+	c.Check(status(map[string][]string{"If-Match": {`"0123"`}}), check.Equals, 412)
+	c.Check(status(map[string][]string{"If-None-Match": {tag}}), check.Equals, 304)
+	c.Check(status(map[string][]string{"If-None-Match": {`"0123"`}}), check.Equals, 200)
+	c.Check(status(map[string][]string{"If-Modified-Since": {lastModified.Format(http.TimeFormat)}}), check.Equals, 304)
+	c.Check(status(map[string][]string{"If-Modified-Since": {lastModified.Add(-time.Hour).Format(http.TimeFormat)}}), check.Equals, 200)
+	c.Check(status(map[string][]string{"If-Unmodified-Since": {lastModified.Add(-time.Hour).Format(http.TimeFormat)}}), check.Equals, 412)
+
+	ranges := []struct {
+		header, contentRange, data string
+	}{
+		{"bytes=2-5", "bytes 2-5/10", "2345"},
+		{"bytes=7-", "bytes 7-9/10", "789"},
+		{"bytes=-3", "bytes 7-9/10", "789"},
+		{"bytes=8-20", "bytes 8-9/10", "89"},
+	}
+	for _, r := range ranges {
+		resp, err := b.GetResponseWithHeaders("name", map[string][]string{"Range": {r.header}})
+		c.Assert(err, check.IsNil)
+		got, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		c.Assert(err, check.IsNil)
+		c.Check(resp.StatusCode, check.Equals, 206)
+		c.Check(resp.Header.Get("Content-Range"), check.Equals, r.contentRange)
+		c.Check(string(got), check.Equals, r.data)
+	}
+	_, err = b.GetResponseWithHeaders("name", map[string][]string{"Range": {"bytes=10-"}})
+	c.Assert(err, check.FitsTypeOf, new(s3.Error))
+	c.Assert(err.(*s3.Error).Code, check.Equals, "InvalidRange")
+}
+
+func (s *ClientTests) TestVersioning(c *check.C) {
+	b := testBucket(s.s3)
+	err := b.PutBucket(s3.Private)
+	c.Assert(err, check.IsNil)
+
+	conf, err := b.GetBucketVersioning()
+	c.Assert(err, check.IsNil)
+	c.Assert(conf.Status, check.Equals, "")
+	err = b.PutBucketVersioning(s3.VersioningConfiguration{Status: s3.VersioningEnabled}, "")
+	c.Assert(err, check.IsNil)
+	conf, err = b.GetBucketVersioning()
+	c.Assert(err, check.IsNil)
+	c.Assert(conf.Status, check.Equals, s3.VersioningEnabled)
+
+	c.Assert(b.Put("name", []byte("one"), "text/plain", s3.Private, s3.Options{}), check.IsNil)
+	c.Assert(b.Put("name", []byte("two"), "text/plain", s3.Private, s3.Options{}), check.IsNil)
+
+	vs, err := b.Versions("", "", "", "", 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(vs.Versions, check.HasLen, 2)
+	c.Assert(vs.DeleteMarkers, check.HasLen, 0)
+	latest, first := vs.Versions[0], vs.Versions[1]
+	c.Assert(latest.IsLatest, check.Equals, true)
+	c.Assert(latest.ETag, check.Equals, etag([]byte("two")))
+	c.Assert(first.IsLatest, check.Equals, false)
+	data, err := b.GetVersion("name", first.VersionId)
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, "one")
+
+	resp, err := b.GetVersionResponse("name", "", nil)
+	c.Assert(err, check.IsNil)
+	resp.Body.Close()
+	c.Assert(s3.ResponseVersion(resp).VersionId, check.Equals, latest.VersionId)
+
+	// Pages are split between the versions of a key.
+	page, err := b.Versions("", "", "", "", 1)
+	c.Assert(err, check.IsNil)
+	c.Assert(page.IsTruncated, check.Equals, true)
+	c.Assert(page.Versions, check.HasLen, 1)
+	page, err = b.Versions("", "", page.NextKeyMarker, page.NextVersionIdMarker, 1)
+	c.Assert(err, check.IsNil)
+	c.Assert(page.Versions, check.HasLen, 1)
+	c.Assert(page.Versions[0].VersionId, check.Equals, first.VersionId)
+
+	// Deleting the object adds a delete marker.
+	info, err := b.DelVersion("name", "")
+	c.Assert(err, check.IsNil)
+	c.Assert(info.DeleteMarker, check.Equals, true)
+	_, err = b.Get("name")
+	c.Assert(err, check.FitsTypeOf, new(s3.Error))
+	c.Assert(err.(*s3.Error).Code, check.Equals, "NoSuchKey")
+	_, err = b.GetVersion("name", info.VersionId)
+	c.Assert(err, check.FitsTypeOf, new(s3.Error))
+	c.Assert(err.(*s3.Error).StatusCode, check.Equals, 405)
+
+	vs, err = b.Versions("", "", "", "", 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(vs.Versions, check.HasLen, 2)
+	c.Assert(vs.DeleteMarkers, check.HasLen, 1)
+	c.Assert(vs.DeleteMarkers[0].VersionId, check.Equals, info.VersionId)
+	c.Assert(vs.DeleteMarkers[0].IsLatest, check.Equals, true)
+
+	// Removing the delete marker brings the object back.
+	info, err = b.DelVersion("name", info.VersionId)
+	c.Assert(err, check.IsNil)
+	c.Assert(info.DeleteMarker, check.Equals, true)
+	data, err = b.Get("name")
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, "two")
+
+	for _, v := range []string{latest.VersionId, first.VersionId} {
+		_, err = b.DelVersion("name", v)
+		c.Assert(err, check.IsNil)
+	}
+	vs, err = b.Versions("", "", "", "", 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(vs.Versions, check.HasLen, 0)
+}
+
+// This may take a minute or more due to the minimum size accepted S3
+// on multipart upload parts.
+func (s *ClientTests) TestMultiPartCopy(c *check.C) {
+	b := testBucket(s.s3)
+	err := b.PutBucket(s3.Private)
+	c.Assert(err, check.IsNil)
+
+	partSize := int(s.minPartSize())
+	source := make([]byte, partSize+10)
+	for i := range source {
+		source[i] = byte(i)
+	}
+	err = b.Put("source", source, "application/octet-stream", s3.Private, s3.Options{})
+	c.Assert(err, check.IsNil)
+
+	options := s3.Options{Meta: map[string][]string{"origin": {"parts"}}}
+	multi, err := b.InitMulti("multi", "application/octet-stream", s3.Private, options)
+	c.Assert(err, check.IsNil)
+	defer multi.Abort()
+
+	_, err = multi.PutPart(0, strings.NewReader("<part 0>"))
+	c.Assert(err, check.NotNil)
+	c.Assert(err.(*s3.Error).Code, check.Equals, "InvalidArgument")
+
+	// Parts may be sent in any order.
+	part3, err := multi.PutPart(3, strings.NewReader("<part 3>"))
+	c.Assert(err, check.IsNil)
+	_, part1, err := multi.PutPartCopy(1, s3.CopyOptions{CopySourceOptions: fmt.Sprintf("bytes=0-%d", partSize-1)}, b.Name+"/source")
+	c.Assert(err, check.IsNil)
+	c.Assert(part1.Size, check.Equals, int64(partSize))
+	_, part2, err := multi.PutPartCopy(2, s3.CopyOptions{}, b.Name+"/source")
+	c.Assert(err, check.IsNil)
+	c.Assert(part2.ETag, check.Equals, etag(source))
+
+	parts, err := multi.ListParts()
+	c.Assert(err, check.IsNil)
+	c.Assert(parts, check.HasLen, 3)
+	c.Assert(parts[0].ETag, check.Equals, etag(source[:partSize]))
+	c.Assert(parts[0].Size, check.Equals, int64(partSize))
+	c.Assert(parts[2].ETag, check.Equals, part3.ETag)
+
+	err = multi.Complete([]s3.Part{part1, part1, part3})
+	c.Assert(err, check.NotNil)
+	c.Assert(err.(*s3.Error).Code, check.Equals, "InvalidPartOrder")
+
+	err = multi.Complete([]s3.Part{part1, {N: 2, ETag: part3.ETag}, part3})
+	c.Assert(err, check.NotNil)
+	c.Assert(err.(*s3.Error).Code, check.Equals, "InvalidPart")
+
+	err = multi.Complete([]s3.Part{part1, part2, part3})
+	c.Assert(err, check.IsNil)
+
+	data, err := b.Get("multi")
+	c.Assert(err, check.IsNil)
+	want := append(append(source[:partSize:partSize], source...), "<part 3>"...)
+	c.Assert(bytes.Equal(data, want), check.Equals, true)
+
+	resp, err := b.Head("multi", nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(resp.Header.Get("ETag"), check.Matches, `"[0-9a-f]{32}-3"`)
+	c.Assert(resp.Header.Get("x-amz-meta-origin"), check.Equals, "parts")
+	c.Assert(resp.Header.Get("Content-Type"), check.Equals, "application/octet-stream")
+}
+
+func (s *ClientTests) TestDeletePrefixBatches(c *check.C) {
+	b := testBucket(s.s3)
+	err := b.PutBucket(s3.Private)
+	c.Assert(err, check.IsNil)
+
+	for i := 0; i < 25; i++ {
+		err := b.Put(fmt.Sprintf("logs/%02d", i), []byte("log"), "text/plain", s3.Private, s3.Options{})
+		c.Assert(err, check.IsNil)
+	}
+	c.Assert(b.Put("keep", []byte("data"), "text/plain", s3.Private, s3.Options{}), check.IsNil)
+	defer b.Del("keep")
+
+	summary, err := b.DeletePrefix("logs/", s3.DeleteOptions{Concurrency: 3, BatchSize: 10})
+	c.Assert(err, check.IsNil)
+	c.Assert(summary, check.DeepEquals, &s3.DeleteSummary{Deleted: 25})
+
+	resp, err := b.List("", "", "", 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(resp.Contents, check.HasLen, 1)
+	c.Assert(resp.Contents[0].Key, check.Equals, "keep")
+}
+
+func (s *ClientTests) TestEmptyBucket(c *check.C) {
+	b := testBucket(s.s3)
+	err := b.PutBucket(s3.Private)
+	c.Assert(err, check.IsNil)
+	c.Assert(b.PutBucketVersioning(s3.VersioningConfiguration{Status: s3.VersioningEnabled}, ""), check.IsNil)
+
+	for _, key := range []string{"a", "a", "b", "c"} {
+		c.Assert(b.Put(key, []byte(key), "text/plain", s3.Private, s3.Options{}), check.IsNil)
+	}
+	c.Assert(b.Del("c"), check.IsNil)
+
+	// The bucket is versioned, so both versions of a go.
+	summary, err := b.DeletePrefix("a", s3.DeleteOptions{})
+	c.Assert(err, check.IsNil)
+	c.Assert(summary, check.DeepEquals, &s3.DeleteSummary{Deleted: 2})
+	c.Assert(b.DelBucket(), check.NotNil)
+
+	// b, c and the delete marker of c remain.
+	summary, err = b.EmptyBucket(s3.DeleteOptions{Concurrency: 2, BatchSize: 2})
+	c.Assert(err, check.IsNil)
+	c.Assert(summary, check.DeepEquals, &s3.DeleteSummary{Deleted: 3})
+	c.Assert(b.DelBucket(), check.IsNil)
+}
+
+func (s *ClientTests) TestCopyMultipart(c *check.C) {
+	b := testBucket(s.s3)
+	err := b.PutBucket(s3.Private)
+	c.Assert(err, check.IsNil)
+
+	content := bytes.Repeat([]byte("0123456789"), int(s.minPartSize())*2/10+100)
+	options := s3.Options{
+		Meta: map[string][]string{"color": {"blue"}},
+		Tags: []s3.Tag{{Key: "team", Value: "storage"}},
+	}
+	c.Assert(b.Put("big", content, "application/octet-stream", s3.Private, options), check.IsNil)
+	defer b.Del("big")
+
+	copyOptions := s3.CopyObjectOptions{MultipartThreshold: s.minPartSize(), Concurrency: 2}
+	res, err := b.Copy("copy", s3.Private, b, "big", copyOptions)
+	c.Assert(err, check.IsNil)
+	defer b.Del("copy")
+	c.Assert(strings.HasSuffix(res.ETag, `-3"`), check.Equals, true)
+	data, err := b.Get("copy")
+	c.Assert(err, check.IsNil)
+	c.Assert(bytes.Equal(data, content), check.Equals, true)
+
+	// The metadata and tags of the source are kept...
+	resp, err := b.Head("copy", nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(resp.Header.Get("Content-Type"), check.Equals, "application/octet-stream")
+	c.Assert(resp.Header.Get("x-amz-meta-color"), check.Equals, "blue")
+	tagging, err := b.GetObjectTagging("copy")
+	c.Assert(err, check.IsNil)
+	c.Assert(tagging.TagSet, check.DeepEquals, options.Tags)
+
+	// ...unless they are replaced.
+	copyOptions.MetadataDirective = s3.MetadataDirectiveReplace
+	copyOptions.TaggingDirective = s3.TaggingDirectiveReplace
+	copyOptions.ContentType = "text/plain"
+	copyOptions.Meta = map[string][]string{"color": {"red"}}
+	_, err = b.Copy("copy", s3.Private, b, "big", copyOptions)
+	c.Assert(err, check.IsNil)
+	resp, err = b.Head("copy", nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(resp.Header.Get("Content-Type"), check.Equals, "text/plain")
+	c.Assert(resp.Header.Get("x-amz-meta-color"), check.Equals, "red")
+	tagging, err = b.GetObjectTagging("copy")
+	c.Assert(err, check.IsNil)
+	c.Assert(tagging.TagSet, check.HasLen, 0)
+
+	uploads, _, err := b.ListMulti("", "")
+	c.Assert(err, check.IsNil)
+	c.Assert(uploads, check.HasLen, 0)
+}
+
+// failingReaderAt fails to read the bytes of r from offset on.
+type failingReaderAt struct {
+	r      *bytes.Reader
+	offset int64
+}
+
+func (f *failingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off+int64(len(p)) > f.offset {
+		return 0, errors.New("read failed")
+	}
+	return f.r.ReadAt(p, off)
+}
+
+func (s *ClientTests) TestPutResumable(c *check.C) {
+	b := testBucket(s.s3)
+	err := b.PutBucket(s3.Private)
+	c.Assert(err, check.IsNil)
+
+	content := bytes.Repeat([]byte("0123456789"), int(s.minPartSize())*2/10+100)
+	size := int64(len(content))
+	store := s3.NewFileCheckpointStore(filepath.Join(c.MkDir(), "upload.json"))
+	options := s3.ResumableOptions{ContentType: "text/plain"}
+
+	// The third part cannot be read.
+	r := &failingReaderAt{bytes.NewReader(content), 2 * s.minPartSize()}
+	err = b.PutResumable("big", r, size, s3.Private, store, options)
+	c.Assert(err, check.ErrorMatches, "read failed")
+	cp, err := store.Load()
+	c.Assert(err, check.IsNil)
+	c.Assert(cp.Key, check.Equals, "big")
+	c.Assert(cp.PartSize, check.Equals, s.minPartSize())
+	c.Assert(cp.Parts, check.HasLen, 2)
+
+	// Only the third part is sent when resuming, after the first two
+	// are reported as reused.
+	var progress []s3.PutAllProgress
+	options.Progress = func(p s3.PutAllProgress) { progress = append(progress, p) }
+	err = b.PutResumable("big", bytes.NewReader(content), size, s3.Private, store, options)
+	c.Assert(err, check.IsNil)
+	defer b.Del("big")
+	c.Assert(progress, check.HasLen, 3)
+	for i, p := range progress {
+		c.Assert(p.Part.N, check.Equals, i+1)
+		c.Assert(p.Reused, check.Equals, i < 2)
+		c.Assert(p.PartsDone, check.Equals, i+1)
+	}
+	c.Assert(progress[2].BytesDone, check.Equals, size)
+
+	data, err := b.Get("big")
+	c.Assert(err, check.IsNil)
+	c.Assert(bytes.Equal(data, content), check.Equals, true)
+	_, err = os.Stat(store.Path)
+	c.Assert(os.IsNotExist(err), check.Equals, true)
+	multis, _, err := b.ListMulti("", "")
+	c.Assert(err, check.IsNil)
+	c.Assert(multis, check.HasLen, 0)
+}
+
+func (s *ClientTests) TestPutResumableRestarts(c *check.C) {
+	b := testBucket(s.s3)
+	err := b.PutBucket(s3.Private)
+	c.Assert(err, check.IsNil)
+
+	store := s3.NewFileCheckpointStore(filepath.Join(c.MkDir(), "upload.json"))
+
+	// A checkpoint for another upload is an error.
+	cp := &s3.Checkpoint{Bucket: b.Name, Key: "other", UploadId: "gone", Size: 5, PartSize: s.minPartSize()}
+	c.Assert(store.Save(cp), check.IsNil)
+	err = b.PutResumable("small", bytes.NewReader([]byte("hello")), 5, s3.Private, store, s3.ResumableOptions{})
+	c.Assert(err, check.ErrorMatches, fmt.Sprintf(`s3: checkpoint is for 5 bytes to %s/other, not 5 bytes to %s/small`, b.Name, b.Name))
+
+	// A checkpoint whose upload no longer exists starts a new upload.
+	cp.Key = "small"
+	cp.Parts = []s3.Part{{N: 1, ETag: `"5d41402abc4b2a76b9719d911017c592"`, Size: 5}}
+	c.Assert(store.Save(cp), check.IsNil)
+	err = b.PutResumable("small", bytes.NewReader([]byte("hello")), 5, s3.Private, store, s3.ResumableOptions{})
+	c.Assert(err, check.IsNil)
+	defer b.Del("small")
+	data, err := b.Get("small")
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, "hello")
+	cp, err = store.Load()
+	c.Assert(err, check.IsNil)
+	c.Assert(cp, check.IsNil)
+}
+
 func etag(data []byte) string {
 	sum := md5.New()
 	sum.Write(data)
@@ -462,7 +1039,7 @@ func (s *ClientTests) TestMultiComplete(c *check.C) {
 	defer multi.Abort()
 
 	// Minimum size S3 accepts for all but the last part is 5MB.
-	data1 := make([]byte, 5*1024*1024)
+	data1 := make([]byte, s.minPartSize())
 	data2 := []byte("<part 2>")
 
 	part1, err := multi.PutPart(1, bytes.NewReader(data1))
@@ -0,0 +1,120 @@
+//go:build go1.16
+// +build go1.16
+
+// Command s3sync synchronizes a local directory with the objects under a
+// prefix of an S3 bucket.
+//
+// Usage:
+//
+//	s3sync [flags] <dir> s3://<bucket>/<prefix>
+//	s3sync [flags] s3://<bucket>/<prefix> <dir>
+//
+// The first form uploads the files of the directory that are missing from
+// the bucket or differ there, and the second downloads the objects that
+// are missing from the directory or differ there. Credentials are taken
+// from the environment or the instance role, as by aws.GetAuth.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/AdRoll/goamz/aws"
+	"github.com/AdRoll/goamz/s3"
+	"github.com/AdRoll/goamz/s3/s3sync"
+)
+
+// patterns is a flag that can be repeated.
+type patterns []string
+
+func (p *patterns) String() string { return strings.Join(*p, ",") }
+
+func (p *patterns) Set(v string) error {
+	*p = append(*p, v)
+	return nil
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: s3sync [flags] <dir> s3://<bucket>/<prefix>\n")
+	fmt.Fprintf(os.Stderr, "       s3sync [flags] s3://<bucket>/<prefix> <dir>\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	var include, exclude patterns
+	flag.Var(&include, "include", "only sync the files matching `pattern`; may be repeated")
+	flag.Var(&exclude, "exclude", "do not sync the files matching `pattern`; may be repeated")
+	region := flag.String("region", "us-east-1", "the region of the bucket")
+	del := flag.Bool("delete", false, "delete the destination files missing from the source")
+	dryRun := flag.Bool("dryrun", false, "show what would be done without doing it")
+	checksum := flag.Bool("checksum", false, "compare files of the same size by MD5 rather than modification time")
+	concurrency := flag.Int("concurrency", 4, "the number of files transferred in parallel")
+	acl := flag.String("acl", string(s3.Private), "the canned ACL of the uploaded objects")
+	flag.Usage = usage
+	flag.Parse()
+	log.SetFlags(0)
+	log.SetPrefix("s3sync: ")
+
+	if flag.NArg() != 2 {
+		usage()
+		os.Exit(2)
+	}
+	r, ok := aws.Regions[*region]
+	if !ok {
+		log.Fatalf("unknown region %q", *region)
+	}
+	auth, err := aws.GetAuth("", "", "", time.Time{})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	verb := ""
+	if *dryRun {
+		verb = "(dryrun) "
+	}
+	options := s3sync.Options{
+		Include:     include,
+		Exclude:     exclude,
+		Delete:      *del,
+		DryRun:      *dryRun,
+		Checksum:    *checksum,
+		Concurrency: *concurrency,
+		ACL:         s3.ACL(*acl),
+		Progress: func(a s3sync.Action) {
+			fmt.Printf("%s%s %s\n", verb, a.Op, a.Path)
+		},
+	}
+	src, dst := flag.Arg(0), flag.Arg(1)
+	var summary *s3sync.Summary
+	switch {
+	case isURL(dst) && !isURL(src):
+		bucket, prefix := parseURL(dst)
+		summary, err = s3sync.Upload(src, s3.New(auth, r).Bucket(bucket), prefix, options)
+	case isURL(src) && !isURL(dst):
+		bucket, prefix := parseURL(src)
+		summary, err = s3sync.Download(s3.New(auth, r).Bucket(bucket), prefix, dst, options)
+	default:
+		log.Fatal("exactly one of the source and the destination must be an s3:// URL")
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%s%d changed, %d unchanged\n", verb, len(summary.Actions), summary.Unchanged)
+}
+
+func isURL(s string) bool {
+	return strings.HasPrefix(s, "s3://")
+}
+
+// parseURL returns the bucket and prefix of the URL s3://<bucket>/<prefix>.
+func parseURL(s string) (bucket, prefix string) {
+	s = strings.TrimPrefix(s, "s3://")
+	if i := strings.Index(s, "/"); i >= 0 {
+		return s[:i], s[i+1:]
+	}
+	return s, ""
+}
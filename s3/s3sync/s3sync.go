@@ -0,0 +1,484 @@
+//go:build go1.16
+// +build go1.16
+
+// Package s3sync synchronizes a local directory with the objects under a
+// prefix of an S3 bucket, in the manner of rsync.
+//
+// Upload copies to the bucket the files of the directory that are missing
+// or differ there, and Download does the same the other way around. The
+// file "a/b.txt" of the directory is the object prefix+"a/b.txt". Files
+// differ if their sizes differ or, for files of the same size, if the
+// source is newer than the destination. With Options.Checksum set, files
+// of the same size are compared by MD5 instead, using the ETag of objects
+// uploaded in a single request.
+package s3sync
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AdRoll/goamz/s3"
+	"github.com/AdRoll/goamz/s3/internal/pool"
+)
+
+// Op is the kind of an Action.
+type Op string
+
+const (
+	OpUpload   Op = "upload"   // Upload a file to the bucket.
+	OpDownload Op = "download" // Download an object to the directory.
+	OpDelete   Op = "delete"   // Delete a file missing from the source.
+)
+
+// Action is a change made to the destination of a sync.
+type Action struct {
+	Op   Op
+	Path string // The slash-separated path of the file in the directory.
+	Size int64
+}
+
+// Options controls a sync.
+type Options struct {
+	// Include, if set, restricts the sync to the files matched by one of
+	// its patterns, and Exclude leaves out the files matched by one of
+	// its patterns. Patterns have the syntax of path.Match. A pattern
+	// without a slash, such as "*.log", is matched against the name of
+	// the file and of every directory above it; other patterns, such as
+	// "logs/*.gz", are matched against the path of the file and of every
+	// directory above it, relative to the synced directory.
+	Include []string
+	Exclude []string
+
+	// Delete removes the files of the destination that are missing from
+	// the source. Files left out by Include and Exclude are kept.
+	Delete bool
+
+	// DryRun reports the actions a sync would make without making them.
+	DryRun bool
+
+	// Checksum compares files of the same size by MD5 rather than by
+	// modification time. Objects uploaded in parts have no MD5 ETag and
+	// are still compared by modification time.
+	Checksum bool
+
+	// Concurrency is the number of files transferred in parallel. It
+	// defaults to 1.
+	Concurrency int
+
+	// PartSize is the size of the parts files larger than it are
+	// uploaded and downloaded in. It defaults to s3.MinPartSize.
+	PartSize int64
+
+	// ACL and Options apply to the uploaded objects. ACL defaults to
+	// s3.Private. The content type of every object is detected from the
+	// extension of the file, or else from its first bytes.
+	ACL     s3.ACL
+	Options s3.Options
+
+	// Progress, if set, is called after each action is made, or planned
+	// with DryRun. Calls are serialized.
+	Progress func(Action)
+}
+
+// Summary reports the outcome of a sync.
+type Summary struct {
+	// Actions are the actions made, or planned with DryRun, with the
+	// transfers first and then the deletions, each ordered by path.
+	Actions []Action
+
+	// Unchanged is the number of files left alone, being the same in
+	// the source and the destination.
+	Unchanged int
+}
+
+// file describes a file in the directory or an object in the bucket.
+type file struct {
+	size  int64
+	mtime time.Time // Truncated to the second, the precision of S3.
+	etag  string    // Only set for objects.
+}
+
+// Upload syncs the bucket with the directory dir: the files of dir that
+// are missing from the bucket or differ there are uploaded under prefix,
+// and, if options.Delete is set, the objects under prefix that are
+// missing from dir are deleted.
+//
+// If a transfer fails, the remaining ones are cancelled and the error is
+// returned along with the summary of the actions already made.
+func Upload(dir string, b *s3.Bucket, prefix string, options Options) (*Summary, error) {
+	prefix = dirPrefix(prefix)
+	if err := checkPatterns(options); err != nil {
+		return nil, err
+	}
+	local, err := listLocal(dir, options)
+	if err != nil {
+		return nil, err
+	}
+	remote, err := listRemote(b, prefix, options)
+	if err != nil {
+		return nil, err
+	}
+	summary := &Summary{}
+	var actions []Action
+	for _, name := range sortedNames(local) {
+		l := local[name]
+		r, ok := remote[name]
+		if ok {
+			changed, err := differ(filepath.Join(dir, filepath.FromSlash(name)), l, r, l.mtime.After(r.mtime), options.Checksum)
+			if err != nil {
+				return nil, err
+			}
+			if !changed {
+				summary.Unchanged++
+				continue
+			}
+		}
+		actions = append(actions, Action{Op: OpUpload, Path: name, Size: l.size})
+	}
+	if options.Delete {
+		for _, name := range sortedNames(remote) {
+			if _, ok := local[name]; !ok {
+				actions = append(actions, Action{Op: OpDelete, Path: name, Size: remote[name].size})
+			}
+		}
+	}
+	return summary, run(summary, actions, options, func(a Action) error {
+		if a.Op == OpDelete {
+			return b.Del(prefix + a.Path)
+		}
+		return upload(b, prefix+a.Path, filepath.Join(dir, filepath.FromSlash(a.Path)), options)
+	})
+}
+
+// Download syncs the directory dir with the bucket: the objects under
+// prefix that are missing from dir or differ there are downloaded, with
+// the modification time of the files set to that of the objects, and, if
+// options.Delete is set, the files of dir that are missing from the
+// bucket are deleted. Objects whose keys are not valid file paths, such
+// as "a//b" or "../a", are left out.
+//
+// If a transfer fails, the remaining ones are cancelled and the error is
+// returned along with the summary of the actions already made.
+func Download(b *s3.Bucket, prefix string, dir string, options Options) (*Summary, error) {
+	prefix = dirPrefix(prefix)
+	if err := checkPatterns(options); err != nil {
+		return nil, err
+	}
+	remote, err := listRemote(b, prefix, options)
+	if err != nil {
+		return nil, err
+	}
+	local, err := listLocal(dir, options)
+	if os.IsNotExist(err) {
+		local, err = nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	summary := &Summary{}
+	var actions []Action
+	for _, name := range sortedNames(remote) {
+		r := remote[name]
+		l, ok := local[name]
+		if ok {
+			changed, err := differ(filepath.Join(dir, filepath.FromSlash(name)), l, r, r.mtime.After(l.mtime), options.Checksum)
+			if err != nil {
+				return nil, err
+			}
+			if !changed {
+				summary.Unchanged++
+				continue
+			}
+		}
+		actions = append(actions, Action{Op: OpDownload, Path: name, Size: r.size})
+	}
+	if options.Delete {
+		for _, name := range sortedNames(local) {
+			if _, ok := remote[name]; !ok {
+				actions = append(actions, Action{Op: OpDelete, Path: name, Size: local[name].size})
+			}
+		}
+	}
+	return summary, run(summary, actions, options, func(a Action) error {
+		path := filepath.Join(dir, filepath.FromSlash(a.Path))
+		if a.Op == OpDelete {
+			return os.Remove(path)
+		}
+		return download(b, prefix+a.Path, path, remote[a.Path].mtime, options)
+	})
+}
+
+// dirPrefix returns prefix ending with a slash, unless it is empty.
+func dirPrefix(prefix string) string {
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return prefix
+}
+
+// differ reports whether the local file at path, described by l, and the
+// object described by r differ. newer tells whether the source is newer
+// than the destination.
+func differ(path string, l, r file, newer, checksum bool) (bool, error) {
+	if l.size != r.size {
+		return true, nil
+	}
+	etag := strings.Trim(r.etag, `"`)
+	if !checksum || len(etag) != 2*md5.Size || strings.Contains(etag, "-") {
+		return newer, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	h := md5.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return false, err
+	}
+	return hex.EncodeToString(h.Sum(nil)) != etag, nil
+}
+
+// checkPatterns checks the syntax of the patterns of options.
+func checkPatterns(options Options) error {
+	for _, patterns := range [][]string{options.Include, options.Exclude} {
+		for _, p := range patterns {
+			if _, err := path.Match(p, ""); err != nil {
+				return fmt.Errorf("s3sync: bad pattern %q", p)
+			}
+		}
+	}
+	return nil
+}
+
+// selected reports whether the file name is selected by the Include and
+// Exclude patterns of options.
+func selected(name string, options Options) bool {
+	if len(options.Include) > 0 && !matchAny(options.Include, name) {
+		return false
+	}
+	return !matchAny(options.Exclude, name)
+}
+
+func matchAny(patterns []string, name string) bool {
+	elems := strings.Split(name, "/")
+	for _, p := range patterns {
+		for i := range elems {
+			target := elems[i]
+			if strings.Contains(p, "/") {
+				target = strings.Join(elems[:i+1], "/")
+			}
+			if ok, _ := path.Match(p, target); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// listLocal returns the regular files of dir selected by options, by
+// slash-separated path.
+func listLocal(dir string, options Options) (map[string]file, error) {
+	files := make(map[string]file)
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		if selected(name, options) {
+			files[name] = file{size: fi.Size(), mtime: fi.ModTime().Truncate(time.Second)}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// listRemote returns the objects under prefix selected by options, by
+// path relative to prefix. Keys ending with a slash, which stand for
+// directories, and keys that are not valid paths are left out.
+func listRemote(b *s3.Bucket, prefix string, options Options) (map[string]file, error) {
+	files := make(map[string]file)
+	iter := b.ObjectIter(s3.ListV2Options{Prefix: prefix})
+	for iter.Next() {
+		k := iter.Key()
+		name := k.Key[len(prefix):]
+		if !fs.ValidPath(name) || name == "." || !selected(name, options) {
+			continue
+		}
+		mtime, err := time.Parse(time.RFC3339, k.LastModified)
+		if err != nil {
+			return nil, fmt.Errorf("s3sync: bad modification time of %s: %v", k.Key, err)
+		}
+		files[name] = file{size: k.Size, mtime: mtime.Truncate(time.Second), etag: k.ETag}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+func sortedNames(files map[string]file) []string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// run makes the actions with do, up to options.Concurrency at a time,
+// recording those made in summary. The first error cancels the remaining
+// actions and is returned.
+func run(summary *Summary, actions []Action, options Options, do func(Action) error) error {
+	if options.DryRun {
+		for _, a := range actions {
+			if options.Progress != nil {
+				options.Progress(a)
+			}
+		}
+		summary.Actions = actions
+		return nil
+	}
+	var (
+		mu   sync.Mutex
+		done = make([]bool, len(actions))
+		g    = pool.New(options.Concurrency)
+	)
+	for i := range actions {
+		i := i
+		ok := g.Go(func() error {
+			if err := do(actions[i]); err != nil {
+				return err
+			}
+			mu.Lock()
+			done[i] = true
+			if options.Progress != nil {
+				options.Progress(actions[i])
+			}
+			mu.Unlock()
+			return nil
+		})
+		if !ok {
+			break
+		}
+	}
+	err := g.Wait()
+
+	for i, a := range actions {
+		if done[i] {
+			summary.Actions = append(summary.Actions, a)
+		}
+	}
+	return err
+}
+
+// upload uploads the file at path to key, in parts if it is larger than
+// options.PartSize.
+func upload(b *s3.Bucket, key, path string, options Options) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	contType, err := contentType(f)
+	if err != nil {
+		return err
+	}
+	perm := options.ACL
+	if perm == "" {
+		perm = s3.Private
+	}
+	partSize := options.PartSize
+	if partSize <= 0 {
+		partSize = s3.MinPartSize
+	}
+	if fi.Size() <= partSize {
+		return b.PutReader(key, f, fi.Size(), contType, perm, options.Options)
+	}
+	m, err := b.InitMulti(key, contType, perm, options.Options)
+	if err != nil {
+		return err
+	}
+	// Leave no unfinished upload behind to be billed for its parts.
+	parts, err := m.PutAllConcurrent(f, fi.Size(), s3.PutAllOptions{PartSize: partSize})
+	if err == nil {
+		err = m.Complete(parts)
+	}
+	if err != nil {
+		m.Abort()
+	}
+	return err
+}
+
+// contentType returns the content type of f, detected from its extension
+// or else from its first bytes. The offset of f is left at the start.
+func contentType(f *os.File) (string, error) {
+	if t := mime.TypeByExtension(filepath.Ext(f.Name())); t != "" {
+		return t, nil
+	}
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return http.DetectContentType(head[:n]), nil
+}
+
+// download downloads the object at key to the file at path, replacing it
+// only once complete, and sets the modification time of the file to
+// mtime.
+func download(b *s3.Bucket, key, path string, mtime time.Time, options Options) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	_, _, err = b.Download(key, f, s3.DownloadOptions{PartSize: options.PartSize})
+	if err == nil {
+		// TempFile creates the file readable by its owner only.
+		err = f.Chmod(0644)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Chtimes(path, mtime, mtime)
+}
@@ -0,0 +1,244 @@
+//go:build go1.16
+// +build go1.16
+
+package s3sync_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/AdRoll/goamz/aws"
+	"github.com/AdRoll/goamz/s3"
+	"github.com/AdRoll/goamz/s3/s3sync"
+	"github.com/AdRoll/goamz/s3/s3test"
+	"github.com/AdRoll/goamz/testutil"
+	"gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) {
+	check.TestingT(t)
+}
+
+type S struct {
+	srv    *s3test.Server
+	bucket *s3.Bucket
+}
+
+var _ = check.Suite(&S{})
+
+func (s *S) SetUpTest(c *check.C) {
+	srv, err := s3test.NewServer(nil)
+	c.Assert(err, check.IsNil)
+	s.srv = srv
+	region := aws.Region{Name: "faux-region-1", S3Endpoint: srv.URL(), S3LocationConstraint: true}
+	s.bucket = s3.New(aws.Auth{AccessKey: "abc", SecretKey: "123"}, region).Bucket("bucket")
+	c.Assert(s.bucket.PutBucket(s3.Private), check.IsNil)
+}
+
+func (s *S) TearDownTest(c *check.C) {
+	s.srv.Quit()
+}
+
+// writeFiles writes the files of dir with the given paths and contents.
+func writeFiles(c *check.C, dir string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		c.Assert(os.MkdirAll(filepath.Dir(path), 0777), check.IsNil)
+		c.Assert(ioutil.WriteFile(path, []byte(content), 0666), check.IsNil)
+	}
+}
+
+// readFiles returns the contents of the files of dir by path.
+func readFiles(c *check.C, dir string) map[string]string {
+	files := make(map[string]string)
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() {
+			return err
+		}
+		data, err := ioutil.ReadFile(path)
+		rel, _ := filepath.Rel(dir, path)
+		files[filepath.ToSlash(rel)] = string(data)
+		return err
+	})
+	c.Assert(err, check.IsNil)
+	return files
+}
+
+func (s *S) keys(c *check.C) []string {
+	resp, err := s.bucket.List("", "", "", 0)
+	c.Assert(err, check.IsNil)
+	var keys []string
+	for _, k := range resp.Contents {
+		keys = append(keys, k.Key)
+	}
+	return keys
+}
+
+func (s *S) TestUpload(c *check.C) {
+	dir := c.MkDir()
+	writeFiles(c, dir, map[string]string{
+		"index.html":   "<html></html>",
+		"a/b.txt":      "hello",
+		"a/c.log":      "log",
+		"tmp/d.txt":    "scratch",
+		"data/e.bin":   "\x00\x01\x02",
+		"data/f.plain": "plain text",
+	})
+	options := s3sync.Options{Exclude: []string{"*.log", "tmp"}, Concurrency: 3}
+
+	summary, err := s3sync.Upload(dir, s.bucket, "site", options)
+	c.Assert(err, check.IsNil)
+	c.Assert(summary.Actions, check.DeepEquals, []s3sync.Action{
+		{s3sync.OpUpload, "a/b.txt", 5},
+		{s3sync.OpUpload, "data/e.bin", 3},
+		{s3sync.OpUpload, "data/f.plain", 10},
+		{s3sync.OpUpload, "index.html", 13},
+	})
+	c.Assert(s.keys(c), check.DeepEquals, []string{"site/a/b.txt", "site/data/e.bin", "site/data/f.plain", "site/index.html"})
+	for key, contType := range map[string]string{
+		"site/index.html":   "text/html; charset=utf-8",
+		"site/a/b.txt":      "text/plain; charset=utf-8",
+		"site/data/e.bin":   "application/octet-stream",
+		"site/data/f.plain": "text/plain; charset=utf-8",
+	} {
+		resp, err := s.bucket.Head(key, nil)
+		c.Assert(err, check.IsNil)
+		c.Assert(resp.Header.Get("Content-Type"), check.Equals, contType, check.Commentf("%s", key))
+	}
+
+	// Nothing changed.
+	summary, err = s3sync.Upload(dir, s.bucket, "site/", options)
+	c.Assert(err, check.IsNil)
+	c.Assert(summary.Actions, check.HasLen, 0)
+	c.Assert(summary.Unchanged, check.Equals, 4)
+
+	// A file of the same size changed later, one was removed.
+	writeFiles(c, dir, map[string]string{"a/b.txt": "HELLO"})
+	later := time.Now().Add(time.Hour)
+	c.Assert(os.Chtimes(filepath.Join(dir, "a", "b.txt"), later, later), check.IsNil)
+	c.Assert(os.Remove(filepath.Join(dir, "index.html")), check.IsNil)
+	options.Delete = true
+	options.DryRun = true
+	summary, err = s3sync.Upload(dir, s.bucket, "site", options)
+	c.Assert(err, check.IsNil)
+	want := []s3sync.Action{
+		{s3sync.OpUpload, "a/b.txt", 5},
+		{s3sync.OpDelete, "index.html", 13},
+	}
+	c.Assert(summary.Actions, check.DeepEquals, want)
+	c.Assert(s.keys(c), check.HasLen, 4)
+
+	options.DryRun = false
+	summary, err = s3sync.Upload(dir, s.bucket, "site", options)
+	c.Assert(err, check.IsNil)
+	c.Assert(summary.Actions, check.DeepEquals, want)
+	c.Assert(summary.Unchanged, check.Equals, 2)
+	c.Assert(s.keys(c), check.DeepEquals, []string{"site/a/b.txt", "site/data/e.bin", "site/data/f.plain"})
+	data, err := s.bucket.Get("site/a/b.txt")
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, "HELLO")
+}
+
+func (s *S) TestUploadChecksum(c *check.C) {
+	dir := c.MkDir()
+	writeFiles(c, dir, map[string]string{"a.txt": "hello"})
+	_, err := s3sync.Upload(dir, s.bucket, "", s3sync.Options{})
+	c.Assert(err, check.IsNil)
+
+	// Touching a file does not change its checksum.
+	later := time.Now().Add(time.Hour)
+	c.Assert(os.Chtimes(filepath.Join(dir, "a.txt"), later, later), check.IsNil)
+	summary, err := s3sync.Upload(dir, s.bucket, "", s3sync.Options{Checksum: true})
+	c.Assert(err, check.IsNil)
+	c.Assert(summary.Actions, check.HasLen, 0)
+
+	writeFiles(c, dir, map[string]string{"a.txt": "HELLO"})
+	summary, err = s3sync.Upload(dir, s.bucket, "", s3sync.Options{Checksum: true})
+	c.Assert(err, check.IsNil)
+	c.Assert(summary.Actions, check.DeepEquals, []s3sync.Action{{s3sync.OpUpload, "a.txt", 5}})
+}
+
+func (s *S) TestUploadMultipart(c *check.C) {
+	dir := c.MkDir()
+	content := make([]byte, s3.MinPartSize+10)
+	writeFiles(c, dir, map[string]string{"big": string(content)})
+	_, err := s3sync.Upload(dir, s.bucket, "", s3sync.Options{})
+	c.Assert(err, check.IsNil)
+	data, err := s.bucket.Get("big")
+	c.Assert(err, check.IsNil)
+	c.Assert(data, check.DeepEquals, content)
+}
+
+func (s *S) TestUploadMultipartAborts(c *check.C) {
+	dir := c.MkDir()
+	writeFiles(c, dir, map[string]string{"big": string(make([]byte, s3.MinPartSize+10))})
+	faults := &testutil.Faults{}
+	faults.Add(testutil.FaultRule{
+		Fault: testutil.ErrorFault(testutil.S3Protocol, 400, "InvalidPart", "One or more of the specified parts could not be found."),
+		Match: func(req *http.Request) bool {
+			return req.Method == "POST" && req.URL.Query().Get("uploadId") != ""
+		},
+	})
+	s.srv.SetFaults(faults)
+
+	_, err := s3sync.Upload(dir, s.bucket, "", s3sync.Options{})
+	c.Assert(err, check.ErrorMatches, "One or more of the specified parts could not be found.")
+	multis, _, err := s.bucket.ListMulti("", "")
+	c.Assert(err, check.IsNil)
+	c.Assert(multis, check.HasLen, 0)
+}
+
+func (s *S) TestDownload(c *check.C) {
+	for key, content := range map[string]string{
+		"site/a/b.txt":    "hello",
+		"site/index.html": "<html></html>",
+		"site/dir/":       "",
+		"site/x//y":       "invalid",
+		"other":           "other",
+	} {
+		c.Assert(s.bucket.Put(key, []byte(content), "text/plain", s3.Private, s3.Options{}), check.IsNil)
+	}
+	dir := filepath.Join(c.MkDir(), "site")
+
+	summary, err := s3sync.Download(s.bucket, "site", dir, s3sync.Options{Concurrency: 2})
+	c.Assert(err, check.IsNil)
+	c.Assert(summary.Actions, check.DeepEquals, []s3sync.Action{
+		{s3sync.OpDownload, "a/b.txt", 5},
+		{s3sync.OpDownload, "index.html", 13},
+	})
+	c.Assert(readFiles(c, dir), check.DeepEquals, map[string]string{
+		"a/b.txt":    "hello",
+		"index.html": "<html></html>",
+	})
+
+	// The files have the modification time of the objects.
+	summary, err = s3sync.Download(s.bucket, "site", dir, s3sync.Options{})
+	c.Assert(err, check.IsNil)
+	c.Assert(summary.Actions, check.HasLen, 0)
+	c.Assert(summary.Unchanged, check.Equals, 2)
+
+	// Extraneous files are deleted, excluded ones are kept.
+	writeFiles(c, dir, map[string]string{"extra.txt": "extra", "keep.log": "log"})
+	c.Assert(s.bucket.Put("site/a/b.txt", []byte("hello, world"), "text/plain", s3.Private, s3.Options{}), check.IsNil)
+	options := s3sync.Options{Delete: true, Exclude: []string{"*.log"}}
+	summary, err = s3sync.Download(s.bucket, "site", dir, options)
+	c.Assert(err, check.IsNil)
+	c.Assert(summary.Actions, check.DeepEquals, []s3sync.Action{
+		{s3sync.OpDownload, "a/b.txt", 12},
+		{s3sync.OpDelete, "extra.txt", 5},
+	})
+	c.Assert(readFiles(c, dir), check.DeepEquals, map[string]string{
+		"a/b.txt":    "hello, world",
+		"index.html": "<html></html>",
+		"keep.log":   "log",
+	})
+}
+
+func (s *S) TestBadPattern(c *check.C) {
+	_, err := s3sync.Upload(c.MkDir(), s.bucket, "", s3sync.Options{Include: []string{"["}})
+	c.Assert(err, check.ErrorMatches, `s3sync: bad pattern "\["`)
+}
@@ -1,9 +1,18 @@
 package s3_test
 
 import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
 	"github.com/AdRoll/goamz/aws"
 	"github.com/AdRoll/goamz/s3"
 	"github.com/AdRoll/goamz/s3/s3test"
+	"github.com/AdRoll/goamz/testutil"
 	"gopkg.in/check.v1"
 )
 
@@ -37,6 +46,10 @@ type LocalServerSuite struct {
 	clientTests ClientTests
 }
 
+// localMinPartSize is the smallest part size of the local servers, far
+// below that of S3 to keep the multipart tests fast.
+const localMinPartSize = 1024
+
 var (
 	// run tests twice, once in us-east-1 mode, once not.
 	_ = check.Suite(&LocalServerSuite{})
@@ -50,14 +63,30 @@ var (
 )
 
 func (s *LocalServerSuite) SetUpSuite(c *check.C) {
+	if s.srv.config == nil {
+		s.srv.config = &s3test.Config{}
+	}
+	s.srv.config.MinPartSize = localMinPartSize
 	s.srv.SetUp(c)
 	s.clientTests.s3 = s3.New(s.srv.auth, s.srv.region)
+	s.clientTests.partSize = localMinPartSize
+	s3.SetMinPartSize(localMinPartSize)
+	// The local server answers at once: retries need not wait long.
+	s3.SetAttemptStrategy(&aws.AttemptStrategy{
+		Total: 300 * time.Millisecond,
+		Delay: 100 * time.Millisecond,
+	})
 
 	// TODO Sadly the fake server ignores auth completely right now. :-(
 	s.clientTests.authIsBroken = true
 	s.clientTests.Cleanup()
 }
 
+func (s *LocalServerSuite) TearDownSuite(c *check.C) {
+	s3.SetMinPartSize(0)
+	s3.SetAttemptStrategy(nil)
+}
+
 func (s *LocalServerSuite) TearDownTest(c *check.C) {
 	s.clientTests.Cleanup()
 }
@@ -74,6 +103,343 @@ func (s *LocalServerSuite) TestBucketList(c *check.C) {
 	s.clientTests.TestBucketList(c)
 }
 
+func (s *LocalServerSuite) TestObjectIter(c *check.C) {
+	s.clientTests.TestObjectIter(c)
+}
+
+func (s *LocalServerSuite) TestBucketConfiguration(c *check.C) {
+	s.clientTests.TestBucketConfiguration(c)
+}
+
+func (s *LocalServerSuite) TestBucketLogging(c *check.C) {
+	b := testBucket(s.clientTests.s3)
+	c.Assert(b.PutBucket(s3.Private), check.IsNil)
+
+	status, err := b.GetBucketLogging()
+	c.Assert(err, check.IsNil)
+	c.Assert(status.LoggingEnabled, check.IsNil)
+
+	enabled := &s3.LoggingEnabled{TargetBucket: "logs", TargetPrefix: b.Name + "/"}
+	c.Assert(b.PutBucketLogging(s3.BucketLoggingStatus{LoggingEnabled: enabled}), check.IsNil)
+	status, err = b.GetBucketLogging()
+	c.Assert(err, check.IsNil)
+	c.Assert(status.LoggingEnabled, check.DeepEquals, enabled)
+
+	c.Assert(b.PutBucketLogging(s3.BucketLoggingStatus{}), check.IsNil)
+	status, err = b.GetBucketLogging()
+	c.Assert(err, check.IsNil)
+	c.Assert(status.LoggingEnabled, check.IsNil)
+}
+
+func (s *LocalServerSuite) TestBucketNotification(c *check.C) {
+	b := testBucket(s.clientTests.s3)
+	c.Assert(b.PutBucket(s3.Private), check.IsNil)
+
+	conf, err := b.GetBucketNotification()
+	c.Assert(err, check.IsNil)
+	c.Assert(conf.TopicConfigurations, check.HasLen, 0)
+
+	topic := s3.TopicConfiguration{
+		Filter:   s3.NewKeyFilter("", ".log"),
+		TopicArn: "arn:aws:sns:us-east-1:123456789012:logs",
+		Events:   []string{s3.ObjectCreatedPut},
+	}
+	err = b.PutBucketNotification(s3.NotificationConfiguration{TopicConfigurations: []s3.TopicConfiguration{topic}})
+	c.Assert(err, check.IsNil)
+	conf, err = b.GetBucketNotification()
+	c.Assert(err, check.IsNil)
+	c.Assert(conf.TopicConfigurations, check.DeepEquals, []s3.TopicConfiguration{topic})
+}
+
+func (s *LocalServerSuite) TestACL(c *check.C) {
+	s.clientTests.TestACL(c)
+}
+
+func (s *LocalServerSuite) TestObjectTagging(c *check.C) {
+	s.clientTests.TestObjectTagging(c)
+}
+
+func (s *LocalServerSuite) TestConditionalGet(c *check.C) {
+	s.clientTests.TestConditionalGet(c)
+}
+
+func (s *LocalServerSuite) TestVersioning(c *check.C) {
+	s.clientTests.TestVersioning(c)
+}
+
+func (s *LocalServerSuite) TestMultiInitPutList(c *check.C) {
+	s.clientTests.TestMultiInitPutList(c)
+}
+
+func (s *LocalServerSuite) TestMultiComplete(c *check.C) {
+	s.clientTests.TestMultiComplete(c)
+}
+
+func (s *LocalServerSuite) TestListMulti(c *check.C) {
+	s.clientTests.TestListMulti(c)
+}
+
+func (s *LocalServerSuite) TestMultiPutAllZeroLength(c *check.C) {
+	s.clientTests.TestMultiPutAllZeroLength(c)
+}
+
+func (s *LocalServerSuite) TestMultiPartCopy(c *check.C) {
+	s.clientTests.TestMultiPartCopy(c)
+}
+
+func (s *LocalServerSuite) TestDeletePrefixBatches(c *check.C) {
+	s.clientTests.TestDeletePrefixBatches(c)
+}
+
+func (s *LocalServerSuite) TestEmptyBucket(c *check.C) {
+	s.clientTests.TestEmptyBucket(c)
+}
+
+func (s *LocalServerSuite) TestPutResumable(c *check.C) {
+	s.clientTests.TestPutResumable(c)
+}
+
+func (s *LocalServerSuite) TestPutResumableRestarts(c *check.C) {
+	s.clientTests.TestPutResumableRestarts(c)
+}
+
 func (s *LocalServerSuite) TestDoublePutBucket(c *check.C) {
 	s.clientTests.TestDoublePutBucket(c)
 }
+
+func (s *LocalServerSuite) TestCopy(c *check.C) {
+	src := testBucket(s.clientTests.s3)
+	c.Assert(src.PutBucket(s3.Private), check.IsNil)
+	// The destination is reached through the endpoint of another region.
+	region := s.srv.region
+	region.Name = "faux-region-2"
+	dst := testBucket(s3.New(s.srv.auth, region))
+	c.Assert(dst.PutBucket(s3.Private), check.IsNil)
+	defer killBucket(dst)
+
+	options := s3.Options{Meta: map[string][]string{"color": {"blue"}}}
+	c.Assert(src.Put("small", []byte("hello"), "text/plain", s3.Private, options), check.IsNil)
+
+	res, err := dst.Copy("copy", s3.Private, src, "small", s3.CopyObjectOptions{})
+	c.Assert(err, check.IsNil)
+	c.Assert(res.ETag, check.Equals, `"5d41402abc4b2a76b9719d911017c592"`)
+	data, err := dst.Get("copy")
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, "hello")
+	resp, err := dst.Head("copy", nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(resp.Header.Get("Content-Type"), check.Equals, "text/plain")
+	c.Assert(resp.Header.Get("x-amz-meta-color"), check.Equals, "blue")
+}
+
+func (s *LocalServerSuite) TestCopyMultipart(c *check.C) {
+	s.clientTests.TestCopyMultipart(c)
+}
+
+func (s *LocalServerSuite) TestGetRetriesInjectedInternalError(c *check.C) {
+	b := testBucket(s.clientTests.s3)
+	c.Assert(b.PutBucket(s3.Private), check.IsNil)
+	c.Assert(b.Put("name", []byte("yo!"), "text/plain", s3.Private, s3.Options{}), check.IsNil)
+
+	faults := &testutil.Faults{}
+	faults.Add(testutil.FaultRule{Fault: testutil.InternalErrorFault(testutil.S3Protocol), Times: 2})
+	s.srv.srv.SetFaults(faults)
+	defer s.srv.srv.SetFaults(nil)
+
+	data, err := b.Get("name")
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, "yo!")
+	c.Assert(faults.Fired(), check.Equals, 2)
+
+	faults.Add(testutil.FaultRule{Fault: testutil.Fault{Reset: true}})
+	_, err = b.Get("name")
+	c.Assert(err, check.NotNil)
+
+	faults.Clear()
+	c.Assert(b.Del("name"), check.IsNil)
+}
+
+func (s *LocalServerSuite) TestFileStorage(c *check.C) {
+	dir := c.MkDir()
+	start := func() (*s3test.Server, *s3.Bucket) {
+		storage, err := s3test.NewFileStorage(dir)
+		c.Assert(err, check.IsNil)
+		srv, err := s3test.NewServer(&s3test.Config{Storage: storage})
+		c.Assert(err, check.IsNil)
+		region := aws.Region{Name: "faux-region-1", S3Endpoint: srv.URL(), S3LocationConstraint: true}
+		return srv, s3.New(s.srv.auth, region).Bucket("bucket")
+	}
+
+	srv, b := start()
+	c.Assert(b.PutBucket(s3.Private), check.IsNil)
+	c.Assert(b.PutBucketVersioning(s3.VersioningConfiguration{Status: s3.VersioningEnabled}, ""), check.IsNil)
+	// Keys whose segments are both files and directories, or unsafe
+	// as file names.
+	keys := []string{"a", "a/b", "a//../b/", ".hidden"}
+	for _, key := range keys {
+		c.Assert(b.Put(key, []byte("old "+key), "text/plain", s3.Private, s3.Options{}), check.IsNil)
+		c.Assert(b.Put(key, []byte(key), "text/plain", s3.PublicRead, s3.Options{}), check.IsNil)
+	}
+	tagging := s3.Tagging{TagSet: []s3.Tag{{Key: "kept", Value: "yes"}}}
+	c.Assert(b.PutObjectTagging("a", tagging), check.IsNil)
+	c.Assert(b.Del("a/b"), check.IsNil)
+	srv.Quit()
+
+	srv, b = start()
+	defer srv.Quit()
+	for _, key := range keys[2:] {
+		data, err := b.Get(key)
+		c.Assert(err, check.IsNil)
+		c.Assert(string(data), check.Equals, key)
+	}
+	_, err := b.Get("a/b")
+	c.Assert(err.(*s3.Error).Code, check.Equals, "NoSuchKey")
+	got, err := b.GetObjectTagging("a")
+	c.Assert(err, check.IsNil)
+	c.Assert(got.TagSet, check.DeepEquals, tagging.TagSet)
+	conf, err := b.GetBucketVersioning()
+	c.Assert(err, check.IsNil)
+	c.Assert(conf.Status, check.Equals, s3.VersioningEnabled)
+
+	versions, err := b.Versions("a/", "", "", "", 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(versions.DeleteMarkers, check.HasLen, 1)
+	c.Assert(versions.Versions, check.HasLen, 4)
+	for _, v := range versions.Versions {
+		_, err := b.DelVersion(v.Key, v.VersionId)
+		c.Assert(err, check.IsNil)
+	}
+	data, err := b.Get("a")
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, "a")
+}
+
+func (s *LocalServerSuite) TestFileStorageStreams(c *check.C) {
+	dir := c.MkDir()
+	storage, err := s3test.NewFileStorage(dir)
+	c.Assert(err, check.IsNil)
+	srv, err := s3test.NewServer(&s3test.Config{Storage: storage, MinPartSize: localMinPartSize})
+	c.Assert(err, check.IsNil)
+	defer srv.Quit()
+	region := aws.Region{Name: "faux-region-1", S3Endpoint: srv.URL(), S3LocationConstraint: true}
+	b := s3.New(s.srv.auth, region).Bucket("bucket")
+	c.Assert(b.PutBucket(s3.Private), check.IsNil)
+
+	// A body that does not match its Content-MD5 leaves the object as
+	// it was.
+	c.Assert(b.Put("name", []byte("old"), "text/plain", s3.Private, s3.Options{}), check.IsNil)
+	err = b.Put("name", []byte("new"), "text/plain", s3.Private, s3.Options{ContentMD5: "XUFAKrxLKna5cZ2REBfFkg=="})
+	c.Assert(err.(*s3.Error).Code, check.Equals, "BadDigest")
+	data, err := b.Get("name")
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, "old")
+
+	// Parts are kept in the storage until the upload completes.
+	multi, err := b.InitMulti("big", "text/plain", s3.Private, s3.Options{})
+	c.Assert(err, check.IsNil)
+	part1, err := multi.PutPart(1, bytes.NewReader(bytes.Repeat([]byte("a"), localMinPartSize)))
+	c.Assert(err, check.IsNil)
+	part2, err := multi.PutPart(2, strings.NewReader("b"))
+	c.Assert(err, check.IsNil)
+	uploads := filepath.Join(dir, "bucket", "uploads", multi.UploadId)
+	_, err = os.Stat(filepath.Join(uploads, "2"))
+	c.Assert(err, check.IsNil)
+	c.Assert(multi.Complete([]s3.Part{part1, part2}), check.IsNil)
+	_, err = os.Stat(uploads)
+	c.Assert(os.IsNotExist(err), check.Equals, true)
+	data, err = b.Get("big")
+	c.Assert(err, check.IsNil)
+	c.Assert(len(data), check.Equals, localMinPartSize+1)
+	c.Assert(string(data[len(data)-2:]), check.Equals, "ab")
+}
+
+func (s *LocalServerSuite) TestFileStorageRestart(c *check.C) {
+	dir := c.MkDir()
+	start := func() (*s3test.Server, *s3.Bucket) {
+		storage, err := s3test.NewFileStorage(dir)
+		c.Assert(err, check.IsNil)
+		srv, err := s3test.NewServer(&s3test.Config{Storage: storage})
+		c.Assert(err, check.IsNil)
+		region := aws.Region{Name: "faux-region-1", S3Endpoint: srv.URL(), S3LocationConstraint: true}
+		return srv, s3.New(s.srv.auth, region).Bucket("bucket")
+	}
+
+	srv, b := start()
+	c.Assert(b.PutBucket(s3.Private), check.IsNil)
+	c.Assert(b.PutBucketVersioning(s3.VersioningConfiguration{Status: s3.VersioningEnabled}, ""), check.IsNil)
+	for _, data := range []string{"first", "second", "third"} {
+		c.Assert(b.Put("name", []byte(data), "text/plain", s3.Private, s3.Options{}), check.IsNil)
+	}
+	before, err := b.Versions("name", "", "", "", 0)
+	c.Assert(err, check.IsNil)
+	multi, err := b.InitMulti("big", "text/plain", s3.Private, s3.Options{})
+	c.Assert(err, check.IsNil)
+	_, err = multi.PutPart(1, strings.NewReader("part"))
+	c.Assert(err, check.IsNil)
+	srv.Quit()
+
+	// Versions saved within the same instant keep their order.
+	infos, err := filepath.Glob(filepath.Join(dir, "bucket", "objects", "name", "@*.json"))
+	c.Assert(err, check.IsNil)
+	c.Assert(infos, check.HasLen, 3)
+	for _, path := range infos {
+		var info map[string]interface{}
+		data, err := ioutil.ReadFile(path)
+		c.Assert(err, check.IsNil)
+		c.Assert(json.Unmarshal(data, &info), check.IsNil)
+		info["LastModified"] = "2020-01-01T00:00:00Z"
+		data, err = json.Marshal(info)
+		c.Assert(err, check.IsNil)
+		c.Assert(ioutil.WriteFile(path, data, 0666), check.IsNil)
+	}
+
+	srv, b = start()
+	defer srv.Quit()
+	_, err = os.Stat(filepath.Join(dir, "bucket", "uploads"))
+	c.Assert(os.IsNotExist(err), check.Equals, true)
+	data, err := b.Get("name")
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, "third")
+	after, err := b.Versions("name", "", "", "", 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(after.Versions, check.HasLen, 3)
+	for i, v := range after.Versions {
+		c.Assert(v.VersionId, check.Equals, before.Versions[i].VersionId)
+	}
+
+	// Versions added after the restart are the latest.
+	c.Assert(b.Put("name", []byte("fourth"), "text/plain", s3.Private, s3.Options{}), check.IsNil)
+	data, err = b.Get("name")
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, "fourth")
+}
+
+func (s *LocalServerSuite) TestSnapshotRestore(c *check.C) {
+	b := testBucket(s.clientTests.s3)
+	c.Assert(b.PutBucket(s3.Private), check.IsNil)
+	c.Assert(b.Put("fixture", []byte("seed"), "text/plain", s3.Private, s3.Options{}), check.IsNil)
+
+	dir := c.MkDir()
+	c.Assert(s.srv.srv.Snapshot(dir), check.IsNil)
+	c.Assert(s.srv.srv.Snapshot(dir), check.ErrorMatches, ".* is not empty")
+
+	c.Assert(b.Put("fixture", []byte("changed"), "text/plain", s3.Private, s3.Options{}), check.IsNil)
+	c.Assert(b.Put("other", []byte("other"), "text/plain", s3.Private, s3.Options{}), check.IsNil)
+	c.Assert(s.srv.srv.Restore(dir), check.IsNil)
+
+	data, err := b.Get("fixture")
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, "seed")
+	_, err = b.Get("other")
+	c.Assert(err.(*s3.Error).Code, check.Equals, "NoSuchKey")
+
+	// The snapshot seeds other servers too.
+	srv, err := s3test.NewServer(nil)
+	c.Assert(err, check.IsNil)
+	defer srv.Quit()
+	c.Assert(srv.Restore(dir), check.IsNil)
+	region := aws.Region{Name: "faux-region-1", S3Endpoint: srv.URL(), S3LocationConstraint: true}
+	data, err = s3.New(s.srv.auth, region).Bucket(b.Name).Get("fixture")
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, "seed")
+}
@@ -0,0 +1,117 @@
+package s3test
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// setVersionHeader adds the version headers of obj to a response, if its
+// bucket is versioned.
+func setVersionHeader(h http.Header, obj *object) {
+	if obj.versionId == "" {
+		return
+	}
+	h.Set("x-amz-version-id", obj.versionId)
+	if obj.deleteMarker {
+		h.Set("x-amz-delete-marker", "true")
+	}
+}
+
+// checkConditions applies the If-Match, If-None-Match, If-Modified-Since
+// and If-Unmodified-Since headers of a GET or HEAD request for obj. It
+// fails with 412 Precondition Failed, or returns true if the response must
+// be 304 Not Modified.
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetObject.html
+func checkConditions(req *http.Request, obj *object) (notModified bool) {
+	etag := obj.etag()
+	// HTTP dates have no fractional seconds.
+	mtime := obj.mtime.Truncate(time.Second)
+
+	if h := req.Header.Get("If-Match"); h != "" {
+		if !etagMatches(h, etag) {
+			fatalf(412, "PreconditionFailed", "At least one of the pre-conditions you specified did not hold")
+		}
+	} else if t, ok := parseHTTPDate(req.Header.Get("If-Unmodified-Since")); ok && mtime.After(t) {
+		fatalf(412, "PreconditionFailed", "At least one of the pre-conditions you specified did not hold")
+	}
+	if h := req.Header.Get("If-None-Match"); h != "" {
+		return etagMatches(h, etag)
+	}
+	if t, ok := parseHTTPDate(req.Header.Get("If-Modified-Since")); ok && !mtime.After(t) {
+		return true
+	}
+	return false
+}
+
+// etagMatches reports whether the list of entity tags of an If-Match or
+// If-None-Match header includes etag.
+func etagMatches(list, etag string) bool {
+	for _, tag := range strings.Split(list, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || strings.Trim(tag, `"`) == strings.Trim(etag, `"`) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseHTTPDate(s string) (time.Time, bool) {
+	if s == "" {
+		return time.Time{}, false
+	}
+	t, err := http.ParseTime(s)
+	return t, err == nil
+}
+
+// parseRange parses the Range header of a request for an object of the
+// given size, returning the first and last byte to send. It returns false
+// if the whole object must be sent, as S3 does for malformed or multiple
+// ranges, and fails with 416 if the range cannot be satisfied.
+func parseRange(h string, size int64, respHeader http.Header) (start, end int64, ok bool) {
+	if !strings.HasPrefix(h, "bytes=") || strings.Contains(h, ",") {
+		return 0, 0, false
+	}
+	spec := strings.SplitN(strings.TrimSpace(h[len("bytes="):]), "-", 2)
+	if len(spec) != 2 {
+		return 0, 0, false
+	}
+	unsatisfiable := func() {
+		respHeader.Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+		fatalf(416, "InvalidRange", "The requested range is not satisfiable")
+	}
+	if spec[0] == "" {
+		// The last n bytes.
+		n, err := strconv.ParseInt(spec[1], 10, 64)
+		if err != nil || n < 0 {
+			return 0, 0, false
+		}
+		if n == 0 || size == 0 {
+			unsatisfiable()
+		}
+		if n > size {
+			n = size
+		}
+		return size - n, size - 1, true
+	}
+	start, err := strconv.ParseInt(spec[0], 10, 64)
+	if err != nil || start < 0 {
+		return 0, 0, false
+	}
+	end = size - 1
+	if spec[1] != "" {
+		end, err = strconv.ParseInt(spec[1], 10, 64)
+		if err != nil || end < start {
+			return 0, 0, false
+		}
+		if end > size-1 {
+			end = size - 1
+		}
+	}
+	if start >= size {
+		unsatisfiable()
+	}
+	return start, end, true
+}
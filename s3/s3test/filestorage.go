@@ -0,0 +1,251 @@
+package s3test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// FileStorage is a Storage keeping buckets and objects in a directory, so
+// that a Server using it can be restarted without losing them. Its layout
+// is:
+//
+//	<bucket>/bucket.json                     the bucket metadata
+//	<bucket>/objects/<key>/@<version>        the content of an object
+//	<bucket>/objects/<key>/@<version>.json   the object metadata
+//	<bucket>/uploads/<upload>/<n>            the content of part n of an upload
+//
+// where each segment of the key between slashes is a directory, with the
+// bytes other than ASCII letters, digits, '-', '_' and non-leading '.'
+// escaped as %XX, and version is "null" for unversioned objects.
+type FileStorage struct {
+	dir string
+}
+
+// NewFileStorage returns a FileStorage in the directory dir, creating it
+// if needed.
+func NewFileStorage(dir string) (*FileStorage, error) {
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return nil, err
+	}
+	return &FileStorage{dir: dir}, nil
+}
+
+const (
+	bucketInfoFile = "bucket.json"
+	objectsDir     = "objects"
+	uploadsDir     = "uploads"
+	versionPrefix  = "@"
+	infoSuffix     = ".json"
+)
+
+func (s *FileStorage) bucketDir(name string) string {
+	return filepath.Join(s.dir, name)
+}
+
+// objectPath returns the path of the content of obj; its metadata is the
+// same path followed by infoSuffix.
+func (s *FileStorage) objectPath(obj *ObjectInfo) string {
+	p := []string{s.bucketDir(obj.Bucket), objectsDir}
+	for _, seg := range strings.Split(obj.Key, "/") {
+		p = append(p, escapeSegment(seg))
+	}
+	versionId := obj.VersionId
+	if versionId == "" {
+		versionId = nullVersion
+	}
+	return filepath.Join(append(p, versionPrefix+escapeSegment(versionId))...)
+}
+
+// escapeSegment returns a file name for a segment of an object key.
+// Escaping a leading '.' keeps "." and ".." out, and '%' alone stands for
+// the empty segment.
+func escapeSegment(seg string) string {
+	if seg == "" {
+		return "%"
+	}
+	var b strings.Builder
+	for i := 0; i < len(seg); i++ {
+		c := seg[i]
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' && i > 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func (s *FileStorage) Load() ([]*BucketInfo, []*ObjectInfo, error) {
+	entries, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	var buckets []*BucketInfo
+	var objects []*ObjectInfo
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		b := &BucketInfo{}
+		if err := readJSON(filepath.Join(s.dir, e.Name(), bucketInfoFile), b); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, nil, err
+		}
+		buckets = append(buckets, b)
+		// The parts of uploads in progress are not kept across restarts.
+		if err := os.RemoveAll(filepath.Join(s.dir, e.Name(), uploadsDir)); err != nil {
+			return nil, nil, err
+		}
+		err := filepath.Walk(filepath.Join(s.dir, e.Name(), objectsDir), func(path string, fi os.FileInfo, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			name := fi.Name()
+			if fi.IsDir() || !strings.HasPrefix(name, versionPrefix) || !strings.HasSuffix(name, infoSuffix) {
+				return nil
+			}
+			obj := &ObjectInfo{}
+			if err := readJSON(path, obj); err != nil {
+				return err
+			}
+			objects = append(objects, obj)
+			return nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return buckets, objects, nil
+}
+
+func (s *FileStorage) PutBucket(b *BucketInfo) error {
+	dir := s.bucketDir(b.Name)
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+	return writeJSON(filepath.Join(dir, bucketInfoFile), b)
+}
+
+func (s *FileStorage) DeleteBucket(name string) error {
+	return os.RemoveAll(s.bucketDir(name))
+}
+
+func (s *FileStorage) PutObject(obj *ObjectInfo, data io.Reader) error {
+	path := s.objectPath(obj)
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		return err
+	}
+	if data == nil {
+		data = strings.NewReader("")
+	}
+	err := writeFile(path, func(w io.Writer) error {
+		_, err := io.Copy(w, data)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return writeJSON(path+infoSuffix, obj)
+}
+
+func (s *FileStorage) UpdateObject(obj *ObjectInfo) error {
+	return writeJSON(s.objectPath(obj)+infoSuffix, obj)
+}
+
+func (s *FileStorage) OpenObject(obj *ObjectInfo) (io.ReadCloser, error) {
+	return os.Open(s.objectPath(obj))
+}
+
+func (s *FileStorage) DeleteObject(obj *ObjectInfo) error {
+	path := s.objectPath(obj)
+	for _, p := range []string{path + infoSuffix, path} {
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	// Remove the directories of the key left empty.
+	top := filepath.Join(s.bucketDir(obj.Bucket), objectsDir)
+	for dir := filepath.Dir(path); dir != top; dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+	return nil
+}
+
+// uploadDir returns the directory holding the parts of an upload.
+func (s *FileStorage) uploadDir(bucket, uploadId string) string {
+	return filepath.Join(s.bucketDir(bucket), uploadsDir, escapeSegment(uploadId))
+}
+
+func (s *FileStorage) PutPart(bucket, uploadId string, n int, data io.Reader) error {
+	dir := s.uploadDir(bucket, uploadId)
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(dir, strconv.Itoa(n)), func(w io.Writer) error {
+		_, err := io.Copy(w, data)
+		return err
+	})
+}
+
+func (s *FileStorage) OpenPart(bucket, uploadId string, n int) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(s.uploadDir(bucket, uploadId), strconv.Itoa(n)))
+}
+
+func (s *FileStorage) DeleteParts(bucket, uploadId string) error {
+	return os.RemoveAll(s.uploadDir(bucket, uploadId))
+}
+
+func readJSON(path string, v interface{}) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("s3test: cannot decode %s: %v", path, err)
+	}
+	return nil
+}
+
+func writeJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return err
+	}
+	return writeFile(path, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// writeFile writes the file at path with write, replacing it only once
+// it is complete.
+func writeFile(path string, write func(w io.Writer) error) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), ".tmp")
+	if err != nil {
+		return err
+	}
+	err = write(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
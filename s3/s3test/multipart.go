@@ -0,0 +1,551 @@
+package s3test
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AdRoll/goamz/s3"
+)
+
+// maxPartNumber is the highest part number S3 accepts.
+// https://docs.aws.amazon.com/AmazonS3/latest/userguide/qfacts.html
+const maxPartNumber = 10000
+
+type multipartUpload struct {
+	id        string
+	key       string
+	initiated time.Time
+	parts     map[int]*multipartUploadPart
+
+	// The properties given when the upload was initiated, that the
+	// completed object gets.
+	meta         http.Header
+	acl          *s3.AccessControlPolicy
+	tags         []s3.Tag
+	storageClass string
+}
+
+type multipartUploadPart struct {
+	size         int64
+	checksum     []byte
+	lastModified time.Time
+}
+
+func (p *multipartUploadPart) etag() string {
+	return fmt.Sprintf(`"%x"`, p.checksum)
+}
+
+// multipartResource is an object with the ?uploads subresource, to
+// initiate a multipart upload, or the ?uploadId subresource, to work
+// with the upload uploadId.
+type multipartResource struct {
+	objectResource
+	uploadId string
+}
+
+// upload returns the upload of the resource, failing with NoSuchUpload
+// if it was completed, aborted, or never existed.
+func (r multipartResource) upload() *multipartUpload {
+	u := r.bucket.multipartUploads[r.uploadId]
+	if u == nil || u.key != r.name {
+		fatalf(404, "NoSuchUpload", "The specified multipart upload does not exist. The upload ID might be invalid, or the multipart upload might have been aborted or completed.")
+	}
+	return u
+}
+
+func partNumber(a *action) int {
+	n, err := strconv.Atoi(a.req.Form.Get("partNumber"))
+	if err != nil || n < 1 || n > maxPartNumber {
+		fatalf(400, "InvalidArgument", "Part number must be an integer between 1 and 10000, inclusive")
+	}
+	return n
+}
+
+type listPartsResult struct {
+	XMLName              struct{} `xml:"ListPartsResult"`
+	Bucket               string
+	Key                  string
+	UploadId             string
+	Initiator            s3.Owner
+	Owner                s3.Owner
+	StorageClass         string
+	PartNumberMarker     int
+	NextPartNumberMarker int
+	MaxParts             int
+	IsTruncated          bool
+	Part                 []listedPart
+}
+
+type listedPart struct {
+	PartNumber   int
+	LastModified string
+	ETag         string
+	Size         int64
+}
+
+// GET on an upload lists its parts.
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_ListParts.html
+func (r multipartResource) get(a *action) interface{} {
+	if r.uploadId == "" {
+		return notAllowed()
+	}
+	u := r.upload()
+	resp := &listPartsResult{
+		Bucket:       r.bucket.name,
+		Key:          u.key,
+		UploadId:     u.id,
+		Initiator:    owner,
+		Owner:        owner,
+		StorageClass: storageClassName(u.storageClass),
+		MaxParts:     1000,
+	}
+	if s := a.req.Form.Get("part-number-marker"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			fatalf(400, "InvalidArgument", "Part number marker must be an integer between 0 and 2147483647, inclusive")
+		}
+		resp.PartNumberMarker = n
+	}
+	if s := a.req.Form.Get("max-parts"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			fatalf(400, "InvalidArgument", "Provided max-parts not an integer or within integer range")
+		}
+		if n < resp.MaxParts {
+			resp.MaxParts = n
+		}
+	}
+	var numbers []int
+	for n := range u.parts {
+		if n > resp.PartNumberMarker {
+			numbers = append(numbers, n)
+		}
+	}
+	sort.Ints(numbers)
+	if len(numbers) > resp.MaxParts {
+		numbers = numbers[:resp.MaxParts]
+		resp.IsTruncated = true
+	}
+	for _, n := range numbers {
+		p := u.parts[n]
+		resp.Part = append(resp.Part, listedPart{
+			PartNumber:   n,
+			LastModified: p.lastModified.Format(timeFormat),
+			ETag:         p.etag(),
+			Size:         p.size,
+		})
+		resp.NextPartNumberMarker = n
+	}
+	return resp
+}
+
+type copyPartResult struct {
+	XMLName      struct{} `xml:"CopyPartResult"`
+	LastModified string
+	ETag         string
+}
+
+// PUT on an upload uploads one of its parts, from the request body or,
+// with the x-amz-copy-source header, from an existing object.
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_UploadPart.html
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_UploadPartCopy.html
+func (r multipartResource) put(a *action) interface{} {
+	if r.uploadId == "" {
+		return notAllowed()
+	}
+	u := r.upload()
+	n := partNumber(a)
+	source := a.req.Header.Get("x-amz-copy-source")
+	if source == "" {
+		part := r.bucket.putPart(u, n, bodyReader(a))
+		a.w.Header().Set("ETag", part.etag())
+		return nil
+	}
+	b, obj := copySource(a, source)
+	data := copySourceRange(a, b, obj)
+	defer data.Close()
+	part := r.bucket.putPart(u, n, newContentReader(data))
+	return &copyPartResult{
+		LastModified: part.lastModified.Format(timeFormat),
+		ETag:         part.etag(),
+	}
+}
+
+// putPart stores data as part n of u, replacing any previous one.
+func (b *bucket) putPart(u *multipartUpload, n int, data *contentReader) *multipartUploadPart {
+	if err := b.storage.PutPart(b.name, u.id, n, data); err != nil {
+		if data.failure != nil {
+			panic(data.failure)
+		}
+		storageError(err)
+	}
+	part := &multipartUploadPart{
+		size:         data.size,
+		checksum:     data.sum,
+		lastModified: time.Now(),
+	}
+	u.parts[n] = part
+	return part
+}
+
+// copySource returns the object named by the x-amz-copy-source header
+// value source, "bucket/key" with an optional "?versionId=" suffix, and
+// its bucket.
+func copySource(a *action, source string) (*bucket, *object) {
+	source, err := url.QueryUnescape(source)
+	if err != nil {
+		fatalf(400, "InvalidArgument", "Invalid copy source encoding")
+	}
+	var versionId string
+	if i := strings.Index(source, "?versionId="); i >= 0 {
+		source, versionId = source[:i], source[i+len("?versionId="):]
+	}
+	parts := strings.SplitN(strings.TrimPrefix(source, "/"), "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		fatalf(400, "InvalidArgument", "Copy Source must mention the source bucket and key: sourcebucket/sourcekey")
+	}
+	b := a.srv.buckets[parts[0]]
+	if b == nil {
+		fatalf(404, "NoSuchBucket", "The specified bucket does not exist")
+	}
+	var obj *object
+	if versionId != "" {
+		obj = b.version(parts[1], versionId)
+	} else {
+		obj = b.objects[parts[1]]
+	}
+	if obj == nil {
+		if versionId != "" {
+			fatalf(404, "NoSuchVersion", "The specified version does not exist.")
+		}
+		fatalf(404, "NoSuchKey", "The specified key does not exist.")
+	}
+	if obj.deleteMarker {
+		fatalf(400, "InvalidRequest", "The source of a copy request may not specifically refer to a delete marker by version id.")
+	}
+	if _, readable := obj.restoreStatus(time.Now()); !readable {
+		fatalf(403, "InvalidObjectState", "The operation is not valid for the object's storage class")
+	}
+	return b, obj
+}
+
+// copySourceRange returns a reader of the bytes of obj, an object of b,
+// selected by the x-amz-copy-source-range header, or all of them.
+func copySourceRange(a *action, b *bucket, obj *object) io.ReadCloser {
+	first, last := int64(0), obj.size-1
+	if h := a.req.Header.Get("x-amz-copy-source-range"); h != "" {
+		if _, err := fmt.Sscanf(h, "bytes=%d-%d", &first, &last); err != nil || first < 0 || first > last {
+			fatalf(400, "InvalidArgument", "The x-amz-copy-source-range value must be of the form bytes=first-last where first and last are the zero-based offsets of the first and last bytes to copy")
+		}
+		if last >= obj.size {
+			fatalf(400, "InvalidRequest", fmt.Sprintf("The specified copy range is invalid for the source object size: %d", obj.size))
+		}
+	}
+	r := b.open(obj)
+	if _, err := io.CopyN(ioutil.Discard, r, first); err != nil {
+		r.Close()
+		storageError(err)
+	}
+	return &rangeReader{r, last - first + 1}
+}
+
+// rangeReader reads the next left bytes of the object content it wraps,
+// failing if there are fewer.
+type rangeReader struct {
+	io.ReadCloser
+	left int64
+}
+
+func (r *rangeReader) Read(p []byte) (int, error) {
+	if r.left <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > r.left {
+		p = p[:r.left]
+	}
+	n, err := r.ReadCloser.Read(p)
+	r.left -= int64(n)
+	if err == io.EOF && r.left > 0 {
+		err = io.ErrUnexpectedEOF
+	} else if err == io.EOF {
+		err = nil
+	}
+	return n, err
+}
+
+// partsReader reads the contents of parts of an upload one after the
+// other, opening each only once the previous one is exhausted.
+type partsReader struct {
+	b        *bucket
+	uploadId string
+	numbers  []int
+	r        io.ReadCloser
+}
+
+func (p *partsReader) Read(buf []byte) (int, error) {
+	for {
+		if p.r == nil {
+			if len(p.numbers) == 0 {
+				return 0, io.EOF
+			}
+			r, err := p.b.storage.OpenPart(p.b.name, p.uploadId, p.numbers[0])
+			if err != nil {
+				return 0, err
+			}
+			p.r, p.numbers = r, p.numbers[1:]
+		}
+		n, err := p.r.Read(buf)
+		if err == io.EOF {
+			err = p.r.Close()
+			p.r = nil
+			if n == 0 && err == nil {
+				continue
+			}
+		}
+		return n, err
+	}
+}
+
+func (p *partsReader) Close() error {
+	if p.r == nil {
+		return nil
+	}
+	return p.r.Close()
+}
+
+type initiateMultipartUploadResult struct {
+	XMLName  struct{} `xml:"InitiateMultipartUploadResult"`
+	Bucket   string
+	Key      string
+	UploadId string
+}
+
+type completeMultipartUpload struct {
+	XMLName struct{} `xml:"CompleteMultipartUpload"`
+	Part    []struct {
+		PartNumber int
+		ETag       string
+	}
+}
+
+type completeMultipartUploadResult struct {
+	XMLName  struct{} `xml:"CompleteMultipartUploadResult"`
+	Location string
+	Bucket   string
+	Key      string
+	ETag     string
+}
+
+// POST on ?uploads initiates an upload, and POST on an upload completes
+// it.
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_CreateMultipartUpload.html
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_CompleteMultipartUpload.html
+func (r multipartResource) post(a *action) interface{} {
+	if r.uploadId == "" {
+		u := &multipartUpload{
+			id:           strconv.FormatInt(rand.Int63(), 16),
+			key:          r.name,
+			initiated:    time.Now(),
+			parts:        make(map[int]*multipartUploadPart),
+			meta:         metaFromRequest(a.req),
+			acl:          aclFromRequest(a.req),
+			tags:         tagsFromRequest(a.req),
+			storageClass: a.req.Header.Get("x-amz-storage-class"),
+		}
+		r.bucket.multipartUploads[u.id] = u
+		return &initiateMultipartUploadResult{
+			Bucket:   r.bucket.name,
+			Key:      u.key,
+			UploadId: u.id,
+		}
+	}
+
+	u := r.upload()
+	var req completeMultipartUpload
+	if err := xml.NewDecoder(a.req.Body).Decode(&req); err != nil || len(req.Part) == 0 {
+		fatalf(400, "MalformedXML", "The XML you provided was not well-formed or did not validate against our published schema")
+	}
+	// The ETag of the object is the MD5 of the MD5s of its parts,
+	// followed by the number of parts.
+	data := &partsReader{b: r.bucket, uploadId: u.id}
+	defer data.Close()
+	sums := md5.New()
+	for i, p := range req.Part {
+		if i > 0 && p.PartNumber <= req.Part[i-1].PartNumber {
+			fatalf(400, "InvalidPartOrder", "The list of parts was not in ascending order. Parts must be ordered by part number.")
+		}
+		part := u.parts[p.PartNumber]
+		if part == nil || strings.Trim(p.ETag, `"`) != hex.EncodeToString(part.checksum) {
+			fatalf(400, "InvalidPart", "One or more of the specified parts could not be found. The part may not have been uploaded, or the specified entity tag may not match the part's entity tag.")
+		}
+		if i < len(req.Part)-1 && part.size < a.srv.config.minPartSize() {
+			fatalf(400, "EntityTooSmall", "Your proposed upload is smaller than the minimum allowed object size.")
+		}
+		data.numbers = append(data.numbers, p.PartNumber)
+		sums.Write(part.checksum)
+	}
+
+	obj := &object{
+		name:          u.key,
+		mtime:         time.Now(),
+		meta:          u.meta,
+		multipartETag: fmt.Sprintf(`"%x-%d"`, sums.Sum(nil), len(req.Part)),
+		acl:           u.acl,
+		tags:          u.tags,
+		storageClass:  u.storageClass,
+	}
+	r.bucket.putObject(obj, newContentReader(data))
+	r.bucket.deleteUpload(u.id)
+	setVersionHeader(a.w.Header(), obj)
+	return &completeMultipartUploadResult{
+		Location: fmt.Sprintf("http://%s/%s/%s", a.srv.listener.Addr(), r.bucket.name, u.key),
+		Bucket:   r.bucket.name,
+		Key:      u.key,
+		ETag:     obj.etag(),
+	}
+}
+
+// DELETE on an upload aborts it.
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_AbortMultipartUpload.html
+func (r multipartResource) delete(a *action) interface{} {
+	if r.uploadId == "" {
+		return notAllowed()
+	}
+	r.bucket.deleteUpload(r.upload().id)
+	a.w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// deleteUpload forgets the upload id of b and deletes its parts.
+func (b *bucket) deleteUpload(id string) {
+	delete(b.multipartUploads, id)
+	if err := b.storage.DeleteParts(b.name, id); err != nil {
+		storageError(err)
+	}
+}
+
+// multipartUploadsResource is a bucket with the ?uploads subresource.
+type multipartUploadsResource struct {
+	bucketResource
+}
+
+type listMultipartUploadsResult struct {
+	XMLName            struct{} `xml:"ListMultipartUploadsResult"`
+	Bucket             string
+	KeyMarker          string
+	UploadIdMarker     string
+	NextKeyMarker      string
+	NextUploadIdMarker string
+	Delimiter          string `xml:",omitempty"`
+	Prefix             string
+	MaxUploads         int
+	IsTruncated        bool
+	Upload             []listedUpload
+	CommonPrefixes     []string `xml:">Prefix"`
+}
+
+type listedUpload struct {
+	Key          string
+	UploadId     string
+	Initiator    s3.Owner
+	Owner        s3.Owner
+	StorageClass string
+	Initiated    string
+}
+
+// GET on ?uploads lists the uploads in progress in the bucket, by key
+// and then by initiation time.
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_ListMultipartUploads.html
+func (r multipartUploadsResource) get(a *action) interface{} {
+	resp := &listMultipartUploadsResult{
+		Bucket:         r.bucket.name,
+		KeyMarker:      a.req.Form.Get("key-marker"),
+		UploadIdMarker: a.req.Form.Get("upload-id-marker"),
+		Delimiter:      a.req.Form.Get("delimiter"),
+		Prefix:         a.req.Form.Get("prefix"),
+		MaxUploads:     1000,
+	}
+	if s := a.req.Form.Get("max-uploads"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			fatalf(400, "InvalidArgument", "Provided max-uploads not an integer or within integer range")
+		}
+		if n < resp.MaxUploads {
+			resp.MaxUploads = n
+		}
+	}
+	var uploads []*multipartUpload
+	for _, u := range r.bucket.multipartUploads {
+		if strings.HasPrefix(u.key, resp.Prefix) {
+			uploads = append(uploads, u)
+		}
+	}
+	sort.Slice(uploads, func(i, j int) bool {
+		if uploads[i].key != uploads[j].key {
+			return uploads[i].key < uploads[j].key
+		}
+		if !uploads[i].initiated.Equal(uploads[j].initiated) {
+			return uploads[i].initiated.Before(uploads[j].initiated)
+		}
+		return uploads[i].id < uploads[j].id
+	})
+
+	// Without an upload ID marker, the uploads of the key marker are
+	// skipped too; with one, those up to it.
+	passed := false
+	count := 0
+	for _, u := range uploads {
+		if u.key < resp.KeyMarker {
+			continue
+		}
+		if u.key == resp.KeyMarker && !passed {
+			passed = u.id == resp.UploadIdMarker
+			continue
+		}
+		if count == resp.MaxUploads {
+			resp.IsTruncated = true
+			break
+		}
+		if resp.Delimiter != "" {
+			if i := strings.Index(u.key[len(resp.Prefix):], resp.Delimiter); i >= 0 {
+				p := u.key[:len(resp.Prefix)+i+len(resp.Delimiter)]
+				if p <= resp.KeyMarker || len(resp.CommonPrefixes) > 0 && resp.CommonPrefixes[len(resp.CommonPrefixes)-1] == p {
+					continue
+				}
+				resp.CommonPrefixes = append(resp.CommonPrefixes, p)
+				resp.NextKeyMarker, resp.NextUploadIdMarker = p, ""
+				count++
+				continue
+			}
+		}
+		resp.Upload = append(resp.Upload, listedUpload{
+			Key:          u.key,
+			UploadId:     u.id,
+			Initiator:    owner,
+			Owner:        owner,
+			StorageClass: storageClassName(u.storageClass),
+			Initiated:    u.initiated.Format(timeFormat),
+		})
+		resp.NextKeyMarker, resp.NextUploadIdMarker = u.key, u.id
+		count++
+	}
+	if !resp.IsTruncated {
+		resp.NextKeyMarker, resp.NextUploadIdMarker = "", ""
+	}
+	return resp
+}
+
+func (r multipartUploadsResource) put(a *action) interface{}    { return notAllowed() }
+func (r multipartUploadsResource) post(a *action) interface{}   { return notAllowed() }
+func (r multipartUploadsResource) delete(a *action) interface{} { return notAllowed() }
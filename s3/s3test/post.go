@@ -0,0 +1,248 @@
+package s3test
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/AdRoll/goamz/aws"
+	"github.com/AdRoll/goamz/s3"
+)
+
+// postPolicy is the decoded policy document of a browser-based upload.
+type postPolicy struct {
+	Expiration string
+	Conditions []interface{}
+}
+
+// postResponse is returned when success_action_status is 201.
+type postResponse struct {
+	XMLName  struct{} `xml:"PostResponse"`
+	Location string
+	Bucket   string
+	Key      string
+	ETag     string
+}
+
+// postFieldsUnchecked are the form fields policy conditions need not
+// cover.
+var postFieldsUnchecked = map[string]bool{
+	"policy":          true,
+	"x-amz-signature": true,
+	"signature":       true,
+	"awsaccesskeyid":  true,
+	"file":            true,
+}
+
+func policyFailed(format string, a ...interface{}) {
+	fatalf(403, "AccessDenied", "Invalid according to Policy: "+format, a...)
+}
+
+// POST of a multipart form on a bucket uploads an object from a browser.
+// http://docs.aws.amazon.com/AmazonS3/latest/API/RESTObjectPOST.html
+func (r bucketResource) postObject(a *action) interface{} {
+	if r.bucket == nil {
+		fatalf(404, "NoSuchBucket", "The specified bucket does not exist")
+	}
+	if err := a.req.ParseMultipartForm(32 << 20); err != nil {
+		fatalf(400, "MalformedPOSTRequest", "The body of your POST request is not well-formed multipart/form-data.")
+	}
+	// Field names are case insensitive.
+	fields := make(map[string]string)
+	for k, v := range a.req.MultipartForm.Value {
+		fields[strings.ToLower(k)] = v[0]
+	}
+	files := a.req.MultipartForm.File["file"]
+	if len(files) == 0 {
+		fatalf(400, "InvalidArgument", "POST requires exactly one file upload per request.")
+	}
+	f, err := files[0].Open()
+	if err != nil {
+		fatalf(400, "IncompleteBody", "%v", err)
+	}
+	defer f.Close()
+
+	r.checkPostPolicy(a, fields, files[0].Size)
+
+	key := fields["key"]
+	if key == "" {
+		fatalf(400, "InvalidArgument", "Bucket POST must contain a field named 'key'.")
+	}
+	key = strings.Replace(key, "${filename}", files[0].Filename, -1)
+
+	acl := s3.Private
+	if v, ok := fields["acl"]; ok {
+		acl = s3.ACL(v)
+	}
+	obj := &object{
+		name:  key,
+		meta:  make(http.Header),
+		mtime: time.Now(),
+		acl:   cannedACL(acl),
+	}
+	for k, v := range fields {
+		if k == "content-type" || strings.HasPrefix(k, "x-amz-meta-") {
+			obj.meta.Set(k, v)
+		}
+	}
+	data := newContentReader(f)
+	data.body = true
+	r.bucket.putObject(obj, data)
+	setVersionHeader(a.w.Header(), obj)
+
+	etag := obj.etag()
+	a.w.Header().Set("ETag", etag)
+	if redirect := fields["success_action_redirect"]; redirect != "" {
+		u, err := url.Parse(redirect)
+		if err != nil {
+			fatalf(400, "InvalidArgument", "Invalid success_action_redirect")
+		}
+		q := u.Query()
+		q.Set("bucket", r.name)
+		q.Set("key", key)
+		q.Set("etag", etag)
+		u.RawQuery = q.Encode()
+		a.w.Header().Set("Location", u.String())
+		a.w.WriteHeader(http.StatusSeeOther)
+		return nil
+	}
+	switch fields["success_action_status"] {
+	case "200":
+		a.w.WriteHeader(http.StatusOK)
+	case "201":
+		a.w.WriteHeader(http.StatusCreated)
+		return &postResponse{
+			Location: a.srv.URL() + "/" + r.name + "/" + key,
+			Bucket:   r.name,
+			Key:      key,
+			ETag:     etag,
+		}
+	default:
+		a.w.WriteHeader(http.StatusNoContent)
+	}
+	return nil
+}
+
+// checkPostPolicy checks the signature of the policy of a browser-based
+// upload and that the form fields and the file size meet its conditions.
+func (r bucketResource) checkPostPolicy(a *action, fields map[string]string, size int64) {
+	encoded := fields["policy"]
+	if encoded == "" {
+		fatalf(403, "AccessDenied", "Access Denied")
+	}
+	if secret := a.srv.config.secretKey(); secret != "" {
+		checkPostSignature(fields, encoded, secret)
+	}
+	doc, err := base64.StdEncoding.DecodeString(encoded)
+	var policy postPolicy
+	if err == nil {
+		err = json.Unmarshal(doc, &policy)
+	}
+	if err != nil {
+		fatalf(400, "InvalidPolicyDocument", "Invalid Policy: Invalid JSON.")
+	}
+	expiration, err := time.Parse(time.RFC3339, policy.Expiration)
+	if err != nil {
+		fatalf(400, "InvalidPolicyDocument", "Invalid Policy: Invalid 'expiration' value: '%s'", policy.Expiration)
+	}
+	if time.Now().After(expiration) {
+		policyFailed("Policy expired.")
+	}
+
+	// The bucket is not a form field but may be named by conditions.
+	values := map[string]string{"bucket": r.name}
+	for k, v := range fields {
+		values[k] = v
+	}
+	covered := make(map[string]bool)
+	for _, cond := range policy.Conditions {
+		switch cond := cond.(type) {
+		case map[string]interface{}:
+			for k, v := range cond {
+				k = strings.ToLower(k)
+				if s, _ := v.(string); values[k] != s {
+					policyFailed(`Policy Condition failed: ["eq", "$%s", "%v"]`, k, v)
+				}
+				covered[k] = true
+			}
+		case []interface{}:
+			checkPostCondition(cond, values, size, covered)
+		default:
+			fatalf(400, "InvalidPolicyDocument", "Invalid Policy: Invalid Condition: %v", cond)
+		}
+	}
+	for k := range fields {
+		if !covered[k] && !postFieldsUnchecked[k] && !strings.HasPrefix(k, "x-ignore-") {
+			policyFailed("Extra input fields: %s", k)
+		}
+	}
+}
+
+func checkPostCondition(cond []interface{}, values map[string]string, size int64, covered map[string]bool) {
+	op, _ := cond[0].(string)
+	if op == "content-length-range" && len(cond) == 3 {
+		min, ok1 := cond[1].(float64)
+		max, ok2 := cond[2].(float64)
+		if !ok1 || !ok2 {
+			fatalf(400, "InvalidPolicyDocument", "Invalid Policy: Invalid content-length-range")
+		}
+		if size < int64(min) {
+			fatalf(400, "EntityTooSmall", "Your proposed upload is smaller than the minimum allowed size")
+		}
+		if size > int64(max) {
+			fatalf(400, "EntityTooLarge", "Your proposed upload exceeds the maximum allowed size")
+		}
+		return
+	}
+	if len(cond) != 3 {
+		fatalf(400, "InvalidPolicyDocument", "Invalid Policy: Invalid Condition: %v", cond)
+	}
+	name, _ := cond[1].(string)
+	value, _ := cond[2].(string)
+	if !strings.HasPrefix(name, "$") {
+		fatalf(400, "InvalidPolicyDocument", "Invalid Policy: Invalid Condition: %v", cond)
+	}
+	name = strings.ToLower(name[1:])
+	var ok bool
+	switch strings.ToLower(op) {
+	case "eq":
+		ok = values[name] == value
+	case "starts-with":
+		ok = strings.HasPrefix(values[name], value)
+	default:
+		fatalf(400, "InvalidPolicyDocument", "Invalid Policy: Invalid Condition: %v", cond)
+	}
+	if !ok {
+		policyFailed(`Policy Condition failed: ["%s", "$%s", "%s"]`, op, name, value)
+	}
+	covered[name] = true
+}
+
+// checkPostSignature checks the signature of a browser-based upload,
+// either of version 4 or of version 2.
+func checkPostSignature(fields map[string]string, policy, secret string) {
+	var expected, got string
+	if fields["x-amz-algorithm"] == "AWS4-HMAC-SHA256" {
+		// The credential is <access key>/<date>/<region>/s3/aws4_request.
+		scope := strings.Split(fields["x-amz-credential"], "/")
+		t, err := time.Parse(aws.ISO8601BasicFormat, fields["x-amz-date"])
+		if len(scope) != 5 || err != nil {
+			fatalf(400, "InvalidArgument", "Invalid credential or date")
+		}
+		auth := aws.Auth{AccessKey: scope[0], SecretKey: secret}
+		signer := aws.NewV4Signer(auth, "s3", aws.Region{Name: scope[2]})
+		expected, got = signer.SignPolicy(t, policy), fields["x-amz-signature"]
+	} else {
+		mac := hmac.New(sha1.New, []byte(secret))
+		mac.Write([]byte(policy))
+		expected, got = base64.StdEncoding.EncodeToString(mac.Sum(nil)), fields["signature"]
+	}
+	if !hmac.Equal([]byte(expected), []byte(got)) {
+		fatalf(403, "SignatureDoesNotMatch", "The request signature we calculated does not match the signature you provided. Check your key and signing method.")
+	}
+}
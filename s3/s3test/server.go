@@ -4,14 +4,16 @@ import (
 	"bytes"
 	"crypto/md5"
 	"encoding/base64"
-	"encoding/hex"
+	"encoding/json"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"github.com/AdRoll/goamz/s3"
+	"github.com/AdRoll/goamz/testutil"
+	"hash"
 	"io"
 	"io/ioutil"
 	"log"
-	"math/rand"
 	"net"
 	"net/http"
 	"net/url"
@@ -56,6 +58,25 @@ type Config struct {
 	// Address on which to listen. By default, a random port is assigned by the
 	// operating system and the server listens on localhost.
 	ListenAddress string
+
+	// SecretKey, if set, is used to check the signature of browser-based
+	// POST uploads. Other requests are not authenticated.
+	SecretKey string
+
+	// RestoreDelay is how long the restore of an object stored in the
+	// GLACIER or DEEP_ARCHIVE storage class takes. Until it is restored,
+	// such an object cannot be read.
+	RestoreDelay time.Duration
+
+	// Storage holds the buckets and objects of the server, which starts
+	// with those it already holds. By default, they are kept in memory
+	// and lost when the server quits.
+	Storage Storage
+
+	// MinPartSize is the smallest size of every part of a multipart
+	// upload but the last one, s3.MinPartSize by default as in S3. Tests
+	// may lower it to keep their multipart uploads small.
+	MinPartSize int64
 }
 
 func (c *Config) send409Conflict() bool {
@@ -65,8 +86,37 @@ func (c *Config) send409Conflict() bool {
 	return false
 }
 
+func (c *Config) secretKey() string {
+	if c != nil {
+		return c.SecretKey
+	}
+	return ""
+}
+
+func (c *Config) restoreDelay() time.Duration {
+	if c != nil {
+		return c.RestoreDelay
+	}
+	return 0
+}
+
+func (c *Config) minPartSize() int64 {
+	if c != nil && c.MinPartSize > 0 {
+		return c.MinPartSize
+	}
+	return s3.MinPartSize
+}
+
+func (c *Config) storage() Storage {
+	if c != nil && c.Storage != nil {
+		return c.Storage
+	}
+	return newMemoryStorage()
+}
+
 // Server is a fake S3 server for testing purposes.
-// All of the data for the server is kept in memory.
+// Unless Config.Storage is set, all of the data for the server is kept
+// in memory.
 type Server struct {
 	url      string
 	reqId    int
@@ -74,14 +124,25 @@ type Server struct {
 	mu       sync.Mutex
 	buckets  map[string]*bucket
 	config   *Config
+	storage  Storage
+	faults   *testutil.Faults
 }
 
 type bucket struct {
 	name             string
-	acl              s3.ACL
+	acl              *s3.AccessControlPolicy
 	ctime            time.Time
 	objects          map[string]*object
-	multipartUploads map[string][]*multipartUploadPart
+	multipartUploads map[string]*multipartUpload
+	config           map[string][]byte // configuration documents by subresource.
+	versioning       string            // empty if versioning was never enabled.
+
+	// versions holds all the versions of each key, latest first. The
+	// current version is also in objects unless it is a delete marker.
+	versions map[string][]*object
+	seq      int64 // the sequence number of the latest version added.
+
+	storage Storage // holds the state of the bucket and its objects.
 }
 
 type object struct {
@@ -89,13 +150,27 @@ type object struct {
 	mtime    time.Time
 	meta     http.Header // metadata to return with requests.
 	checksum []byte      // also held as Content-MD5 in meta.
-	data     []byte
+	size     int64       // of the data, held by the storage.
+	acl      *s3.AccessControlPolicy
+	tags     []s3.Tag
+
+	storageClass  string
+	restoreReady  time.Time // when the requested restore completes.
+	restoreExpiry time.Time // when the restored copy is removed.
+
+	versionId    string // empty if the bucket was never versioned.
+	deleteMarker bool
+	seq          int64 // orders the versions of a key, the latest highest.
+
+	multipartETag string // set if the object was uploaded in parts.
 }
 
-type multipartUploadPart struct {
-	data         []byte
-	etag         string
-	lastModified time.Time
+// etag returns the quoted entity tag of obj.
+func (obj *object) etag() string {
+	if obj.multipartETag != "" {
+		return obj.multipartETag
+	}
+	return fmt.Sprintf(`"%x"`, obj.checksum)
 }
 
 // A resource encapsulates the subject of an HTTP request.
@@ -115,18 +190,29 @@ func NewServer(config *Config) (*Server, error) {
 		listenAddress = config.ListenAddress
 	}
 
+	srv := &Server{
+		config:  config,
+		storage: config.storage(),
+	}
+	buckets, objects, err := srv.storage.Load()
+	if err == nil {
+		err = srv.load(buckets, objects)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot load the storage: %v", err)
+	}
+
 	l, err := net.Listen("tcp", listenAddress)
 	if err != nil {
 		return nil, fmt.Errorf("cannot listen on localhost: %v", err)
 	}
-	srv := &Server{
-		listener: l,
-		url:      "http://" + l.Addr().String(),
-		buckets:  make(map[string]*bucket),
-		config:   config,
-	}
+	srv.listener = l
+	srv.url = "http://" + l.Addr().String()
 	go http.Serve(l, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-		srv.serveHTTP(w, req)
+		srv.mu.Lock()
+		faults := srv.faults
+		srv.mu.Unlock()
+		faults.Serve(w, req, http.HandlerFunc(srv.serveHTTP))
 	}))
 	return srv, nil
 }
@@ -136,6 +222,14 @@ func (srv *Server) Quit() {
 	srv.listener.Close()
 }
 
+// SetFaults attaches f to the server so that its fault rules are applied
+// to subsequent requests. A nil f disables fault injection.
+func (srv *Server) SetFaults(f *testutil.Faults) {
+	srv.mu.Lock()
+	srv.faults = f
+	srv.mu.Unlock()
+}
+
 // URL returns a URL for the server.
 func (srv *Server) URL() string {
 	return srv.url
@@ -177,6 +271,20 @@ func (srv *Server) serveHTTP(w http.ResponseWriter, req *http.Request) {
 				err.BucketName = r.bucket.name
 			case bucketResource:
 				err.BucketName = r.name
+			case bucketConfigResource:
+				err.BucketName = r.name
+			case aclResource:
+				err.BucketName = r.name
+			case objectTaggingResource:
+				err.BucketName = r.bucket.name
+			case objectRestoreResource:
+				err.BucketName = r.bucket.name
+			case versioningResource:
+				err.BucketName = r.name
+			case multipartResource:
+				err.BucketName = r.bucket.name
+			case multipartUploadsResource:
+				err.BucketName = r.name
 			}
 			err.RequestId = a.reqId
 			// TODO HostId
@@ -221,21 +329,13 @@ func xmlMarshal(w io.Writer, x interface{}) {
 // In a fully implemented test server, each of these would have
 // its own resource type.
 var unimplementedBucketResourceNames = map[string]bool{
-	"acl":            true,
 	"lifecycle":      true,
-	"policy":         true,
 	"location":       true,
-	"logging":        true,
-	"notification":   true,
-	"versions":       true,
 	"requestPayment": true,
-	"versioning":     true,
 	"website":        true,
-	"uploads":        true,
 }
 
 var unimplementedObjectResourceNames = map[string]bool{
-	"acl":     true,
 	"torrent": true,
 }
 
@@ -257,11 +357,35 @@ func (srv *Server) resourceForURL(u *url.URL) (r resource) {
 		bucket: srv.buckets[bucketName],
 	}
 	q := u.Query()
+	if _, ok := q["acl"]; ok {
+		if b.bucket == nil {
+			fatalf(404, "NoSuchBucket", "The specified bucket does not exist")
+		}
+		return aclResource{bucketResource: b, objectName: objectName}
+	}
 	if objectName == "" {
 		for name := range q {
 			if unimplementedBucketResourceNames[name] {
 				return nullResource{}
 			}
+			if name == "versioning" {
+				if b.bucket == nil {
+					fatalf(404, "NoSuchBucket", "The specified bucket does not exist")
+				}
+				return versioningResource{b}
+			}
+			if name == "uploads" {
+				if b.bucket == nil {
+					fatalf(404, "NoSuchBucket", "The specified bucket does not exist")
+				}
+				return multipartUploadsResource{b}
+			}
+			if _, ok := bucketConfigErrors[name]; ok {
+				if b.bucket == nil {
+					fatalf(404, "NoSuchBucket", "The specified bucket does not exist")
+				}
+				return bucketConfigResource{bucketResource: b, subresource: name}
+			}
 		}
 		return b
 
@@ -279,9 +403,23 @@ func (srv *Server) resourceForURL(u *url.URL) (r resource) {
 			return nullResource{}
 		}
 	}
-	if obj := objr.bucket.objects[objr.name]; obj != nil {
+	if objr.version != "" {
+		objr.object = objr.bucket.version(objr.name, objr.version)
+	} else if obj := objr.bucket.objects[objr.name]; obj != nil {
 		objr.object = obj
 	}
+	if _, ok := q["tagging"]; ok {
+		return objectTaggingResource{objr}
+	}
+	if _, ok := q["restore"]; ok {
+		return objectRestoreResource{objr}
+	}
+	if _, ok := q["uploads"]; ok {
+		return multipartResource{objectResource: objr}
+	}
+	if uploadId, ok := q["uploadId"]; ok {
+		return multipartResource{objectResource: objr, uploadId: uploadId[0]}
+	}
 	return objr
 }
 
@@ -328,19 +466,38 @@ func (r bucketResource) get(a *action) interface{} {
 		return nil
 	}
 
-	var objs orderedObjects
-
-	// first get all matching objects and arrange them in alphabetical order.
-	for name, obj := range r.bucket.objects {
-		if strings.HasPrefix(name, prefix) {
-			objs = append(objs, obj)
-		}
-	}
-	sort.Sort(objs)
-
 	if maxKeys <= 0 {
 		maxKeys = 1000
 	}
+	if _, ok := a.req.Form["versions"]; ok {
+		return r.listVersions(a, prefix, delimiter, maxKeys)
+	}
+	if a.req.Form.Get("list-type") == "2" {
+		token := a.req.Form.Get("continuation-token")
+		startAfter := a.req.Form.Get("start-after")
+		if token != "" {
+			// The server's continuation tokens are the last name returned.
+			marker = token
+		} else {
+			marker = startAfter
+		}
+		resp := &s3.ListV2Resp{
+			Name:              r.bucket.name,
+			Prefix:            prefix,
+			Delimiter:         delimiter,
+			StartAfter:        startAfter,
+			ContinuationToken: token,
+			MaxKeys:           maxKeys,
+		}
+		var last string
+		resp.Contents, resp.CommonPrefixes, last, resp.IsTruncated = r.bucket.list(prefix, delimiter, marker, maxKeys)
+		resp.KeyCount = len(resp.Contents) + len(resp.CommonPrefixes)
+		if resp.IsTruncated {
+			resp.NextContinuationToken = last
+		}
+		return resp
+	}
+
 	resp := &s3.ListResp{
 		Name:      r.bucket.name,
 		Prefix:    prefix,
@@ -348,12 +505,25 @@ func (r bucketResource) get(a *action) interface{} {
 		Marker:    marker,
 		MaxKeys:   maxKeys,
 	}
+	resp.Contents, resp.CommonPrefixes, _, resp.IsTruncated = r.bucket.list(prefix, delimiter, marker, maxKeys)
+	return resp
+}
 
-	var prefixes []string
-	for _, obj := range objs {
-		if !strings.HasPrefix(obj.name, prefix) {
-			continue
+// list returns up to maxKeys keys and common prefixes of b, in
+// alphabetical order, that begin with prefix and sort after marker.
+// It also returns the last name listed and whether more names remain.
+func (b *bucket) list(prefix, delimiter, marker string, maxKeys int) (contents []s3.Key, prefixes []string, last string, truncated bool) {
+	var objs orderedObjects
+
+	// first get all matching objects and arrange them in alphabetical order.
+	for name, obj := range b.objects {
+		if strings.HasPrefix(name, prefix) {
+			objs = append(objs, obj)
 		}
+	}
+	sort.Sort(objs)
+
+	for _, obj := range objs {
 		name := obj.name
 		isPrefix := false
 		if delimiter != "" {
@@ -368,21 +538,394 @@ func (r bucketResource) get(a *action) interface{} {
 		if name <= marker {
 			continue
 		}
-		if len(resp.Contents)+len(prefixes) >= maxKeys {
-			resp.IsTruncated = true
+		if len(contents)+len(prefixes) >= maxKeys {
+			truncated = true
 			break
 		}
 		if isPrefix {
 			prefixes = append(prefixes, name)
 		} else {
 			// Contents contains only keys not found in CommonPrefixes
-			resp.Contents = append(resp.Contents, obj.s3Key())
+			contents = append(contents, obj.s3Key())
 		}
+		last = name
 	}
-	resp.CommonPrefixes = prefixes
-	return resp
+	return contents, prefixes, last, truncated
+}
+
+// bucketConfigErrors holds the configuration subresources stored by
+// bucketConfigResource, with the error returned when one is not set.
+var bucketConfigErrors = map[string]*s3Error{
+	"cors":        {statusCode: 404, Code: "NoSuchCORSConfiguration", Message: "The CORS configuration does not exist"},
+	"policy":      {statusCode: 404, Code: "NoSuchBucketPolicy", Message: "The bucket policy does not exist"},
+	"tagging":     {statusCode: 404, Code: "NoSuchTagSet", Message: "The TagSet does not exist"},
+	"replication": {statusCode: 404, Code: "ReplicationConfigurationNotFoundError", Message: "The replication configuration was not found"},
+	// These are reported as disabled instead.
+	"logging":      nil,
+	"notification": nil,
+}
+
+// bucketConfigDefaults holds the documents returned for the subresources
+// that are never missing.
+var bucketConfigDefaults = map[string]interface{}{
+	"logging":      &s3.BucketLoggingStatus{},
+	"notification": &s3.NotificationConfiguration{},
+}
+
+// bucketConfigResource is a configuration subresource of a bucket, such
+// as ?cors. Documents are stored as sent and returned verbatim.
+type bucketConfigResource struct {
+	bucketResource
+	subresource string
+}
+
+func (r bucketConfigResource) get(a *action) interface{} {
+	doc, ok := r.bucket.config[r.subresource]
+	if !ok {
+		if err := bucketConfigErrors[r.subresource]; err != nil {
+			e := *err
+			panic(&e)
+		}
+		return bucketConfigDefaults[r.subresource]
+	}
+	if r.subresource == "policy" {
+		a.w.Header().Set("Content-Type", "application/json")
+	} else {
+		a.w.Header().Set("Content-Type", "application/xml")
+	}
+	a.w.Write(doc)
+	return nil
+}
+
+func (r bucketConfigResource) put(a *action) interface{} {
+	doc, err := ioutil.ReadAll(a.req.Body)
+	if err != nil {
+		fatalf(400, "IncompleteBody", "%v", err)
+	}
+	if r.subresource == "policy" {
+		if !json.Valid(doc) {
+			fatalf(400, "MalformedPolicy", "Policies must be valid JSON")
+		}
+	} else if err := xml.Unmarshal(doc, new(struct{})); err != nil {
+		fatalf(400, "MalformedXML", "The XML you provided was not well-formed or did not validate against our published schema")
+	}
+	if r.subresource == "logging" && !bytes.Contains(doc, []byte("<LoggingEnabled>")) {
+		delete(r.bucket.config, r.subresource)
+	} else {
+		r.bucket.config[r.subresource] = doc
+	}
+	r.bucket.save()
+	return nil
+}
+
+func (r bucketConfigResource) post(a *action) interface{} { return notAllowed() }
+
+func (r bucketConfigResource) delete(a *action) interface{} {
+	delete(r.bucket.config, r.subresource)
+	r.bucket.save()
+	return nil
+}
+
+// owner owns every bucket and object of the server.
+var owner = s3.Owner{ID: "a6c0a6f8c2c7b1d4e5f60718293a4b5c6d7e8f90a1b2c3d4e5f60718293a4b5c", DisplayName: "s3test"}
+
+// cannedACLGrants holds the grants, besides the owner's full control,
+// of each canned ACL.
+var cannedACLGrants = map[s3.ACL][]s3.Grant{
+	s3.Private: nil,
+	s3.PublicRead: {
+		{Grantee: s3.GroupGrantee(s3.AllUsersGroup), Permission: s3.PermRead},
+	},
+	s3.PublicReadWrite: {
+		{Grantee: s3.GroupGrantee(s3.AllUsersGroup), Permission: s3.PermRead},
+		{Grantee: s3.GroupGrantee(s3.AllUsersGroup), Permission: s3.PermWrite},
+	},
+	s3.AuthenticatedRead: {
+		{Grantee: s3.GroupGrantee(s3.AuthenticatedUsersGroup), Permission: s3.PermRead},
+	},
+	s3.BucketOwnerRead: nil,
+	s3.BucketOwnerFull: nil,
+	"log-delivery-write": {
+		{Grantee: s3.GroupGrantee(s3.LogDeliveryGroup), Permission: s3.PermWrite},
+		{Grantee: s3.GroupGrantee(s3.LogDeliveryGroup), Permission: s3.PermReadACP},
+	},
+}
+
+var grantHeaderPerms = map[string]s3.Permission{
+	"X-Amz-Grant-Full-Control": s3.PermFullControl,
+	"X-Amz-Grant-Read":         s3.PermRead,
+	"X-Amz-Grant-Write":        s3.PermWrite,
+	"X-Amz-Grant-Read-Acp":     s3.PermReadACP,
+	"X-Amz-Grant-Write-Acp":    s3.PermWriteACP,
+}
+
+// cannedACL returns the policy corresponding to a canned ACL.
+func cannedACL(acl s3.ACL) *s3.AccessControlPolicy {
+	grants, ok := cannedACLGrants[acl]
+	if !ok {
+		fatalf(400, "InvalidArgument", "Invalid canned ACL %q", acl)
+	}
+	ownerGrant := s3.Grant{
+		Grantee:    s3.Grantee{Type: s3.CanonicalUser, ID: owner.ID, DisplayName: owner.DisplayName},
+		Permission: s3.PermFullControl,
+	}
+	return &s3.AccessControlPolicy{Owner: owner, Grants: append([]s3.Grant{ownerGrant}, grants...)}
+}
+
+// aclFromRequest returns the policy set by the x-amz-grant-* or x-amz-acl
+// headers of req, defaulting to private.
+func aclFromRequest(req *http.Request) *s3.AccessControlPolicy {
+	var grants []s3.Grant
+	for h, perm := range grantHeaderPerms {
+		if v := req.Header.Get(h); v != "" {
+			grants = append(grants, parseGrantHeader(v, perm)...)
+		}
+	}
+	canned := s3.ACL(req.Header.Get("x-amz-acl"))
+	if grants != nil {
+		if canned != "" {
+			fatalf(400, "InvalidRequest", "Specifying both Canned ACLs and Header Grants is not allowed")
+		}
+		sort.Sort(grantsByPermission(grants))
+		return &s3.AccessControlPolicy{Owner: owner, Grants: grants}
+	}
+	if canned == "" {
+		canned = s3.Private
+	}
+	return cannedACL(canned)
+}
+
+// parseGrantHeader parses the value of an x-amz-grant-* header, such as
+// `id="1234", uri="http://acs.amazonaws.com/groups/global/AllUsers"`.
+func parseGrantHeader(value string, perm s3.Permission) []s3.Grant {
+	var grants []s3.Grant
+	for _, item := range strings.Split(value, ",") {
+		kv := strings.SplitN(strings.TrimSpace(item), "=", 2)
+		if len(kv) != 2 {
+			fatalf(400, "InvalidArgument", "Invalid grant %q", item)
+		}
+		v, err := strconv.Unquote(kv[1])
+		if err != nil {
+			v = kv[1]
+		}
+		var g s3.Grantee
+		switch kv[0] {
+		case "id":
+			g = s3.CanonicalUserGrantee(v)
+		case "emailAddress":
+			g = s3.EmailGrantee(v)
+		case "uri":
+			g = s3.GroupGrantee(v)
+		default:
+			fatalf(400, "InvalidArgument", "Invalid grantee type %q", kv[0])
+		}
+		grants = append(grants, s3.Grant{Grantee: g, Permission: perm})
+	}
+	return grants
+}
+
+// grantsByPermission orders grants set from headers deterministically.
+type grantsByPermission []s3.Grant
+
+func (g grantsByPermission) Len() int           { return len(g) }
+func (g grantsByPermission) Less(i, j int) bool { return g[i].Permission < g[j].Permission }
+func (g grantsByPermission) Swap(i, j int)      { g[i], g[j] = g[j], g[i] }
+
+// aclResource is the ?acl subresource of a bucket or, if objectName is
+// set, of an object.
+type aclResource struct {
+	bucketResource
+	objectName string
+}
+
+// policy returns where the ACL of the resource is held.
+func (r aclResource) policy() **s3.AccessControlPolicy {
+	if r.objectName == "" {
+		return &r.bucket.acl
+	}
+	obj := r.bucket.objects[r.objectName]
+	if obj == nil {
+		fatalf(404, "NoSuchKey", "The specified key does not exist.")
+	}
+	return &obj.acl
+}
+
+func (r aclResource) get(a *action) interface{} {
+	p := r.policy()
+	if *p == nil {
+		*p = cannedACL(s3.Private)
+	}
+	return *p
+}
+
+func (r aclResource) put(a *action) interface{} {
+	p := r.policy()
+	data, err := ioutil.ReadAll(a.req.Body)
+	if err != nil {
+		fatalf(400, "IncompleteBody", "%v", err)
+	}
+	if len(data) == 0 {
+		*p = aclFromRequest(a.req)
+		r.save()
+		return nil
+	}
+	policy := &s3.AccessControlPolicy{}
+	if err := xml.Unmarshal(data, policy); err != nil {
+		fatalf(400, "MalformedACLError", "The XML you provided was not well-formed or did not validate against our published schema")
+	}
+	if policy.Owner.ID != owner.ID {
+		fatalf(403, "AccessDenied", "Access Denied")
+	}
+	for i := range policy.Grants {
+		g := &policy.Grants[i].Grantee
+		if g.Type == s3.CanonicalUser && g.ID == owner.ID {
+			g.DisplayName = owner.DisplayName
+		}
+	}
+	*p = policy
+	r.save()
+	return nil
+}
+
+// save saves the ACL of the resource in the storage.
+func (r aclResource) save() {
+	if r.objectName == "" {
+		r.bucket.save()
+	} else {
+		r.bucket.saveObject(r.bucket.objects[r.objectName])
+	}
+}
+
+func (r aclResource) post(a *action) interface{} { return notAllowed() }
+
+func (r aclResource) delete(a *action) interface{} { return notAllowed() }
+
+// tagsFromRequest returns the tags set by the x-amz-tagging header of
+// req.
+func tagsFromRequest(req *http.Request) []s3.Tag {
+	h := req.Header.Get("x-amz-tagging")
+	if h == "" {
+		return nil
+	}
+	var tags []s3.Tag
+	for _, pair := range strings.Split(h, "&") {
+		kv := strings.SplitN(pair, "=", 2)
+		var key, value string
+		var err error
+		if len(kv) == 2 {
+			key, err = url.QueryUnescape(kv[0])
+			if err == nil {
+				value, err = url.QueryUnescape(kv[1])
+			}
+		}
+		if len(kv) != 2 || err != nil {
+			fatalf(400, "InvalidArgument", "The header 'x-amz-tagging' shall be encoded as UTF-8 then URLEncoded URL query parameters without tag name duplicates.")
+		}
+		tags = append(tags, s3.Tag{Key: key, Value: value})
+	}
+	return tags
+}
+
+// objectTaggingResource is the ?tagging subresource of an object.
+type objectTaggingResource struct {
+	objectResource
+}
+
+func (r objectTaggingResource) get(a *action) interface{} {
+	if r.object == nil {
+		fatalf(404, "NoSuchKey", "The specified key does not exist.")
+	}
+	return &s3.Tagging{TagSet: r.object.tags}
+}
+
+func (r objectTaggingResource) put(a *action) interface{} {
+	if r.object == nil {
+		fatalf(404, "NoSuchKey", "The specified key does not exist.")
+	}
+	var tagging s3.Tagging
+	if err := xml.NewDecoder(a.req.Body).Decode(&tagging); err != nil {
+		fatalf(400, "MalformedXML", "The XML you provided was not well-formed or did not validate against our published schema")
+	}
+	r.object.tags = tagging.TagSet
+	r.bucket.saveObject(r.object)
+	return nil
+}
+
+func (r objectTaggingResource) post(a *action) interface{} { return notAllowed() }
+
+func (r objectTaggingResource) delete(a *action) interface{} {
+	if r.object == nil {
+		fatalf(404, "NoSuchKey", "The specified key does not exist.")
+	}
+	r.object.tags = nil
+	r.bucket.saveObject(r.object)
+	return nil
+}
+
+// archived reports whether obj is in a storage class that must be
+// restored before it can be read.
+func (obj *object) archived() bool {
+	return obj.storageClass == string(s3.GlacierStorage) || obj.storageClass == string(s3.DeepArchiveStorage)
 }
 
+// restoreStatus returns the x-amz-restore header of obj at time now, and
+// whether its data can be read. A restored copy that expired is dropped.
+func (obj *object) restoreStatus(now time.Time) (header string, readable bool) {
+	if !obj.restoreExpiry.IsZero() && now.After(obj.restoreExpiry) {
+		obj.restoreReady, obj.restoreExpiry = time.Time{}, time.Time{}
+	}
+	switch {
+	case obj.restoreReady.IsZero():
+		return "", !obj.archived()
+	case now.Before(obj.restoreReady):
+		return `ongoing-request="true"`, false
+	}
+	return fmt.Sprintf(`ongoing-request="false", expiry-date="%s"`, obj.restoreExpiry.UTC().Format(http.TimeFormat)), true
+}
+
+// objectRestoreResource is the ?restore subresource of an object.
+type objectRestoreResource struct {
+	objectResource
+}
+
+// POST on the restore subresource starts restoring an archived object,
+// which becomes readable after Config.RestoreDelay.
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_RestoreObject.html
+func (r objectRestoreResource) post(a *action) interface{} {
+	obj := r.object
+	if obj == nil {
+		fatalf(404, "NoSuchKey", "The specified key does not exist.")
+	}
+	if !obj.archived() {
+		fatalf(403, "InvalidObjectState", "Restore is not allowed for the object's current storage class")
+	}
+	var req s3.RestoreRequest
+	if err := xml.NewDecoder(a.req.Body).Decode(&req); err != nil {
+		fatalf(400, "MalformedXML", "The XML you provided was not well-formed or did not validate against our published schema")
+	}
+	if req.Days < 1 {
+		fatalf(400, "InvalidArgument", "Days must be a positive integer")
+	}
+	now := time.Now()
+	lifetime := time.Duration(req.Days) * 24 * time.Hour
+	switch _, readable := obj.restoreStatus(now); {
+	case obj.restoreReady.IsZero():
+		obj.restoreReady = now.Add(a.srv.config.restoreDelay())
+		obj.restoreExpiry = obj.restoreReady.Add(lifetime)
+		a.w.WriteHeader(http.StatusAccepted)
+	case !readable:
+		fatalf(409, "RestoreAlreadyInProgress", "Object restore is already in progress")
+	default:
+		obj.restoreExpiry = now.Add(lifetime)
+	}
+	r.bucket.saveObject(obj)
+	return nil
+}
+
+func (r objectRestoreResource) get(a *action) interface{}    { return notAllowed() }
+func (r objectRestoreResource) put(a *action) interface{}    { return notAllowed() }
+func (r objectRestoreResource) delete(a *action) interface{} { return notAllowed() }
+
 // orderedObjects holds a slice of objects that can be sorted
 // by name.
 type orderedObjects []*object
@@ -401,22 +944,43 @@ func (obj *object) s3Key() s3.Key {
 	return s3.Key{
 		Key:          obj.name,
 		LastModified: obj.mtime.Format(timeFormat),
-		Size:         int64(len(obj.data)),
-		ETag:         fmt.Sprintf(`"%x"`, obj.checksum),
-		// TODO StorageClass
+		Size:         obj.size,
+		ETag:         obj.etag(),
+		StorageClass: obj.listStorageClass(),
 		// TODO Owner
 	}
 }
 
+func (obj *object) listStorageClass() string {
+	return storageClassName(obj.storageClass)
+}
+
+// storageClassName returns the storage class listed for objects stored in
+// class, as given by the x-amz-storage-class header.
+func storageClassName(class string) string {
+	if class == "" {
+		return string(s3.StandardStorage)
+	}
+	return class
+}
+
 // DELETE on a bucket deletes the bucket if it's not empty.
 func (r bucketResource) delete(a *action) interface{} {
 	b := r.bucket
 	if b == nil {
 		fatalf(404, "NoSuchBucket", "The specified bucket does not exist")
 	}
-	if len(b.objects) > 0 {
+	if len(b.versions) > 0 {
 		fatalf(400, "BucketNotEmpty", "The bucket you tried to delete is not empty")
 	}
+	for id := range b.multipartUploads {
+		if err := b.storage.DeleteParts(b.name, id); err != nil {
+			storageError(err)
+		}
+	}
+	if err := b.storage.DeleteBucket(b.name); err != nil {
+		storageError(err)
+	}
 	delete(a.srv.buckets, b.name)
 	return nil
 }
@@ -425,6 +989,7 @@ func (r bucketResource) delete(a *action) interface{} {
 // http://docs.amazonwebservices.com/AmazonS3/latest/API/RESTBucketPUT.html
 func (r bucketResource) put(a *action) interface{} {
 	var created bool
+	acl := aclFromRequest(a.req)
 	if r.bucket == nil {
 		if !validBucketName(r.name) {
 			fatalf(400, "InvalidBucketName", "The specified bucket is not valid")
@@ -432,20 +997,15 @@ func (r bucketResource) put(a *action) interface{} {
 		if loc := locationConstraint(a); loc == "" {
 			fatalf(400, "InvalidRequets", "The unspecified location constraint is incompatible for the region specific endpoint this request was sent to.")
 		}
-		// TODO validate acl
-		r.bucket = &bucket{
-			name: r.name,
-			// TODO default acl
-			objects:          make(map[string]*object),
-			multipartUploads: make(map[string][]*multipartUploadPart),
-		}
+		r.bucket = newBucket(&BucketInfo{Name: r.name, Created: time.Now()}, a.srv.storage)
 		a.srv.buckets[r.name] = r.bucket
 		created = true
 	}
 	if !created && a.srv.config.send409Conflict() {
 		fatalf(409, "BucketAlreadyOwnedByYou", "Your previous request to create the named bucket succeeded and you already own it.")
 	}
-	r.bucket.acl = s3.ACL(a.req.Header.Get("x-amz-acl"))
+	r.bucket.acl = acl
+	r.bucket.save()
 	return nil
 }
 
@@ -453,6 +1013,9 @@ func (r bucketResource) post(a *action) interface{} {
 	if _, multiDel := a.req.URL.Query()["delete"]; multiDel {
 		return r.multiDel(a)
 	}
+	if strings.HasPrefix(a.req.Header.Get("Content-Type"), "multipart/form-data") {
+		return r.postObject(a)
+	}
 
 	fatalf(400, "Method", "bucket operation not supported")
 	return nil
@@ -470,8 +1033,11 @@ func (b bucketResource) multiDel(a *action) interface{} {
 	}
 
 	type multiDelDelete struct {
-		XMLName struct{} `xml:"Deleted"`
-		Key     string
+		XMLName               struct{} `xml:"Deleted"`
+		Key                   string
+		VersionId             string `xml:",omitempty"`
+		DeleteMarker          bool   `xml:",omitempty"`
+		DeleteMarkerVersionId string `xml:",omitempty"`
 	}
 
 	type multiDelError struct {
@@ -492,6 +1058,9 @@ func (b bucketResource) multiDel(a *action) interface{} {
 	if err := xml.NewDecoder(a.req.Body).Decode(req); err != nil {
 		fatalf(400, "InvalidRequest", err.Error())
 	}
+	if len(req.Object) == 0 || len(req.Object) > 1000 {
+		fatalf(400, "MalformedXML", "The XML you provided was not well-formed or did not validate against our published schema")
+	}
 
 	res := &multiDelResult{
 		Deleted: []*multiDelDelete{},
@@ -499,12 +1068,23 @@ func (b bucketResource) multiDel(a *action) interface{} {
 	}
 
 	for _, o := range req.Object {
-		if _, exists := b.bucket.objects[o.Key]; exists {
-			delete(b.bucket.objects, o.Key)
-
-			res.Deleted = append(res.Deleted, &multiDelDelete{
-				Key: o.Key,
-			})
+		if _, exists := b.bucket.versions[o.Key]; exists {
+			deleted := &multiDelDelete{
+				Key:       o.Key,
+				VersionId: o.VersionId,
+			}
+			if o.VersionId != "" {
+				if v := b.bucket.deleteVersion(o.Key, o.VersionId); v != nil && v.deleteMarker {
+					deleted.DeleteMarker = true
+					deleted.DeleteMarkerVersionId = o.VersionId
+				}
+			} else if marker := b.bucket.deleteObject(o.Key); marker != nil {
+				deleted.DeleteMarker = true
+				deleted.DeleteMarkerVersionId = marker.versionIdOrNull()
+			}
+			if !req.Quiet {
+				res.Deleted = append(res.Deleted, deleted)
+			}
 		} else {
 			res.Error = append(res.Error, &multiDelError{
 				Key:     o.Key,
@@ -574,10 +1154,24 @@ type objectResource struct {
 // http://docs.amazonwebservices.com/AmazonS3/latest/API/RESTObjectGET.html
 func (objr objectResource) get(a *action) interface{} {
 	obj := objr.object
+	h := a.w.Header()
 	if obj == nil {
+		if objr.version != "" {
+			fatalf(404, "NoSuchVersion", "The specified version does not exist.")
+		}
+		if latest := objr.bucket.latest(objr.name); latest != nil {
+			setVersionHeader(h, latest)
+		}
 		fatalf(404, "NoSuchKey", "The specified key does not exist.")
 	}
-	h := a.w.Header()
+	if obj.deleteMarker {
+		setVersionHeader(h, obj)
+		fatalf(405, "MethodNotAllowed", "The specified method is not allowed against this resource.")
+	}
+	restore, readable := obj.restoreStatus(time.Now())
+	if a.req.Method != "HEAD" && !readable {
+		fatalf(403, "InvalidObjectState", "The operation is not valid for the object's storage class")
+	}
 	// add metadata
 	for name, d := range obj.meta {
 		h[name] = d
@@ -592,24 +1186,44 @@ func (objr objectResource) get(a *action) interface{} {
 			h.Set(name, vals[0])
 		}
 	}
-	if r := a.req.Header.Get("Range"); r != "" {
-		fatalf(400, "NotImplemented", "range unimplemented")
-	}
-	// TODO Last-Modified-Since
-	// TODO If-Modified-Since
-	// TODO If-Unmodified-Since
-	// TODO If-Match
-	// TODO If-None-Match
 	// TODO Connection: close ??
 	// TODO x-amz-request-id
-	h.Set("Content-Length", fmt.Sprint(len(obj.data)))
-	h.Set("ETag", hex.EncodeToString(obj.checksum))
-	h.Set("Last-Modified", obj.mtime.Format(time.RFC1123))
+	h.Set("ETag", obj.etag())
+	h.Set("Last-Modified", obj.mtime.UTC().Format(http.TimeFormat))
+	h.Set("Accept-Ranges", "bytes")
+	setVersionHeader(h, obj)
+	if len(obj.tags) != 0 {
+		h.Set("x-amz-tagging-count", strconv.Itoa(len(obj.tags)))
+	}
+	if obj.storageClass != "" && obj.storageClass != string(s3.StandardStorage) {
+		h.Set("x-amz-storage-class", obj.storageClass)
+	}
+	if restore != "" {
+		h.Set("x-amz-restore", restore)
+	}
+	if checkConditions(a.req, obj) {
+		a.w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+	start, end := int64(0), obj.size-1
+	if first, last, ok := parseRange(a.req.Header.Get("Range"), obj.size, h); ok {
+		h.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", first, last, obj.size))
+		h.Set("Content-Length", fmt.Sprint(last-first+1))
+		a.w.WriteHeader(http.StatusPartialContent)
+		start, end = first, last
+	} else {
+		h.Set("Content-Length", fmt.Sprint(obj.size))
+	}
 	if a.req.Method == "HEAD" {
 		return nil
 	}
+	data := objr.bucket.open(obj)
+	defer data.Close()
 	// TODO avoid holding the lock when writing data.
-	_, err := a.w.Write(obj.data)
+	_, err := io.CopyN(ioutil.Discard, data, start)
+	if err == nil {
+		_, err = io.CopyN(a.w, data, end-start+1)
+	}
 	if err != nil {
 		// we can't do much except just log the fact.
 		log.Printf("error writing data: %v", err)
@@ -630,34 +1244,118 @@ func (objr objectResource) put(a *action) interface{} {
 	// TODO Cache-Control header
 	// TODO Expires header
 	// TODO x-amz-server-side-encryption
-	// TODO x-amz-storage-class
 
-	uploadId := a.req.URL.Query().Get("uploadId")
+	if source := a.req.Header.Get("x-amz-copy-source"); source != "" {
+		return objr.copy(a, source)
+	}
+	obj := &object{
+		name:         objr.name,
+		mtime:        time.Now(),
+		meta:         metaFromRequest(a.req),
+		acl:          aclFromRequest(a.req),
+		tags:         tagsFromRequest(a.req),
+		storageClass: a.req.Header.Get("x-amz-storage-class"),
+	}
+	// A PUT replaces the object, metadata included.
+	objr.bucket.putObject(obj, bodyReader(a))
+	a.w.Header().Set("ETag", obj.etag())
+	setVersionHeader(a.w.Header(), obj)
+	return nil
+}
 
-	// Check that the upload ID is valid if this is a multipart upload
-	if uploadId != "" {
-		if _, ok := objr.bucket.multipartUploads[uploadId]; !ok {
-			fatalf(404, "NoSuchUpload", "The specified multipart upload does not exist. The upload ID might be invalid, or the multipart upload might have been aborted or completed.")
-		}
+// maxCopySize is the size of the largest object a PUT can copy.
+const maxCopySize int64 = 5 * 1024 * 1024 * 1024
 
-		partNumberStr := a.req.URL.Query().Get("partNumber")
+type copyObjectResult struct {
+	XMLName      struct{} `xml:"CopyObjectResult"`
+	LastModified string
+	ETag         string
+}
 
-		if partNumberStr == "" {
-			fatalf(400, "InvalidRequest", "Missing partNumber parameter")
-		}
+// copy creates the object as a copy of the object named by source, the
+// value of the x-amz-copy-source header. The metadata and tags of the
+// source are kept unless the request replaces them.
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_CopyObject.html
+func (objr objectResource) copy(a *action, source string) interface{} {
+	b, src := copySource(a, source)
+	if src.size > maxCopySize {
+		fatalf(400, "InvalidRequest", "The specified copy source is larger than the maximum allowable size for a copy source: %d", maxCopySize)
+	}
+	replaceMeta := a.req.Header.Get("x-amz-metadata-directive") == "REPLACE"
+	storageClass := a.req.Header.Get("x-amz-storage-class")
+	if b == objr.bucket && src.name == objr.name && !replaceMeta && storageClass == "" {
+		fatalf(400, "InvalidRequest", "This copy request is illegal because it is trying to copy an object to itself without changing the object's metadata, storage class, website redirect location or encryption attributes.")
+	}
+	data := copySourceRange(a, b, src)
+	defer data.Close()
+	obj := &object{
+		name:         objr.name,
+		mtime:        time.Now(),
+		meta:         src.meta.Clone(),
+		acl:          aclFromRequest(a.req),
+		tags:         src.tags,
+		storageClass: storageClass,
+	}
+	if replaceMeta {
+		obj.meta = metaFromRequest(a.req)
+	}
+	if a.req.Header.Get("x-amz-tagging-directive") == "REPLACE" {
+		obj.tags = tagsFromRequest(a.req)
+	}
+	objr.bucket.putObject(obj, newContentReader(data))
+	setVersionHeader(a.w.Header(), obj)
+	if src.versionId != "" {
+		a.w.Header().Set("x-amz-copy-source-version-id", src.versionId)
+	}
+	return &copyObjectResult{
+		LastModified: obj.mtime.Format(timeFormat),
+		ETag:         obj.etag(),
+	}
+}
 
-		partNumber, err := strconv.ParseUint(partNumberStr, 10, 32)
+// contentReader reads the content of a new object or part, computing its
+// size and MD5 sum. Once r is exhausted they are passed to check, if set,
+// and then set on info, if set. A failed check is returned as the error
+// of the last Read and kept in failure, to be sent as the response.
+type contentReader struct {
+	r       io.Reader
+	hash    hash.Hash
+	size    int64
+	sum     []byte // set once r is exhausted.
+	body    bool   // r is the body of the request.
+	check   func(size int64, sum []byte) *s3Error
+	info    *ObjectInfo
+	failure *s3Error
+}
 
-		if err != nil {
-			fatalf(400, "InvalidRequest", "partNumber is not a number")
-		}
+func newContentReader(r io.Reader) *contentReader {
+	return &contentReader{r: r, hash: md5.New()}
+}
 
-		// Parts are 1-indexed for multipart uploads
-		if uint(partNumber)-1 != uint(len(objr.bucket.multipartUploads[uploadId])) {
-			fatalf(400, "InvalidRequest", "Invalid part number")
+func (c *contentReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.hash.Write(p[:n])
+	c.size += int64(n)
+	switch {
+	case err == io.EOF:
+		c.sum = c.hash.Sum(nil)
+		if c.check != nil {
+			if c.failure = c.check(c.size, c.sum); c.failure != nil {
+				return n, errors.New(c.failure.Message)
+			}
 		}
+		if c.info != nil {
+			c.info.Size, c.info.MD5 = c.size, c.sum
+		}
+	case err != nil && c.body:
+		c.failure = &s3Error{statusCode: 400, Code: "IncompleteBody", Message: err.Error()}
 	}
+	return n, err
+}
 
+// bodyReader returns a reader of the body of a request uploading data,
+// which checks it against the Content-MD5 and Content-Length headers.
+func bodyReader(a *action) *contentReader {
 	var expectHash []byte
 	if c := a.req.Header.Get("Content-MD5"); c != "" {
 		var err error
@@ -666,186 +1364,50 @@ func (objr objectResource) put(a *action) interface{} {
 			fatalf(400, "InvalidDigest", "The Content-MD5 you specified was invalid")
 		}
 	}
-	sum := md5.New()
+	length := a.req.ContentLength
 	// TODO avoid holding lock while reading data.
-	data, err := ioutil.ReadAll(io.TeeReader(a.req.Body, sum))
-	if err != nil {
-		fatalf(400, "TODO", "read error")
-	}
-	gotHash := sum.Sum(nil)
-	if expectHash != nil && bytes.Compare(gotHash, expectHash) != 0 {
-		fatalf(400, "BadDigest", "The Content-MD5 you specified did not match what we received")
-	}
-	if a.req.ContentLength >= 0 && int64(len(data)) != a.req.ContentLength {
-		fatalf(400, "IncompleteBody", "You did not provide the number of bytes specified by the Content-Length HTTP header")
-	}
-
-	etag := fmt.Sprintf("\"%x\"", gotHash)
-
-	a.w.Header().Add("ETag", etag)
-
-	if uploadId == "" {
-		// For traditional uploads
-
-		// TODO is this correct, or should we erase all previous metadata?
-		obj := objr.object
-		if obj == nil {
-			obj = &object{
-				name: objr.name,
-				meta: make(http.Header),
-			}
+	c := newContentReader(a.req.Body)
+	c.body = true
+	c.check = func(size int64, sum []byte) *s3Error {
+		if expectHash != nil && !bytes.Equal(sum, expectHash) {
+			return &s3Error{statusCode: 400, Code: "BadDigest", Message: "The Content-MD5 you specified did not match what we received"}
 		}
-
-		// PUT request has been successful - save data and metadata
-		for key, values := range a.req.Header {
-			key = http.CanonicalHeaderKey(key)
-			if metaHeaders[key] || strings.HasPrefix(key, "X-Amz-Meta-") {
-				obj.meta[key] = values
-			}
+		if length >= 0 && size != length {
+			return &s3Error{statusCode: 400, Code: "IncompleteBody", Message: "You did not provide the number of bytes specified by the Content-Length HTTP header"}
 		}
-		obj.data = data
-		obj.checksum = gotHash
-		obj.mtime = time.Now()
-		objr.bucket.objects[objr.name] = obj
-	} else {
-		// For multipart commit
+		return nil
+	}
+	return c
+}
 
-		parts := objr.bucket.multipartUploads[uploadId]
-		part := &multipartUploadPart{
-			data,
-			etag,
-			time.Now(),
+// metaFromRequest returns the headers of an upload request that are
+// stored as metadata of the object.
+func metaFromRequest(req *http.Request) http.Header {
+	meta := make(http.Header)
+	for key, values := range req.Header {
+		key = http.CanonicalHeaderKey(key)
+		if metaHeaders[key] || strings.HasPrefix(key, "X-Amz-Meta-") {
+			meta[key] = values
 		}
-
-		objr.bucket.multipartUploads[uploadId] = append(parts, part)
 	}
-
-	return nil
+	return meta
 }
 
 func (objr objectResource) delete(a *action) interface{} {
-	uploadId := a.req.URL.Query().Get("uploadId")
-
-	if uploadId == "" {
-		// Traditional object delete
-		delete(objr.bucket.objects, objr.name)
+	var deleted *object
+	if objr.version != "" {
+		deleted = objr.bucket.deleteVersion(objr.name, objr.version)
 	} else {
-		// Multipart commit abort
-		_, ok := objr.bucket.multipartUploads[uploadId]
-
-		if !ok {
-			fatalf(404, "NoSuchUpload", "The specified multipart upload does not exist. The upload ID might be invalid, or the multipart upload might have been aborted or completed.")
-		}
-
-		delete(objr.bucket.multipartUploads, uploadId)
+		deleted = objr.bucket.deleteObject(objr.name)
+	}
+	if deleted != nil {
+		setVersionHeader(a.w.Header(), deleted)
 	}
 	return nil
 }
 
 func (objr objectResource) post(a *action) interface{} {
-	// Check if we're initializing a multipart upload
-	if _, ok := a.req.URL.Query()["uploads"]; ok {
-		type multipartInitResponse struct {
-			XMLName  struct{} `xml:"InitiateMultipartUploadResult"`
-			Bucket   string
-			Key      string
-			UploadId string
-		}
-
-		uploadId := strconv.FormatInt(rand.Int63(), 16)
-
-		objr.bucket.multipartUploads[uploadId] = []*multipartUploadPart{}
-
-		return &multipartInitResponse{
-			Bucket:   objr.bucket.name,
-			Key:      objr.name,
-			UploadId: uploadId,
-		}
-	}
-
-	// Check if we're completing a multipart upload
-	if uploadId := a.req.URL.Query().Get("uploadId"); uploadId != "" {
-		type multipartCompleteRequestPart struct {
-			XMLName    struct{} `xml:"Part"`
-			PartNumber uint
-			ETag       string
-		}
-
-		type multipartCompleteRequest struct {
-			XMLName struct{} `xml:"CompleteMultipartUpload"`
-			Part    []multipartCompleteRequestPart
-		}
-
-		type multipartCompleteResponse struct {
-			XMLName  struct{} `xml:"CompleteMultipartUploadResult"`
-			Location string
-			Bucket   string
-			Key      string
-			ETag     string
-		}
-
-		parts, ok := objr.bucket.multipartUploads[uploadId]
-
-		if !ok {
-			fatalf(404, "NoSuchUpload", "The specified multipart upload does not exist. The upload ID might be invalid, or the multipart upload might have been aborted or completed.")
-		}
-
-		req := &multipartCompleteRequest{}
-
-		if err := xml.NewDecoder(a.req.Body).Decode(req); err != nil {
-			fatalf(400, "InvalidRequest", err.Error())
-		}
-
-		if len(req.Part) != len(parts) {
-			fatalf(400, "InvalidRequest", fmt.Sprintf("Number of parts does not match: expected %d, received %d", len(parts), len(req.Part)))
-		}
-
-		sum := md5.New()
-		data := &bytes.Buffer{}
-		w := io.MultiWriter(sum, data)
-
-		for i, p := range parts {
-			reqPart := req.Part[i]
-
-			if reqPart.PartNumber != uint(1+i) {
-				fatalf(400, "InvalidRequest", "Bad part number")
-			}
-
-			if reqPart.ETag != p.etag {
-				fatalf(400, "InvalidRequest", fmt.Sprintf("Invalid etag for part %d", reqPart.PartNumber))
-			}
-
-			w.Write(p.data)
-		}
-
-		delete(objr.bucket.multipartUploads, uploadId)
-
-		obj := objr.object
-
-		if obj == nil {
-			obj = &object{
-				name: objr.name,
-				meta: make(http.Header),
-			}
-		}
-
-		obj.data = data.Bytes()
-		obj.checksum = sum.Sum(nil)
-		obj.mtime = time.Now()
-		objr.bucket.objects[objr.name] = obj
-
-		objectLocation := fmt.Sprintf("http://%s/%s/%s", a.srv.listener.Addr().String(), objr.bucket.name, objr.name)
-
-		return &multipartCompleteResponse{
-			Location: objectLocation,
-			Bucket:   objr.bucket.name,
-			Key:      objr.name,
-			ETag:     uploadId,
-		}
-	}
-
-	fatalf(400, "MethodNotAllowed", "The specified method is not allowed against this resource")
-	return nil
+	return notAllowed()
 }
 
 type CreateBucketConfiguration struct {
@@ -0,0 +1,427 @@
+package s3test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/AdRoll/goamz/s3"
+)
+
+// Storage holds the buckets and objects of a Server. The server keeps the
+// metadata of all of them in memory, saving every change to its Storage,
+// but only the Storage holds the content of the objects and of the parts
+// of multipart uploads.
+//
+// Multipart uploads in progress are not saved: their parts are only held
+// until the upload is completed or aborted, or the server is restored.
+type Storage interface {
+	// Load returns the buckets and objects stored, to populate a new
+	// server, and deletes the parts of multipart uploads left by the
+	// previous one.
+	Load() ([]*BucketInfo, []*ObjectInfo, error)
+
+	// PutBucket creates or updates a bucket.
+	PutBucket(b *BucketInfo) error
+
+	// DeleteBucket deletes a bucket, whose objects were all deleted.
+	DeleteBucket(name string) error
+
+	// PutObject stores a version of an object with the content read from
+	// data, replacing the version with the same ID, if any. The data of
+	// delete markers is nil. data must be read to its end before obj is
+	// saved, as the Size and MD5 of obj are only set then. If reading
+	// data fails, as it does when the content does not match the request
+	// uploading it, nothing is stored.
+	PutObject(obj *ObjectInfo, data io.Reader) error
+
+	// UpdateObject updates the metadata of a stored version of an object.
+	UpdateObject(obj *ObjectInfo) error
+
+	// OpenObject returns the content of a stored version of an object.
+	OpenObject(obj *ObjectInfo) (io.ReadCloser, error)
+
+	// DeleteObject deletes a stored version of an object.
+	DeleteObject(obj *ObjectInfo) error
+
+	// PutPart stores part n of the multipart upload uploadId of bucket
+	// with the content read from data, replacing the part n stored
+	// before, if any. As with PutObject, nothing is stored if reading
+	// data fails.
+	PutPart(bucket, uploadId string, n int, data io.Reader) error
+
+	// OpenPart returns the content of a stored part.
+	OpenPart(bucket, uploadId string, n int) (io.ReadCloser, error)
+
+	// DeleteParts deletes the stored parts of a multipart upload.
+	DeleteParts(bucket, uploadId string) error
+}
+
+// BucketInfo is the state of a bucket held by a Storage.
+type BucketInfo struct {
+	Name       string
+	Created    time.Time
+	ACL        *s3.AccessControlPolicy
+	Versioning string            `json:",omitempty"` // empty if versioning was never enabled.
+	Config     map[string]string `json:",omitempty"` // configuration documents by subresource.
+}
+
+// ObjectInfo is the metadata of a version of an object held by a Storage.
+// Versions are identified by Bucket, Key and VersionId, an empty VersionId
+// being the same as "null".
+type ObjectInfo struct {
+	Bucket       string
+	Key          string
+	VersionId    string `json:",omitempty"` // empty if the bucket was never versioned.
+	DeleteMarker bool   `json:",omitempty"`
+	LastModified time.Time
+	Size         int64
+	MD5          []byte
+	Meta         http.Header             `json:",omitempty"` // headers returned with the content.
+	ETag         string                  `json:",omitempty"` // set if the object was uploaded in parts.
+	ACL          *s3.AccessControlPolicy `json:",omitempty"`
+	Tags         []s3.Tag                `json:",omitempty"`
+	StorageClass string                  `json:",omitempty"`
+
+	// Seq orders the versions of an object, the latest having the
+	// highest. Versions stored without one are ordered by LastModified.
+	Seq int64 `json:",omitempty"`
+
+	// RestoreReady and RestoreExpiry are set while an object stored in
+	// the GLACIER or DEEP_ARCHIVE storage class is being restored, or is
+	// restored.
+	RestoreReady  time.Time
+	RestoreExpiry time.Time
+}
+
+// storageError fails the request being served with an error of its Storage.
+func storageError(err error) {
+	fatalf(500, "InternalError", "We encountered an internal error. Please try again. (%v)", err)
+}
+
+func (b *bucket) info() *BucketInfo {
+	info := &BucketInfo{
+		Name:       b.name,
+		Created:    b.ctime,
+		ACL:        b.acl,
+		Versioning: b.versioning,
+	}
+	if len(b.config) > 0 {
+		info.Config = make(map[string]string)
+		for sub, doc := range b.config {
+			info.Config[sub] = string(doc)
+		}
+	}
+	return info
+}
+
+func newBucket(info *BucketInfo, storage Storage) *bucket {
+	b := &bucket{
+		name:             info.Name,
+		acl:              info.ACL,
+		ctime:            info.Created,
+		objects:          make(map[string]*object),
+		multipartUploads: make(map[string]*multipartUpload),
+		config:           make(map[string][]byte),
+		versioning:       info.Versioning,
+		versions:         make(map[string][]*object),
+		storage:          storage,
+	}
+	for sub, doc := range info.Config {
+		b.config[sub] = []byte(doc)
+	}
+	return b
+}
+
+// save saves the changes to b in its storage.
+func (b *bucket) save() {
+	if err := b.storage.PutBucket(b.info()); err != nil {
+		storageError(err)
+	}
+}
+
+// saveObject saves the changes to the metadata of obj in the storage of b.
+func (b *bucket) saveObject(obj *object) {
+	if err := b.storage.UpdateObject(obj.info(b.name)); err != nil {
+		storageError(err)
+	}
+}
+
+// open returns the content of obj, a version of an object of b.
+func (b *bucket) open(obj *object) io.ReadCloser {
+	r, err := b.storage.OpenObject(obj.info(b.name))
+	if err != nil {
+		storageError(err)
+	}
+	return r
+}
+
+func (obj *object) info(bucket string) *ObjectInfo {
+	return &ObjectInfo{
+		Bucket:        bucket,
+		Key:           obj.name,
+		VersionId:     obj.versionId,
+		DeleteMarker:  obj.deleteMarker,
+		LastModified:  obj.mtime,
+		Size:          obj.size,
+		MD5:           obj.checksum,
+		Meta:          obj.meta,
+		ETag:          obj.multipartETag,
+		ACL:           obj.acl,
+		Tags:          obj.tags,
+		StorageClass:  obj.storageClass,
+		RestoreReady:  obj.restoreReady,
+		RestoreExpiry: obj.restoreExpiry,
+		Seq:           obj.seq,
+	}
+}
+
+func newObject(info *ObjectInfo) *object {
+	return &object{
+		name:          info.Key,
+		mtime:         info.LastModified,
+		meta:          info.Meta,
+		checksum:      info.MD5,
+		size:          info.Size,
+		acl:           info.ACL,
+		tags:          info.Tags,
+		storageClass:  info.StorageClass,
+		restoreReady:  info.RestoreReady,
+		restoreExpiry: info.RestoreExpiry,
+		versionId:     info.VersionId,
+		deleteMarker:  info.DeleteMarker,
+		multipartETag: info.ETag,
+		seq:           info.Seq,
+	}
+}
+
+// load replaces the buckets and objects of srv with the given ones,
+// already held by its storage.
+func (srv *Server) load(buckets []*BucketInfo, objects []*ObjectInfo) error {
+	srv.buckets = make(map[string]*bucket)
+	for _, info := range buckets {
+		srv.buckets[info.Name] = newBucket(info, srv.storage)
+	}
+	for _, info := range objects {
+		b := srv.buckets[info.Bucket]
+		if b == nil {
+			return fmt.Errorf("s3test: object %q stored in missing bucket %q", info.Key, info.Bucket)
+		}
+		b.versions[info.Key] = append(b.versions[info.Key], newObject(info))
+		if info.Seq > b.seq {
+			b.seq = info.Seq
+		}
+	}
+	for _, b := range srv.buckets {
+		for name, versions := range b.versions {
+			sort.SliceStable(versions, func(i, j int) bool {
+				if versions[i].seq != versions[j].seq {
+					return versions[i].seq > versions[j].seq
+				}
+				return versions[i].mtime.After(versions[j].mtime)
+			})
+			b.updateCurrent(name)
+		}
+	}
+	return nil
+}
+
+// Snapshot saves the buckets and objects of the server to the directory
+// dir, which must be empty or not exist. Restore, or a FileStorage on a
+// copy of dir, brings them back.
+func (srv *Server) Snapshot(dir string) error {
+	if entries, err := ioutil.ReadDir(dir); err == nil && len(entries) > 0 {
+		return fmt.Errorf("s3test: snapshot directory %q is not empty", dir)
+	}
+	dst, err := NewFileStorage(dir)
+	if err != nil {
+		return err
+	}
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	var buckets []*BucketInfo
+	var objects []*ObjectInfo
+	for _, b := range srv.buckets {
+		buckets = append(buckets, b.info())
+		for _, versions := range b.versions {
+			for _, v := range versions {
+				objects = append(objects, v.info(b.name))
+			}
+		}
+	}
+	return copyStorage(dst, srv.storage, buckets, objects)
+}
+
+// Restore replaces the buckets and objects of the server with those saved
+// in the directory dir by Snapshot. Multipart uploads in progress are
+// discarded.
+func (srv *Server) Restore(dir string) error {
+	if _, err := os.Stat(dir); err != nil {
+		return err
+	}
+	src, err := NewFileStorage(dir)
+	if err != nil {
+		return err
+	}
+	buckets, objects, err := src.Load()
+	if err != nil {
+		return err
+	}
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	for _, b := range srv.buckets {
+		for id := range b.multipartUploads {
+			if err := srv.storage.DeleteParts(b.name, id); err != nil {
+				return err
+			}
+		}
+		for _, versions := range b.versions {
+			for _, v := range versions {
+				if err := srv.storage.DeleteObject(v.info(b.name)); err != nil {
+					return err
+				}
+			}
+		}
+		if err := srv.storage.DeleteBucket(b.name); err != nil {
+			return err
+		}
+	}
+	srv.buckets = make(map[string]*bucket)
+	if err := copyStorage(srv.storage, src, buckets, objects); err != nil {
+		return err
+	}
+	return srv.load(buckets, objects)
+}
+
+// copyStorage copies the given buckets and objects from src to dst.
+func copyStorage(dst, src Storage, buckets []*BucketInfo, objects []*ObjectInfo) error {
+	for _, info := range buckets {
+		if err := dst.PutBucket(info); err != nil {
+			return err
+		}
+	}
+	for _, info := range objects {
+		if info.DeleteMarker {
+			if err := dst.PutObject(info, nil); err != nil {
+				return err
+			}
+			continue
+		}
+		r, err := src.OpenObject(info)
+		if err != nil {
+			return err
+		}
+		err = dst.PutObject(info, r)
+		r.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// memoryStorage is the default Storage of a Server. It starts empty and
+// only holds the content of objects and parts, in memory.
+type memoryStorage struct {
+	mu    sync.Mutex
+	data  map[memoryKey][]byte
+	parts map[memoryPartKey][]byte
+}
+
+type memoryKey struct {
+	bucket, key, versionId string
+}
+
+type memoryPartKey struct {
+	bucket, uploadId string
+	n                int
+}
+
+func newMemoryStorage() *memoryStorage {
+	return &memoryStorage{
+		data:  make(map[memoryKey][]byte),
+		parts: make(map[memoryPartKey][]byte),
+	}
+}
+
+func (obj *ObjectInfo) memoryKey() memoryKey {
+	versionId := obj.VersionId
+	if versionId == "" {
+		versionId = nullVersion
+	}
+	return memoryKey{obj.Bucket, obj.Key, versionId}
+}
+
+func (s *memoryStorage) Load() ([]*BucketInfo, []*ObjectInfo, error) { return nil, nil, nil }
+func (s *memoryStorage) PutBucket(b *BucketInfo) error               { return nil }
+func (s *memoryStorage) DeleteBucket(name string) error              { return nil }
+func (s *memoryStorage) UpdateObject(obj *ObjectInfo) error          { return nil }
+
+func (s *memoryStorage) PutObject(obj *ObjectInfo, data io.Reader) error {
+	var content []byte
+	if data != nil {
+		var err error
+		if content, err = ioutil.ReadAll(data); err != nil {
+			return err
+		}
+	}
+	s.mu.Lock()
+	s.data[obj.memoryKey()] = content
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *memoryStorage) OpenObject(obj *ObjectInfo) (io.ReadCloser, error) {
+	s.mu.Lock()
+	content, ok := s.data[obj.memoryKey()]
+	s.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("s3test: no content for %s/%s", obj.Bucket, obj.Key)
+	}
+	return ioutil.NopCloser(bytes.NewReader(content)), nil
+}
+
+func (s *memoryStorage) DeleteObject(obj *ObjectInfo) error {
+	s.mu.Lock()
+	delete(s.data, obj.memoryKey())
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *memoryStorage) PutPart(bucket, uploadId string, n int, data io.Reader) error {
+	content, err := ioutil.ReadAll(data)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.parts[memoryPartKey{bucket, uploadId, n}] = content
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *memoryStorage) OpenPart(bucket, uploadId string, n int) (io.ReadCloser, error) {
+	s.mu.Lock()
+	content, ok := s.parts[memoryPartKey{bucket, uploadId, n}]
+	s.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("s3test: no content for part %d of upload %s", n, uploadId)
+	}
+	return ioutil.NopCloser(bytes.NewReader(content)), nil
+}
+
+func (s *memoryStorage) DeleteParts(bucket, uploadId string) error {
+	s.mu.Lock()
+	for k := range s.parts {
+		if k.bucket == bucket && k.uploadId == uploadId {
+			delete(s.parts, k)
+		}
+	}
+	s.mu.Unlock()
+	return nil
+}
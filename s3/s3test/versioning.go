@@ -0,0 +1,293 @@
+package s3test
+
+import (
+	"encoding/xml"
+	"io"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AdRoll/goamz/s3"
+)
+
+// nullVersion is the version ID of the objects written while versioning
+// was suspended, or before it was ever enabled.
+const nullVersion = "null"
+
+// newVersionId returns the ID of the next version written to b.
+func (b *bucket) newVersionId() string {
+	switch b.versioning {
+	case s3.VersioningEnabled:
+		return strconv.FormatInt(rand.Int63(), 36)
+	case s3.VersioningSuspended:
+		return nullVersion
+	}
+	return ""
+}
+
+// putObject makes obj, with content read from data, the current version
+// of its key. Without versioning, or while it is suspended, it replaces
+// the null version of the key.
+func (b *bucket) putObject(obj *object, data *contentReader) {
+	obj.versionId = b.newVersionId()
+	b.addVersion(obj, data)
+}
+
+// addVersion stores obj, whose version ID is set, with content read from
+// data, which sets its size and checksum, and makes it the latest version
+// of its key. data is nil for delete markers.
+func (b *bucket) addVersion(obj *object, data *contentReader) {
+	b.seq++
+	obj.seq = b.seq
+	info := obj.info(b.name)
+	var r io.Reader
+	if data != nil {
+		data.info = info
+		r = data
+	}
+	if err := b.storage.PutObject(info, r); err != nil {
+		if data != nil && data.failure != nil {
+			panic(data.failure)
+		}
+		storageError(err)
+	}
+	obj.size, obj.checksum = info.Size, info.MD5
+	versions := b.versions[obj.name]
+	if obj.versionId != "" && obj.versionId != nullVersion {
+		versions = append([]*object{obj}, versions...)
+	} else {
+		// There is at most one null version.
+		kept := []*object{obj}
+		for _, v := range versions {
+			if v.versionId != "" && v.versionId != nullVersion {
+				kept = append(kept, v)
+			}
+		}
+		versions = kept
+	}
+	b.versions[obj.name] = versions
+	b.updateCurrent(obj.name)
+}
+
+// deleteObject deletes the current version of the key name. In a bucket
+// that ever had versioning enabled, the versions are kept and a delete
+// marker is added instead, which is returned.
+func (b *bucket) deleteObject(name string) *object {
+	if b.versioning == "" {
+		for _, v := range b.versions[name] {
+			if err := b.storage.DeleteObject(v.info(b.name)); err != nil {
+				storageError(err)
+			}
+		}
+		delete(b.versions, name)
+		delete(b.objects, name)
+		return nil
+	}
+	marker := &object{
+		name:         name,
+		mtime:        time.Now(),
+		deleteMarker: true,
+		versionId:    b.newVersionId(),
+	}
+	b.addVersion(marker, nil)
+	return marker
+}
+
+// deleteVersion permanently removes the version versionId of the key name,
+// returning it, or nil if it does not exist.
+func (b *bucket) deleteVersion(name, versionId string) *object {
+	versions := b.versions[name]
+	for i, v := range versions {
+		if v.versionIdOrNull() == versionId {
+			if err := b.storage.DeleteObject(v.info(b.name)); err != nil {
+				storageError(err)
+			}
+			versions = append(versions[:i:i], versions[i+1:]...)
+			if len(versions) == 0 {
+				delete(b.versions, name)
+			} else {
+				b.versions[name] = versions
+			}
+			b.updateCurrent(name)
+			return v
+		}
+	}
+	return nil
+}
+
+// version returns the version versionId of the key name, or nil.
+func (b *bucket) version(name, versionId string) *object {
+	for _, v := range b.versions[name] {
+		if v.versionIdOrNull() == versionId {
+			return v
+		}
+	}
+	return nil
+}
+
+// latest returns the latest version of the key name, which may be a
+// delete marker, or nil if there is none.
+func (b *bucket) latest(name string) *object {
+	if versions := b.versions[name]; len(versions) > 0 {
+		return versions[0]
+	}
+	return nil
+}
+
+// updateCurrent sets the object listed for the key name from its latest
+// version.
+func (b *bucket) updateCurrent(name string) {
+	if v := b.latest(name); v != nil && !v.deleteMarker {
+		b.objects[name] = v
+	} else {
+		delete(b.objects, name)
+	}
+}
+
+// versionIdOrNull returns the version ID of obj as listed by S3.
+func (obj *object) versionIdOrNull() string {
+	if obj.versionId == "" {
+		return nullVersion
+	}
+	return obj.versionId
+}
+
+// versioningResource is the ?versioning subresource of a bucket.
+type versioningResource struct {
+	bucketResource
+}
+
+func (r versioningResource) get(a *action) interface{} {
+	return &s3.VersioningConfiguration{Status: r.bucket.versioning}
+}
+
+func (r versioningResource) put(a *action) interface{} {
+	var conf s3.VersioningConfiguration
+	if err := xml.NewDecoder(a.req.Body).Decode(&conf); err != nil {
+		fatalf(400, "MalformedXML", "The XML you provided was not well-formed or did not validate against our published schema")
+	}
+	switch conf.Status {
+	case s3.VersioningEnabled, s3.VersioningSuspended:
+	default:
+		fatalf(400, "IllegalVersioningConfigurationException", "The Versioning element must be specified")
+	}
+	r.bucket.versioning = conf.Status
+	r.bucket.save()
+	return nil
+}
+
+func (r versioningResource) post(a *action) interface{}   { return notAllowed() }
+func (r versioningResource) delete(a *action) interface{} { return notAllowed() }
+
+// versionEntry is a Version or DeleteMarker element of a version listing.
+type versionEntry struct {
+	XMLName xml.Name
+	s3.Version
+}
+
+type listVersionsResult struct {
+	XMLName             struct{} `xml:"ListVersionsResult"`
+	Name                string
+	Prefix              string
+	KeyMarker           string
+	VersionIdMarker     string
+	MaxKeys             int
+	Delimiter           string
+	IsTruncated         bool
+	Entries             []versionEntry
+	CommonPrefixes      []string `xml:">Prefix"`
+	NextKeyMarker       string   `xml:",omitempty"`
+	NextVersionIdMarker string   `xml:",omitempty"`
+}
+
+// listVersions answers GET on the ?versions subresource of a bucket.
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_ListObjectVersions.html
+func (r bucketResource) listVersions(a *action, prefix, delimiter string, maxKeys int) interface{} {
+	resp := &listVersionsResult{
+		Name:            r.bucket.name,
+		Prefix:          prefix,
+		KeyMarker:       a.req.Form.Get("key-marker"),
+		VersionIdMarker: a.req.Form.Get("version-id-marker"),
+		MaxKeys:         maxKeys,
+		Delimiter:       delimiter,
+	}
+	var names []string
+	for name := range r.bucket.versions {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	count := 0
+	full := func() bool {
+		if count < maxKeys {
+			count++
+			return false
+		}
+		resp.IsTruncated = true
+		return true
+	}
+	for _, name := range names {
+		if name < resp.KeyMarker || name == resp.KeyMarker && resp.VersionIdMarker == "" {
+			continue
+		}
+		if delimiter != "" {
+			if i := strings.Index(name[len(prefix):], delimiter); i >= 0 {
+				p := name[:len(prefix)+i+len(delimiter)]
+				if p <= resp.KeyMarker || len(resp.CommonPrefixes) > 0 && resp.CommonPrefixes[len(resp.CommonPrefixes)-1] == p {
+					continue
+				}
+				if full() {
+					break
+				}
+				resp.CommonPrefixes = append(resp.CommonPrefixes, p)
+				resp.NextKeyMarker, resp.NextVersionIdMarker = p, ""
+				continue
+			}
+		}
+		skipping := name == resp.KeyMarker
+		for i, v := range r.bucket.versions[name] {
+			vid := v.versionIdOrNull()
+			if skipping {
+				skipping = vid != resp.VersionIdMarker
+				continue
+			}
+			if full() {
+				break
+			}
+			resp.Entries = append(resp.Entries, v.versionEntry(i == 0))
+			resp.NextKeyMarker, resp.NextVersionIdMarker = name, vid
+		}
+		if resp.IsTruncated {
+			break
+		}
+	}
+	if !resp.IsTruncated {
+		resp.NextKeyMarker, resp.NextVersionIdMarker = "", ""
+	}
+	return resp
+}
+
+func (obj *object) versionEntry(latest bool) versionEntry {
+	e := versionEntry{
+		XMLName: xml.Name{Local: "Version"},
+		Version: s3.Version{
+			Key:          obj.name,
+			VersionId:    obj.versionIdOrNull(),
+			IsLatest:     latest,
+			LastModified: obj.mtime.Format(timeFormat),
+			Owner:        owner,
+		},
+	}
+	if obj.deleteMarker {
+		e.XMLName.Local = "DeleteMarker"
+	} else {
+		e.ETag = obj.etag()
+		e.Size = obj.size
+		e.StorageClass = obj.listStorageClass()
+	}
+	return e
+}
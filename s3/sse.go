@@ -0,0 +1,62 @@
+package s3
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+)
+
+// Server-side encryption algorithms.
+const (
+	SSEAlgorithmAES256 = "AES256"
+	SSEAlgorithmKMS    = "aws:kms"
+)
+
+// ServerSideEncryption holds the encryption headers S3 sends back for an
+// object.
+type ServerSideEncryption struct {
+	// Algorithm is SSEAlgorithmAES256 or SSEAlgorithmKMS when S3 manages
+	// the key, and empty otherwise.
+	Algorithm string
+
+	// KMSKeyId is the ID of the KMS key used when Algorithm is
+	// SSEAlgorithmKMS.
+	KMSKeyId string
+
+	// CustomerAlgorithm and CustomerKeyMD5 describe the key given with
+	// SSE-C.
+	CustomerAlgorithm string
+	CustomerKeyMD5    string
+}
+
+// ResponseEncryption extracts the encryption headers from the response
+// to a request on an object.
+func ResponseEncryption(resp *http.Response) ServerSideEncryption {
+	return ServerSideEncryption{
+		Algorithm:         resp.Header.Get("x-amz-server-side-encryption"),
+		KMSKeyId:          resp.Header.Get("x-amz-server-side-encryption-aws-kms-key-id"),
+		CustomerAlgorithm: resp.Header.Get("x-amz-server-side-encryption-customer-algorithm"),
+		CustomerKeyMD5:    resp.Header.Get("x-amz-server-side-encryption-customer-key-MD5"),
+	}
+}
+
+// addKMSHeaders adds the headers requesting encryption with the KMS key
+// keyId, or with the default key if it is empty.
+func addKMSHeaders(headers map[string][]string, keyId string, context map[string]string) {
+	headers["x-amz-server-side-encryption"] = []string{SSEAlgorithmKMS}
+	if keyId != "" {
+		headers["x-amz-server-side-encryption-aws-kms-key-id"] = []string{keyId}
+	}
+	if len(context) != 0 {
+		// Marshalling a map of strings can't fail.
+		data, _ := json.Marshal(context)
+		headers["x-amz-server-side-encryption-context"] = []string{base64.StdEncoding.EncodeToString(data)}
+	}
+}
+
+// usesKMS reports whether headers request KMS encryption, which S3 only
+// accepts with signature version 4.
+func usesKMS(headers map[string][]string) bool {
+	v := headers["x-amz-server-side-encryption"]
+	return len(v) != 0 && v[0] == SSEAlgorithmKMS
+}
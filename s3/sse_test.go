@@ -0,0 +1,149 @@
+package s3_test
+
+import (
+	"strings"
+
+	"github.com/AdRoll/goamz/s3"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestPutKMS(c *check.C) {
+	testServer.Response(200, nil, "")
+
+	b := s.s3.Bucket("bucket")
+	options := s3.Options{
+		SSEKMS:        true,
+		SSEKMSKeyId:   "arn:aws:kms:us-east-1:123456789012:key/abcd",
+		SSEKMSContext: map[string]string{"team": "data"},
+	}
+	err := b.Put("name", []byte("content"), "text/plain", s3.Private, options)
+	c.Assert(err, check.IsNil)
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Header["X-Amz-Server-Side-Encryption"], check.DeepEquals, []string{"aws:kms"})
+	c.Assert(req.Header["X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"], check.DeepEquals, []string{"arn:aws:kms:us-east-1:123456789012:key/abcd"})
+	// base64 of {"team":"data"}
+	c.Assert(req.Header["X-Amz-Server-Side-Encryption-Context"], check.DeepEquals, []string{"eyJ0ZWFtIjoiZGF0YSJ9"})
+	// KMS requires signature version 4 even though s.s3 uses version 2.
+	c.Assert(strings.HasPrefix(req.Header.Get("Authorization"), "AWS4-HMAC-SHA256 "), check.Equals, true)
+	c.Assert(readAll(req.Body), check.Equals, "content")
+}
+
+func (s *S) TestPutSSEKeepsV2Signature(c *check.C) {
+	testServer.Response(200, nil, "")
+
+	b := s.s3.Bucket("bucket")
+	err := b.Put("name", []byte("content"), "text/plain", s3.Private, s3.Options{SSE: true})
+	c.Assert(err, check.IsNil)
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Header["X-Amz-Server-Side-Encryption"], check.DeepEquals, []string{"AES256"})
+	c.Assert(strings.HasPrefix(req.Header.Get("Authorization"), "AWS "), check.Equals, true)
+}
+
+func (s *S) TestPutCopyKMS(c *check.C) {
+	headers := map[string]string{
+		"x-amz-server-side-encryption":                "aws:kms",
+		"x-amz-server-side-encryption-aws-kms-key-id": "new-key",
+	}
+	testServer.Response(200, headers, PutCopyResultDump)
+
+	b := s.s3.Bucket("bucket")
+	options := s3.CopyOptions{
+		CopySourceSSECustomerAlgorithm: "AES256",
+		CopySourceSSECustomerKey:       "MWJhakVna1dQT1B0SDFMeGtVVnRQRTFGaU1ldFJrU0I=",
+		CopySourceSSECustomerKeyMD5:    "glIqxpqQ4a9aoK/iLttKzQ==",
+	}
+	options.SSEKMSKeyId = "new-key"
+	res, err := b.PutCopy("name", s3.Private, options, "source-bucket/source-path")
+	c.Assert(err, check.IsNil)
+	c.Assert(res.Encryption, check.Equals, s3.ServerSideEncryption{Algorithm: "aws:kms", KMSKeyId: "new-key"})
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Header["X-Amz-Server-Side-Encryption"], check.DeepEquals, []string{"aws:kms"})
+	c.Assert(req.Header["X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"], check.DeepEquals, []string{"new-key"})
+	c.Assert(req.Header["X-Amz-Copy-Source-Server-Side-Encryption-Customer-Algorithm"], check.DeepEquals, []string{"AES256"})
+	c.Assert(req.Header["X-Amz-Copy-Source-Server-Side-Encryption-Customer-Key-Md5"], check.DeepEquals, []string{"glIqxpqQ4a9aoK/iLttKzQ=="})
+	c.Assert(strings.HasPrefix(req.Header.Get("Authorization"), "AWS4-HMAC-SHA256 "), check.Equals, true)
+}
+
+func (s *S) TestMultiKMSSignsPartsV4(c *check.C) {
+	testServer.Response(200, nil, InitMultiResultDump)
+	testServer.Response(200, map[string]string{"ETag": `"26f90efd10d614f100252ff56d88dad8"`}, "")
+	testServer.Response(200, nil, "")
+
+	b := s.s3.Bucket("sample")
+	multi, err := b.InitMulti("multi", "text/plain", s3.Private, s3.Options{SSEKMS: true})
+	c.Assert(err, check.IsNil)
+	part, err := multi.PutPart(1, strings.NewReader("<part 1>"))
+	c.Assert(err, check.IsNil)
+	err = multi.Complete([]s3.Part{part})
+	c.Assert(err, check.IsNil)
+
+	for i := 0; i < 3; i++ {
+		req := testServer.WaitRequest()
+		c.Assert(strings.HasPrefix(req.Header.Get("Authorization"), "AWS4-HMAC-SHA256 "), check.Equals, true)
+	}
+}
+
+func (s *S) TestMultiOfKMSEncryptedBucketSignsPartsV4(c *check.C) {
+	testServer.Response(200, map[string]string{"ETag": `"26f90efd10d614f100252ff56d88dad8"`}, "")
+	testServer.Response(200, nil, "")
+
+	b := s.s3.Bucket("sample")
+	b.KMSEncrypted = true
+	multi := &s3.Multi{Bucket: b, Key: "multi", UploadId: "id"}
+	part, err := multi.PutPart(1, strings.NewReader("<part 1>"))
+	c.Assert(err, check.IsNil)
+	err = multi.Complete([]s3.Part{part})
+	c.Assert(err, check.IsNil)
+
+	for i := 0; i < 2; i++ {
+		req := testServer.WaitRequest()
+		c.Assert(strings.HasPrefix(req.Header.Get("Authorization"), "AWS4-HMAC-SHA256 "), check.Equals, true)
+	}
+}
+
+func (s *S) TestPutReaderWithResult(c *check.C) {
+	headers := map[string]string{
+		"ETag":                         `"9a0364b9e99bb480dd25e1f0284c8555"`,
+		"x-amz-version-id":             "v1",
+		"x-amz-server-side-encryption": "AES256",
+	}
+	testServer.Response(200, headers, "")
+
+	b := s.s3.Bucket("bucket")
+	res, err := b.PutWithResult("name", []byte("content"), "text/plain", s3.Private, s3.Options{SSE: true})
+	c.Assert(err, check.IsNil)
+	c.Assert(res, check.DeepEquals, &s3.PutObjectResult{
+		ETag:       `"9a0364b9e99bb480dd25e1f0284c8555"`,
+		VersionId:  "v1",
+		Encryption: s3.ServerSideEncryption{Algorithm: "AES256"},
+	})
+	testServer.WaitRequest()
+}
+
+func (s *S) TestKMSEncryptedBucketSignsReadsV4(c *check.C) {
+	testServer.Response(200, nil, "content")
+	testServer.Response(200, nil, "")
+	testServer.Response(200, nil, "content")
+
+	b := s.s3.Bucket("bucket")
+	b.KMSEncrypted = true
+	data, err := b.Get("name")
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, "content")
+	_, err = b.Head("name", nil)
+	c.Assert(err, check.IsNil)
+
+	for i := 0; i < 2; i++ {
+		req := testServer.WaitRequest()
+		c.Assert(strings.HasPrefix(req.Header.Get("Authorization"), "AWS4-HMAC-SHA256 "), check.Equals, true)
+	}
+
+	// Other buckets keep signature version 2.
+	_, err = s.s3.Bucket("other").Get("name")
+	c.Assert(err, check.IsNil)
+	req := testServer.WaitRequest()
+	c.Assert(strings.HasPrefix(req.Header.Get("Authorization"), "AWS "), check.Equals, true)
+}
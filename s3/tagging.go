@@ -0,0 +1,60 @@
+package s3
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Values of CopyOptions.TaggingDirective.
+const (
+	TaggingDirectiveCopy    = "COPY"
+	TaggingDirectiveReplace = "REPLACE"
+)
+
+// encodeTags returns tags in the URL query form of the x-amz-tagging
+// header.
+func encodeTags(tags []Tag) string {
+	pairs := make([]string, len(tags))
+	for i, t := range tags {
+		pairs[i] = url.QueryEscape(t.Key) + "=" + url.QueryEscape(t.Value)
+	}
+	return strings.Join(pairs, "&")
+}
+
+// GetObjectTagging returns the tags of the object at path.
+//
+// See https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetObjectTagging.html
+// for details.
+func (b *Bucket) GetObjectTagging(path string) (*Tagging, error) {
+	tagging := &Tagging{}
+	if err := b.getSubresourceXML(path, "tagging", tagging); err != nil {
+		return nil, err
+	}
+	return tagging, nil
+}
+
+// PutObjectTagging replaces the tags of the object at path.
+//
+// See https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutObjectTagging.html
+// for details.
+func (b *Bucket) PutObjectTagging(path string, tagging Tagging) error {
+	return b.putSubresourceXML(path, "tagging", tagging)
+}
+
+// DeleteObjectTagging removes all the tags of the object at path.
+func (b *Bucket) DeleteObjectTagging(path string) error {
+	req := &request{
+		method: "DELETE",
+		bucket: b.Name,
+		path:   path,
+		params: url.Values{"tagging": {""}},
+	}
+	var err error
+	for attempt := attempts.Start(); attempt.Next(); {
+		err = b.S3.query(req, nil)
+		if !shouldRetry(err) {
+			break
+		}
+	}
+	return err
+}
@@ -0,0 +1,89 @@
+package s3_test
+
+import (
+	"github.com/AdRoll/goamz/s3"
+	"gopkg.in/check.v1"
+)
+
+var GetObjectTaggingDump = `<?xml version="1.0" encoding="UTF-8"?>
+<Tagging xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <TagSet>
+    <Tag>
+      <Key>retention</Key>
+      <Value>30d</Value>
+    </Tag>
+    <Tag>
+      <Key>team</Key>
+      <Value>data eng</Value>
+    </Tag>
+  </TagSet>
+</Tagging>`
+
+func (s *S) TestPutWithTags(c *check.C) {
+	testServer.Response(200, nil, "")
+
+	b := s.s3.Bucket("bucket")
+	options := s3.Options{Tags: []s3.Tag{{Key: "retention", Value: "30d"}, {Key: "team", Value: "data eng&co"}}}
+	err := b.Put("name", []byte("content"), "text/plain", s3.Private, options)
+	c.Assert(err, check.IsNil)
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Header["X-Amz-Tagging"], check.DeepEquals, []string{"retention=30d&team=data+eng%26co"})
+}
+
+func (s *S) TestPutCopyTaggingDirective(c *check.C) {
+	testServer.Response(200, nil, PutCopyResultDump)
+
+	b := s.s3.Bucket("bucket")
+	options := s3.CopyOptions{TaggingDirective: s3.TaggingDirectiveReplace}
+	options.Tags = []s3.Tag{{Key: "retention", Value: "1y"}}
+	_, err := b.PutCopy("name", s3.Private, options, "source-bucket/source-path")
+	c.Assert(err, check.IsNil)
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Header["X-Amz-Tagging-Directive"], check.DeepEquals, []string{"REPLACE"})
+	c.Assert(req.Header["X-Amz-Tagging"], check.DeepEquals, []string{"retention=1y"})
+}
+
+func (s *S) TestGetObjectTagging(c *check.C) {
+	testServer.Response(200, nil, GetObjectTaggingDump)
+
+	b := s.s3.Bucket("bucket")
+	tagging, err := b.GetObjectTagging("name")
+	c.Assert(err, check.IsNil)
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Method, check.Equals, "GET")
+	c.Assert(req.URL.Path, check.Equals, "/bucket/name")
+	c.Assert(req.URL.RawQuery, check.Equals, "tagging=")
+	c.Assert(tagging.TagSet, check.DeepEquals, []s3.Tag{{Key: "retention", Value: "30d"}, {Key: "team", Value: "data eng"}})
+}
+
+func (s *S) TestPutObjectTagging(c *check.C) {
+	testServer.Response(200, nil, "")
+
+	b := s.s3.Bucket("bucket")
+	err := b.PutObjectTagging("name", s3.Tagging{TagSet: []s3.Tag{{Key: "retention", Value: "30d"}}})
+	c.Assert(err, check.IsNil)
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Method, check.Equals, "PUT")
+	c.Assert(req.URL.Path, check.Equals, "/bucket/name")
+	c.Assert(req.URL.RawQuery, check.Equals, "tagging=")
+	c.Assert(req.Header["Content-Md5"], check.HasLen, 1)
+	c.Assert(readAll(req.Body), check.Equals, `<?xml version="1.0" encoding="UTF-8"?>
+<Tagging><TagSet><Tag><Key>retention</Key><Value>30d</Value></Tag></TagSet></Tagging>`)
+}
+
+func (s *S) TestDeleteObjectTagging(c *check.C) {
+	testServer.Response(204, nil, "")
+
+	b := s.s3.Bucket("bucket")
+	err := b.DeleteObjectTagging("name")
+	c.Assert(err, check.IsNil)
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Method, check.Equals, "DELETE")
+	c.Assert(req.URL.Path, check.Equals, "/bucket/name")
+	c.Assert(req.URL.RawQuery, check.Equals, "tagging=")
+}
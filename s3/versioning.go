@@ -0,0 +1,156 @@
+package s3
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// Bucket versioning states.
+const (
+	VersioningEnabled   = "Enabled"
+	VersioningSuspended = "Suspended"
+)
+
+// MFA delete states.
+const (
+	MfaDeleteEnabled  = "Enabled"
+	MfaDeleteDisabled = "Disabled"
+)
+
+// VersioningConfiguration holds the versioning state of a bucket. Status
+// is empty for a bucket that never had versioning enabled.
+type VersioningConfiguration struct {
+	XMLName   xml.Name `xml:"VersioningConfiguration"`
+	Status    string   `xml:"Status,omitempty"`
+	MfaDelete string   `xml:"MfaDelete,omitempty"`
+}
+
+// GetBucketVersioning returns the versioning state of the bucket.
+//
+// See https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketVersioning.html
+// for details.
+func (b *Bucket) GetBucketVersioning() (*VersioningConfiguration, error) {
+	req := &request{
+		bucket: b.Name,
+		path:   "/",
+		params: url.Values{"versioning": {""}},
+	}
+	conf := &VersioningConfiguration{}
+	var err error
+	for attempt := attempts.Start(); attempt.Next(); {
+		err = b.S3.query(req, conf)
+		if !shouldRetry(err) {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+// PutBucketVersioning enables or suspends versioning on the bucket.
+// Changing config.MfaDelete requires mfa, the serial number of the
+// authentication device and its current code separated by a space;
+// pass "" otherwise.
+//
+// See https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketVersioning.html
+// for details.
+func (b *Bucket) PutBucketVersioning(config VersioningConfiguration, mfa string) error {
+	doc, err := xml.Marshal(config)
+	if err != nil {
+		return err
+	}
+	buf := makeXmlBuffer(doc)
+	headers := map[string][]string{
+		"Content-Length": {strconv.Itoa(buf.Len())},
+	}
+	if mfa != "" {
+		headers["x-amz-mfa"] = []string{mfa}
+	}
+	req := &request{
+		method:  "PUT",
+		bucket:  b.Name,
+		path:    "/",
+		headers: headers,
+		params:  url.Values{"versioning": {""}},
+		payload: buf,
+	}
+	return b.S3.query(req, nil)
+}
+
+// VersionInfo holds the version headers S3 sends back for an object in a
+// versioned bucket.
+type VersionInfo struct {
+	// VersionId is the version the request read, wrote or deleted.
+	VersionId string
+
+	// DeleteMarker is true if that version is a delete marker.
+	DeleteMarker bool
+}
+
+// ResponseVersion extracts the version headers from the response to a
+// request on an object.
+func ResponseVersion(resp *http.Response) VersionInfo {
+	return VersionInfo{
+		VersionId:    resp.Header.Get("x-amz-version-id"),
+		DeleteMarker: resp.Header.Get("x-amz-delete-marker") == "true",
+	}
+}
+
+func versionParams(versionId string) url.Values {
+	if versionId == "" {
+		return nil
+	}
+	return url.Values{"versionId": {versionId}}
+}
+
+// GetVersion retrieves a version of an object from an S3 bucket. An empty
+// versionId retrieves the current version.
+func (b *Bucket) GetVersion(path, versionId string) (data []byte, err error) {
+	resp, err := b.GetVersionResponse(path, versionId, nil)
+	if err != nil {
+		return nil, err
+	}
+	data, err = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	return data, err
+}
+
+// GetVersionResponse retrieves a version of an object from an S3 bucket,
+// returning the HTTP response. Its version is given by ResponseVersion.
+// It is the caller's responsibility to call Close on the response body
+// when finished reading.
+func (b *Bucket) GetVersionResponse(path, versionId string, headers map[string][]string) (*http.Response, error) {
+	return b.getResponse(path, versionParams(versionId), headers)
+}
+
+// HeadVersion performs a HEAD request on a version of an object.
+func (b *Bucket) HeadVersion(path, versionId string, headers map[string][]string) (*http.Response, error) {
+	return b.head(path, versionParams(versionId), headers)
+}
+
+// DelVersion permanently removes a version of an object from the S3
+// bucket. An empty versionId deletes the object as Del does, which in
+// a versioned bucket adds a delete marker whose version is returned.
+func (b *Bucket) DelVersion(path, versionId string) (VersionInfo, error) {
+	req := &request{
+		method: "DELETE",
+		bucket: b.Name,
+		path:   path,
+		params: versionParams(versionId),
+	}
+	err := b.S3.prepare(req)
+	if err != nil {
+		return VersionInfo{}, err
+	}
+	resp, err := b.S3.run(req, nil)
+	if err != nil {
+		return VersionInfo{}, err
+	}
+	resp.Body.Close()
+	return ResponseVersion(resp), nil
+}
@@ -0,0 +1,130 @@
+package s3_test
+
+import (
+	"encoding/xml"
+	"github.com/AdRoll/goamz/s3"
+	"gopkg.in/check.v1"
+	"io/ioutil"
+)
+
+func (s *S) TestGetBucketVersioning(c *check.C) {
+	testServer.Response(200, nil, `<VersioningConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><Status>Suspended</Status><MfaDelete>Disabled</MfaDelete></VersioningConfiguration>`)
+
+	b := s.s3.Bucket("bucket")
+	conf, err := b.GetBucketVersioning()
+	c.Assert(err, check.IsNil)
+	c.Assert(conf.Status, check.Equals, s3.VersioningSuspended)
+	c.Assert(conf.MfaDelete, check.Equals, s3.MfaDeleteDisabled)
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Method, check.Equals, "GET")
+	c.Assert(req.URL.Path, check.Equals, "/bucket/")
+	c.Assert(req.URL.RawQuery, check.Equals, "versioning=")
+}
+
+func (s *S) TestPutBucketVersioning(c *check.C) {
+	testServer.Response(200, nil, "")
+
+	b := s.s3.Bucket("bucket")
+	conf := s3.VersioningConfiguration{Status: s3.VersioningEnabled, MfaDelete: s3.MfaDeleteEnabled}
+	err := b.PutBucketVersioning(conf, "20899872 301749")
+	c.Assert(err, check.IsNil)
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Method, check.Equals, "PUT")
+	c.Assert(req.URL.RawQuery, check.Equals, "versioning=")
+	c.Assert(req.Header["X-Amz-Mfa"], check.DeepEquals, []string{"20899872 301749"})
+
+	var sent s3.VersioningConfiguration
+	err = xml.NewDecoder(req.Body).Decode(&sent)
+	c.Assert(err, check.IsNil)
+	c.Assert(sent.Status, check.Equals, "Enabled")
+	c.Assert(sent.MfaDelete, check.Equals, "Enabled")
+}
+
+func (s *S) TestGetVersion(c *check.C) {
+	testServer.Response(200, map[string]string{"x-amz-version-id": "v1"}, "content")
+
+	b := s.s3.Bucket("bucket")
+	resp, err := b.GetVersionResponse("name", "v1", nil)
+	c.Assert(err, check.IsNil)
+	data, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, "content")
+	c.Assert(s3.ResponseVersion(resp), check.Equals, s3.VersionInfo{VersionId: "v1"})
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Method, check.Equals, "GET")
+	c.Assert(req.URL.Path, check.Equals, "/bucket/name")
+	c.Assert(req.Form["versionId"], check.DeepEquals, []string{"v1"})
+}
+
+func (s *S) TestHeadVersion(c *check.C) {
+	testServer.Response(200, map[string]string{"x-amz-version-id": "v2", "x-amz-delete-marker": "true"}, "")
+
+	b := s.s3.Bucket("bucket")
+	resp, err := b.HeadVersion("name", "v2", nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(s3.ResponseVersion(resp), check.Equals, s3.VersionInfo{VersionId: "v2", DeleteMarker: true})
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Method, check.Equals, "HEAD")
+	c.Assert(req.Form["versionId"], check.DeepEquals, []string{"v2"})
+}
+
+func (s *S) TestDelVersion(c *check.C) {
+	testServer.Response(204, map[string]string{"x-amz-version-id": "marker", "x-amz-delete-marker": "true"}, "")
+	testServer.Response(204, map[string]string{"x-amz-version-id": "v1"}, "")
+
+	b := s.s3.Bucket("bucket")
+	info, err := b.DelVersion("name", "")
+	c.Assert(err, check.IsNil)
+	c.Assert(info, check.Equals, s3.VersionInfo{VersionId: "marker", DeleteMarker: true})
+	req := testServer.WaitRequest()
+	c.Assert(req.Method, check.Equals, "DELETE")
+	c.Assert(req.URL.RawQuery, check.Equals, "")
+
+	info, err = b.DelVersion("name", "v1")
+	c.Assert(err, check.IsNil)
+	c.Assert(info, check.Equals, s3.VersionInfo{VersionId: "v1"})
+	req = testServer.WaitRequest()
+	c.Assert(req.Form["versionId"], check.DeepEquals, []string{"v1"})
+}
+
+func (s *S) TestPutCopyVersion(c *check.C) {
+	headers := map[string]string{"x-amz-version-id": "new", "x-amz-copy-source-version-id": "old"}
+	testServer.Response(200, headers, PutCopyResultDump)
+
+	b := s.s3.Bucket("bucket")
+	options := s3.CopyOptions{SourceVersionId: "old"}
+	res, err := b.PutCopy("name", s3.Private, options, "source-bucket/source-path")
+	c.Assert(err, check.IsNil)
+	c.Assert(res.VersionId, check.Equals, "new")
+	c.Assert(res.SourceVersionId, check.Equals, "old")
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Header["X-Amz-Copy-Source"], check.DeepEquals, []string{"source-bucket%2Fsource-path?versionId=old"})
+}
+
+func (s *S) TestDelMultiWithResult(c *check.C) {
+	testServer.Response(200, nil, `<DeleteResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <Deleted><Key>a</Key><DeleteMarker>true</DeleteMarker><DeleteMarkerVersionId>m1</DeleteMarkerVersionId></Deleted>
+  <Deleted><Key>b</Key><VersionId>v1</VersionId></Deleted>
+  <Error><Key>c</Key><Code>AccessDenied</Code><Message>Access Denied</Message></Error>
+</DeleteResult>`)
+
+	b := s.s3.Bucket("bucket")
+	res, err := b.DelMultiWithResult(s3.Delete{Objects: []s3.Object{{Key: "a"}, {Key: "b", VersionId: "v1"}, {Key: "c"}}})
+	c.Assert(err, check.IsNil)
+	c.Assert(res.Deleted, check.DeepEquals, []s3.DeletedObject{
+		{Key: "a", DeleteMarker: true, DeleteMarkerVersionId: "m1"},
+		{Key: "b", VersionId: "v1"},
+	})
+	c.Assert(res.Errors, check.DeepEquals, []s3.DeleteError{{Key: "c", Code: "AccessDenied", Message: "Access Denied"}})
+
+	req := testServer.WaitRequest()
+	body, err := ioutil.ReadAll(req.Body)
+	c.Assert(err, check.IsNil)
+	c.Assert(string(body), check.Matches, `(?s).*<Object><Key>b</Key><VersionId>v1</VersionId></Object>.*`)
+}
@@ -0,0 +1,281 @@
+package testutil
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Protocol identifies the error response format of an AWS service.
+type Protocol int
+
+const (
+	// S3Protocol is the REST/XML format used by S3.
+	S3Protocol Protocol = iota
+
+	// EC2Protocol is the <Response><Errors> format used by EC2.
+	EC2Protocol
+
+	// QueryProtocol is the <ErrorResponse> format used by IAM, SNS, SQS,
+	// STS, ELB, RDS and the other query API services.
+	QueryProtocol
+
+	// JSONProtocol is the JSON format used by DynamoDB, Kinesis and KMS.
+	JSONProtocol
+)
+
+// Fault describes how a request is made to fail.
+//
+// Latency is applied first. Then, in order of precedence, Reset closes the
+// connection without answering, Status sends an error response with Header
+// and Body, and Truncate sends the real response with only half of its body.
+// A Fault with only Latency set delays the real response.
+type Fault struct {
+	Latency  time.Duration
+	Reset    bool
+	Truncate bool
+	Status   int
+	Header   map[string]string
+	Body     string
+}
+
+// FaultRule attaches a Fault to the requests it should affect.
+type FaultRule struct {
+	Fault
+
+	// Match, if set, restricts the rule to requests for which it
+	// returns true.
+	Match func(req *http.Request) bool
+
+	// Nth, if non-zero, fires the rule on the Nth matching request only,
+	// counting from 1.
+	Nth int
+
+	// Probability, if non-zero, fires the rule on each matching request
+	// with the given probability, between 0 and 1.
+	Probability float64
+
+	// Times, if non-zero, is the maximum number of times the rule fires.
+	Times int
+
+	seen  int
+	fired int
+}
+
+// Faults holds a set of fault rules that can be attached to a fake server.
+// Rules are evaluated in the order they were added and the first one that
+// fires is applied. The zero value is ready for use and injects nothing;
+// a nil *Faults is also valid.
+type Faults struct {
+	// Rand, if set, is the source of randomness for probabilistic rules.
+	Rand *rand.Rand
+
+	mu    sync.Mutex
+	rules []*FaultRule
+	fired int
+}
+
+// Add appends rule to the set of rules.
+func (f *Faults) Add(rule FaultRule) {
+	f.mu.Lock()
+	f.rules = append(f.rules, &rule)
+	f.mu.Unlock()
+}
+
+// Clear removes all rules and resets the count of fired faults.
+func (f *Faults) Clear() {
+	f.mu.Lock()
+	f.rules = nil
+	f.fired = 0
+	f.mu.Unlock()
+}
+
+// Fired returns the number of requests a fault was injected into.
+func (f *Faults) Fired() int {
+	if f == nil {
+		return 0
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.fired
+}
+
+// next returns the fault to apply to req, or nil if no rule fires.
+func (f *Faults) next(req *http.Request) *Fault {
+	if f == nil {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, r := range f.rules {
+		if r.Match != nil && !r.Match(req) {
+			continue
+		}
+		r.seen++
+		if r.Times > 0 && r.fired >= r.Times {
+			continue
+		}
+		if r.Nth > 0 && r.seen != r.Nth {
+			continue
+		}
+		if r.Probability > 0 && f.float64() >= r.Probability {
+			continue
+		}
+		r.fired++
+		f.fired++
+		fault := r.Fault
+		return &fault
+	}
+	return nil
+}
+
+func (f *Faults) float64() float64 {
+	if f.Rand != nil {
+		return f.Rand.Float64()
+	}
+	return rand.Float64()
+}
+
+// Serve handles req with h unless a rule fires, in which case the
+// corresponding fault is injected instead. Requests answered entirely by a
+// fault never reach h.
+func (f *Faults) Serve(w http.ResponseWriter, req *http.Request, h http.Handler) {
+	fault := f.next(req)
+	if fault == nil {
+		h.ServeHTTP(w, req)
+		return
+	}
+	if fault.Latency > 0 {
+		time.Sleep(fault.Latency)
+	}
+	switch {
+	case fault.Reset:
+		resetConn(w)
+	case fault.Status != 0:
+		for k, v := range fault.Header {
+			w.Header().Set(k, v)
+		}
+		w.WriteHeader(fault.Status)
+		w.Write([]byte(fault.Body))
+	case fault.Truncate:
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		truncate(w, rec.Result())
+	default:
+		h.ServeHTTP(w, req)
+	}
+}
+
+// hijack takes over the connection of w. It returns a nil conn if w does
+// not support it, as with HTTP/2.
+func hijack(w http.ResponseWriter) (net.Conn, *bufio.ReadWriter) {
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, nil
+	}
+	conn, buf, err := hj.Hijack()
+	if err != nil {
+		return nil, nil
+	}
+	return conn, buf
+}
+
+// resetConn aborts the connection so the client sees a reset rather
+// than an orderly close. If the connection cannot be hijacked, the
+// response is aborted with http.ErrAbortHandler instead, which closes
+// the connection or resets the HTTP/2 stream.
+func resetConn(w http.ResponseWriter) {
+	conn, _ := hijack(w)
+	if conn == nil {
+		panic(http.ErrAbortHandler)
+	}
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		tcp.SetLinger(0)
+	}
+	conn.Close()
+}
+
+// truncate writes the recorded response announcing its full length but
+// sends only the first half of the body before closing the connection.
+func truncate(w http.ResponseWriter, resp *http.Response) {
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	conn, buf := hijack(w)
+	if conn == nil {
+		for k, v := range resp.Header {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(resp.StatusCode)
+		w.Write(body[:len(body)/2])
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+		panic(http.ErrAbortHandler)
+	}
+	defer conn.Close()
+	fmt.Fprintf(buf, "HTTP/1.1 %s\r\n", resp.Status)
+	resp.Header.Write(buf)
+	buf.WriteString("\r\n")
+	buf.Write(body[:len(body)/2])
+	buf.Flush()
+}
+
+// ErrorFault returns a Fault answering with an error response in the
+// format of the given protocol.
+func ErrorFault(p Protocol, status int, code, message string) Fault {
+	const reqId = "FAULT-INJECTED"
+	fault := Fault{Status: status, Header: map[string]string{"Content-Type": "text/xml"}}
+	switch p {
+	case S3Protocol:
+		fault.Header["Content-Type"] = "application/xml"
+		fault.Body = fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<Error><Code>%s</Code><Message>%s</Message><RequestId>%s</RequestId></Error>`, code, message, reqId)
+	case EC2Protocol:
+		fault.Body = fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<Response><Errors><Error><Code>%s</Code><Message>%s</Message></Error></Errors><RequestID>%s</RequestID></Response>`, code, message, reqId)
+	case QueryProtocol:
+		kind := "Sender"
+		if status >= 500 {
+			kind = "Receiver"
+		}
+		fault.Body = fmt.Sprintf(`<ErrorResponse><Error><Type>%s</Type><Code>%s</Code><Message>%s</Message></Error><RequestId>%s</RequestId></ErrorResponse>`, kind, code, message, reqId)
+	case JSONProtocol:
+		fault.Header["Content-Type"] = "application/x-amz-json-1.0"
+		fault.Header["X-Amzn-RequestId"] = reqId
+		fault.Body = fmt.Sprintf(`{"__type":"com.amazonaws.fault#%s","message":%q}`, code, message)
+	default:
+		panic(fmt.Sprintf("unknown protocol %d", p))
+	}
+	return fault
+}
+
+// ThrottlingFault returns a Fault answering with the throttling error
+// each protocol's services send when their request rate is exceeded.
+func ThrottlingFault(p Protocol) Fault {
+	switch p {
+	case S3Protocol:
+		return ErrorFault(p, 503, "SlowDown", "Please reduce your request rate.")
+	case EC2Protocol:
+		return ErrorFault(p, 503, "RequestLimitExceeded", "Request limit exceeded.")
+	case QueryProtocol:
+		return ErrorFault(p, 400, "Throttling", "Rate exceeded")
+	}
+	return ErrorFault(p, 400, "ThrottlingException", "Rate exceeded")
+}
+
+// InternalErrorFault returns a Fault answering with a 500 InternalError.
+func InternalErrorFault(p Protocol) Fault {
+	return ErrorFault(p, 500, "InternalError", "We encountered an internal error. Please try again.")
+}
+
+// UnavailableFault returns a Fault answering with a 503 ServiceUnavailable.
+func UnavailableFault(p Protocol) Fault {
+	return ErrorFault(p, 503, "ServiceUnavailable", "Service is unable to handle request.")
+}
@@ -0,0 +1,166 @@
+package testutil_test
+
+import (
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/AdRoll/goamz/testutil"
+	"gopkg.in/check.v1"
+)
+
+type FaultsSuite struct {
+	faults *testutil.Faults
+	srv    *httptest.Server
+}
+
+var _ = check.Suite(&FaultsSuite{})
+
+func (s *FaultsSuite) SetUpTest(c *check.C) {
+	s.faults = &testutil.Faults{Rand: rand.New(rand.NewSource(1))}
+	ok := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("all is well"))
+	})
+	s.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		s.faults.Serve(w, req, ok)
+	}))
+}
+
+func (s *FaultsSuite) TearDownTest(c *check.C) {
+	s.srv.Close()
+}
+
+func (s *FaultsSuite) get(c *check.C) (*http.Response, string, error) {
+	resp, err := http.Get(s.srv.URL)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	return resp, string(data), err
+}
+
+func (s *FaultsSuite) TestNoFaults(c *check.C) {
+	resp, body, err := s.get(c)
+	c.Assert(err, check.IsNil)
+	c.Assert(resp.StatusCode, check.Equals, 200)
+	c.Assert(body, check.Equals, "all is well")
+	c.Assert(s.faults.Fired(), check.Equals, 0)
+}
+
+func (s *FaultsSuite) TestNthRequestThrottled(c *check.C) {
+	s.faults.Add(testutil.FaultRule{Fault: testutil.ThrottlingFault(testutil.S3Protocol), Nth: 2})
+
+	resp, _, err := s.get(c)
+	c.Assert(err, check.IsNil)
+	c.Assert(resp.StatusCode, check.Equals, 200)
+
+	resp, body, err := s.get(c)
+	c.Assert(err, check.IsNil)
+	c.Assert(resp.StatusCode, check.Equals, 503)
+	c.Assert(body, check.Matches, `(?s).*<Code>SlowDown</Code>.*`)
+
+	resp, _, err = s.get(c)
+	c.Assert(err, check.IsNil)
+	c.Assert(resp.StatusCode, check.Equals, 200)
+	c.Assert(s.faults.Fired(), check.Equals, 1)
+}
+
+func (s *FaultsSuite) TestProtocolErrorBodies(c *check.C) {
+	f := testutil.ThrottlingFault(testutil.JSONProtocol)
+	c.Assert(f.Status, check.Equals, 400)
+	c.Assert(f.Body, check.Equals, `{"__type":"com.amazonaws.fault#ThrottlingException","message":"Rate exceeded"}`)
+
+	f = testutil.InternalErrorFault(testutil.QueryProtocol)
+	c.Assert(f.Status, check.Equals, 500)
+	c.Assert(f.Body, check.Matches, `<ErrorResponse><Error><Type>Receiver</Type><Code>InternalError</Code>.*`)
+
+	f = testutil.ThrottlingFault(testutil.EC2Protocol)
+	c.Assert(f.Status, check.Equals, 503)
+	c.Assert(f.Body, check.Matches, `(?s).*<Response><Errors><Error><Code>RequestLimitExceeded</Code>.*`)
+}
+
+func (s *FaultsSuite) TestReset(c *check.C) {
+	s.faults.Add(testutil.FaultRule{Fault: testutil.Fault{Reset: true}, Times: 1})
+
+	_, _, err := s.get(c)
+	c.Assert(err, check.NotNil)
+
+	_, body, err := s.get(c)
+	c.Assert(err, check.IsNil)
+	c.Assert(body, check.Equals, "all is well")
+}
+
+func (s *FaultsSuite) TestTruncate(c *check.C) {
+	s.faults.Add(testutil.FaultRule{Fault: testutil.Fault{Truncate: true}})
+
+	resp, body, err := s.get(c)
+	c.Assert(err, check.Equals, io.ErrUnexpectedEOF)
+	c.Assert(resp.ContentLength, check.Equals, int64(len("all is well")))
+	c.Assert(body, check.Equals, "all i")
+}
+
+func (s *FaultsSuite) TestWithoutHijacker(c *check.C) {
+	// HTTP/2 connections cannot be hijacked, so the response is aborted.
+	ok := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("all is well"))
+	})
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		s.faults.Serve(w, req, ok)
+	}))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+	client := srv.Client()
+
+	s.faults.Add(testutil.FaultRule{Fault: testutil.Fault{Reset: true}, Times: 1})
+	_, err := client.Get(srv.URL)
+	c.Assert(err, check.NotNil)
+
+	s.faults.Add(testutil.FaultRule{Fault: testutil.Fault{Truncate: true}, Times: 1})
+	resp, err := client.Get(srv.URL)
+	if err == nil {
+		_, err = ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		c.Assert(resp.ProtoMajor, check.Equals, 2)
+	}
+	c.Assert(err, check.NotNil)
+	c.Assert(s.faults.Fired(), check.Equals, 2)
+}
+
+func (s *FaultsSuite) TestLatency(c *check.C) {
+	s.faults.Add(testutil.FaultRule{Fault: testutil.Fault{Latency: 50 * time.Millisecond}})
+
+	start := time.Now()
+	_, body, err := s.get(c)
+	c.Assert(err, check.IsNil)
+	c.Assert(body, check.Equals, "all is well")
+	c.Assert(time.Since(start) >= 50*time.Millisecond, check.Equals, true)
+}
+
+func (s *FaultsSuite) TestProbabilityAndMatch(c *check.C) {
+	s.faults.Add(testutil.FaultRule{
+		Fault:       testutil.UnavailableFault(testutil.QueryProtocol),
+		Probability: 0.5,
+		Match:       func(req *http.Request) bool { return req.Method == "GET" },
+	})
+
+	failed := 0
+	for i := 0; i < 100; i++ {
+		resp, _, err := s.get(c)
+		c.Assert(err, check.IsNil)
+		if resp.StatusCode == 503 {
+			failed++
+		}
+	}
+	c.Assert(failed, check.Equals, s.faults.Fired())
+	c.Assert(failed > 25 && failed < 75, check.Equals, true)
+
+	resp, err := http.Post(s.srv.URL, "text/plain", nil)
+	c.Assert(err, check.IsNil)
+	resp.Body.Close()
+	c.Assert(resp.StatusCode, check.Equals, 200)
+}
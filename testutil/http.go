@@ -8,12 +8,16 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"sync"
 	"time"
 )
 
 type HTTPServer struct {
-	URL      string
-	Timeout  time.Duration
+	URL     string
+	Timeout time.Duration
+
+	mu       sync.Mutex
+	faults   *Faults
 	started  bool
 	request  chan *http.Request
 	response chan ResponseFunc
@@ -80,7 +84,24 @@ func body(req *http.Request) string {
 	return string(data)
 }
 
+// SetFaults attaches f to the server so that its fault rules are applied
+// to subsequent requests. A request answered entirely by a fault is
+// neither queued for WaitRequest nor consumes a prepared response. A nil
+// f disables fault injection.
+func (s *HTTPServer) SetFaults(f *Faults) {
+	s.mu.Lock()
+	s.faults = f
+	s.mu.Unlock()
+}
+
 func (s *HTTPServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	faults := s.faults
+	s.mu.Unlock()
+	faults.Serve(w, req, http.HandlerFunc(s.serveHTTP))
+}
+
+func (s *HTTPServer) serveHTTP(w http.ResponseWriter, req *http.Request) {
 	req.ParseMultipartForm(1e6)
 	data, err := ioutil.ReadAll(req.Body)
 	if err != nil {
@@ -0,0 +1,383 @@
+package testutil
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// RecorderMode selects whether a Recorder talks to the real servers or
+// serves previously recorded interactions.
+type RecorderMode int
+
+const (
+	// ModeRecord forwards every request to the real transport and records
+	// the request/response pair in the cassette.
+	ModeRecord RecorderMode = iota
+
+	// ModeReplay answers every request from the cassette and never
+	// touches the network.
+	ModeReplay
+)
+
+// scrubbed replaces any secret found in a recorded interaction.
+const scrubbed = "SCRUBBED"
+
+// Header names and parameters holding credentials or signatures. They are
+// scrubbed before an interaction is written to disk.
+var (
+	secretHeaders = []string{
+		"Authorization",
+		"X-Amz-Security-Token",
+		"X-Amz-Server-Side-Encryption-Customer-Key",
+		"X-Amz-Copy-Source-Server-Side-Encryption-Customer-Key",
+	}
+	secretParams = map[string]bool{
+		"AWSAccessKeyId":       true,
+		"Signature":            true,
+		"SecurityToken":        true,
+		"X-Amz-Credential":     true,
+		"X-Amz-Signature":      true,
+		"X-Amz-Security-Token": true,
+		"x-amz-security-token": true,
+	}
+	secretElements = regexp.MustCompile(`<(AccessKeyId|SecretAccessKey|SessionToken)>[^<]*</(AccessKeyId|SecretAccessKey|SessionToken)>`)
+	secretFields   = regexp.MustCompile(`"(AccessKeyId|SecretAccessKey|SessionToken|Plaintext)"\s*:\s*"[^"]*"`)
+)
+
+// volatileParams change on every request even when the request is otherwise
+// identical, so they are ignored when matching a request against a cassette.
+var volatileParams = map[string]bool{
+	"Expires":             true,
+	"SignatureMethod":     true,
+	"SignatureVersion":    true,
+	"Timestamp":           true,
+	"X-Amz-Algorithm":     true,
+	"X-Amz-Date":          true,
+	"X-Amz-Expires":       true,
+	"X-Amz-SignedHeaders": true,
+}
+
+// RecordedRequest is the on-disk form of a request made through a Recorder.
+type RecordedRequest struct {
+	Method string
+	URL    string
+	Header http.Header
+	Body   string
+	Base64 bool `json:",omitempty"` // Body is base64-encoded binary data.
+}
+
+// RecordedResponse is the on-disk form of a response received by a Recorder.
+type RecordedResponse struct {
+	Status int
+	Header http.Header
+	Body   string
+	Base64 bool `json:",omitempty"` // Body is base64-encoded binary data.
+}
+
+// Interaction is a single request/response pair in a Cassette.
+type Interaction struct {
+	Request  RecordedRequest
+	Response RecordedResponse
+}
+
+// Cassette holds the interactions captured during a recording session.
+type Cassette struct {
+	Interactions []*Interaction
+}
+
+// LoadCassette reads a cassette previously written with Save.
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := &Cassette{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("cannot parse cassette %s: %v", path, err)
+	}
+	return c, nil
+}
+
+// Save writes the cassette to path as indented JSON.
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// Recorder is an http.RoundTripper that records the requests made by a goamz
+// client to a cassette file, or replays them from it.
+//
+// In ModeRecord, requests are sent through Transport (http.DefaultTransport
+// if nil) and every interaction is kept, with signatures and keys scrubbed,
+// until Stop writes the cassette to disk. In ModeReplay, each request is
+// matched against the first unused recorded interaction with the same
+// method, path and canonicalised parameters.
+//
+// Clients that use http.DefaultClient can be pointed at a Recorder by
+// setting http.DefaultTransport; the s3 package accepts one through its
+// Transport field. A typical test selects the mode from the -amazon flag:
+//
+//	mode := testutil.ModeReplay
+//	if testutil.Amazon {
+//	    mode = testutil.ModeRecord
+//	}
+//	rec, err := testutil.NewRecorder("testdata/list.json", mode)
+//	...
+//	defer rec.Stop()
+type Recorder struct {
+	Mode      RecorderMode
+	Transport http.RoundTripper
+
+	path     string
+	mu       sync.Mutex
+	cassette *Cassette
+	used     []bool
+}
+
+// NewRecorder returns a Recorder bound to the cassette at path. In
+// ModeReplay the cassette must already exist.
+func NewRecorder(path string, mode RecorderMode) (*Recorder, error) {
+	r := &Recorder{Mode: mode, path: path, cassette: &Cassette{}}
+	if mode == ModeReplay {
+		c, err := LoadCassette(path)
+		if err != nil {
+			return nil, err
+		}
+		r.cassette = c
+		r.used = make([]bool, len(c.Interactions))
+	}
+	return r, nil
+}
+
+// Cassette returns the cassette being recorded or replayed.
+func (r *Recorder) Cassette() *Cassette {
+	return r.cassette
+}
+
+// Stop writes the cassette to disk when recording. It is a no-op in
+// ModeReplay.
+func (r *Recorder) Stop() error {
+	if r.Mode != ModeRecord {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cassette.Save(r.path)
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+	if r.Mode == ModeReplay {
+		return r.replay(req, body)
+	}
+	return r.record(req, body)
+}
+
+func (r *Recorder) record(req *http.Request, body []byte) (*http.Response, error) {
+	transport := r.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+
+	in := &Interaction{
+		Request: RecordedRequest{
+			Method: req.Method,
+			URL:    scrubURL(req.URL),
+			Header: scrubHeader(req.Header),
+		},
+		Response: RecordedResponse{
+			Status: resp.StatusCode,
+			Header: scrubHeader(resp.Header),
+		},
+	}
+	in.Request.Body, in.Request.Base64 = encodeBody(scrubBody(req.Header, body))
+	in.Response.Body, in.Response.Base64 = encodeBody(scrubBody(resp.Header, respBody))
+
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, in)
+	r.mu.Unlock()
+	return resp, nil
+}
+
+func (r *Recorder) replay(req *http.Request, body []byte) (*http.Response, error) {
+	key := matchKey(req.Method, req.URL, req.Header, body)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, in := range r.cassette.Interactions {
+		if r.used[i] {
+			continue
+		}
+		recorded, err := in.Request.matchKey()
+		if err != nil {
+			return nil, err
+		}
+		if recorded != key {
+			continue
+		}
+		r.used[i] = true
+		data, err := decodeBody(in.Response.Body, in.Response.Base64)
+		if err != nil {
+			return nil, err
+		}
+		header := make(http.Header)
+		for k, v := range in.Response.Header {
+			header[k] = v
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", in.Response.Status, http.StatusText(in.Response.Status)),
+			StatusCode:    in.Response.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          ioutil.NopCloser(bytes.NewReader(data)),
+			ContentLength: int64(len(data)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("no recorded interaction in %s matches %s", r.path, key)
+}
+
+// readBody consumes the request body and replaces it with an
+// identical reader so the request can still be sent.
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+	data, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(data))
+	return data, nil
+}
+
+func (rr *RecordedRequest) matchKey() (string, error) {
+	u, err := url.Parse(rr.URL)
+	if err != nil {
+		return "", err
+	}
+	body, err := decodeBody(rr.Body, rr.Base64)
+	if err != nil {
+		return "", err
+	}
+	return matchKey(rr.Method, u, rr.Header, body), nil
+}
+
+// matchKey canonicalises a request into a string holding its method, path
+// and non-volatile parameters. Form-encoded bodies are merged into the
+// parameters, and the X-Amz-Target header of JSON APIs is included since
+// it names the operation.
+func matchKey(method string, u *url.URL, header http.Header, body []byte) string {
+	params := make(url.Values)
+	for k, v := range u.Query() {
+		params[k] = v
+	}
+	if isForm(header) {
+		form, _ := url.ParseQuery(string(body))
+		for k, v := range form {
+			params[k] = append(params[k], v...)
+		}
+	}
+	for k := range params {
+		if volatileParams[k] || secretParams[k] {
+			delete(params, k)
+		}
+	}
+	if target := header.Get("X-Amz-Target"); target != "" {
+		params.Set("X-Amz-Target", target)
+	}
+	return method + " " + u.Path + "?" + params.Encode()
+}
+
+func isForm(header http.Header) bool {
+	return strings.HasPrefix(header.Get("Content-Type"), "application/x-www-form-urlencoded")
+}
+
+func scrubHeader(h http.Header) http.Header {
+	result := make(http.Header)
+	for k, v := range h {
+		result[k] = v
+	}
+	for _, k := range secretHeaders {
+		if _, ok := result[k]; ok {
+			result[k] = []string{scrubbed}
+		}
+	}
+	return result
+}
+
+func scrubValues(v url.Values) {
+	for k := range v {
+		if secretParams[k] {
+			v[k] = []string{scrubbed}
+		}
+	}
+}
+
+func scrubURL(u *url.URL) string {
+	c := *u
+	q := c.Query()
+	scrubValues(q)
+	c.RawQuery = q.Encode()
+	if c.Opaque != "" {
+		// The s3 package sets Opaque to control path escaping.
+		c.Opaque = ""
+	}
+	return c.String()
+}
+
+func scrubBody(header http.Header, body []byte) []byte {
+	if isForm(header) {
+		form, err := url.ParseQuery(string(body))
+		if err == nil {
+			scrubValues(form)
+			return []byte(form.Encode())
+		}
+	}
+	body = secretElements.ReplaceAll(body, []byte("<$1>"+scrubbed+"</$2>"))
+	body = secretFields.ReplaceAll(body, []byte(`"$1":"`+scrubbed+`"`))
+	return body
+}
+
+func encodeBody(data []byte) (body string, isBase64 bool) {
+	if utf8.Valid(data) {
+		return string(data), false
+	}
+	return base64.StdEncoding.EncodeToString(data), true
+}
+
+func decodeBody(body string, isBase64 bool) ([]byte, error) {
+	if isBase64 {
+		return base64.StdEncoding.DecodeString(body)
+	}
+	return []byte(body), nil
+}
@@ -0,0 +1,99 @@
+package testutil_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/AdRoll/goamz/testutil"
+	"gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) {
+	check.TestingT(t)
+}
+
+type RecorderSuite struct{}
+
+var _ = check.Suite(&RecorderSuite{})
+
+func get(c *check.C, client *http.Client, u string) string {
+	resp, err := client.Get(u)
+	c.Assert(err, check.IsNil)
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	c.Assert(err, check.IsNil)
+	return string(data)
+}
+
+func (s *RecorderSuite) TestRecordAndReplay(c *check.C) {
+	live := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/xml")
+		w.Write([]byte("<Result>" + req.URL.Query().Get("Action") + "</Result>"))
+	}))
+	path := filepath.Join(c.MkDir(), "cassette.json")
+
+	rec, err := testutil.NewRecorder(path, testutil.ModeRecord)
+	c.Assert(err, check.IsNil)
+	client := &http.Client{Transport: rec}
+	c.Assert(get(c, client, live.URL+"/?Action=One&Signature=secret&Timestamp=1"), check.Equals, "<Result>One</Result>")
+	c.Assert(get(c, client, live.URL+"/?Action=Two&Signature=secret&Timestamp=1"), check.Equals, "<Result>Two</Result>")
+	c.Assert(rec.Stop(), check.IsNil)
+	live.Close()
+
+	data, err := ioutil.ReadFile(path)
+	c.Assert(err, check.IsNil)
+	c.Assert(strings.Contains(string(data), "secret"), check.Equals, false)
+
+	rec, err = testutil.NewRecorder(path, testutil.ModeReplay)
+	c.Assert(err, check.IsNil)
+	client = &http.Client{Transport: rec}
+	// Signatures and timestamps differ between sessions and are ignored.
+	c.Assert(get(c, client, "http://replay.invalid/?Timestamp=2&Action=Two&Signature=other"), check.Equals, "<Result>Two</Result>")
+	c.Assert(get(c, client, "http://replay.invalid/?Action=One"), check.Equals, "<Result>One</Result>")
+
+	_, err = client.Get("http://replay.invalid/?Action=One")
+	c.Assert(err, check.ErrorMatches, ".*no recorded interaction .* matches GET /\\?Action=One")
+}
+
+func (s *RecorderSuite) TestRecordScrubsSecrets(c *check.C) {
+	live := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("<Credentials><AccessKeyId>AKID</AccessKeyId><SecretAccessKey>shh</SecretAccessKey></Credentials>"))
+	}))
+	defer live.Close()
+	path := filepath.Join(c.MkDir(), "cassette.json")
+
+	rec, err := testutil.NewRecorder(path, testutil.ModeRecord)
+	c.Assert(err, check.IsNil)
+	client := &http.Client{Transport: rec}
+	req, err := http.NewRequest("POST", live.URL+"/", strings.NewReader(url.Values{
+		"Action":         {"GetSessionToken"},
+		"AWSAccessKeyId": {"AKID"},
+		"Signature":      {"sig"},
+	}.Encode()))
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential=AKID")
+	req.Header.Set("X-Amz-Copy-Source-Server-Side-Encryption-Customer-Key", "key")
+	resp, err := client.Do(req)
+	c.Assert(err, check.IsNil)
+	resp.Body.Close()
+	c.Assert(rec.Stop(), check.IsNil)
+
+	cassette, err := testutil.LoadCassette(path)
+	c.Assert(err, check.IsNil)
+	c.Assert(cassette.Interactions, check.HasLen, 1)
+	in := cassette.Interactions[0]
+	c.Assert(in.Request.Header.Get("Authorization"), check.Equals, "SCRUBBED")
+	c.Assert(in.Request.Header.Get("X-Amz-Copy-Source-Server-Side-Encryption-Customer-Key"), check.Equals, "SCRUBBED")
+	form, err := url.ParseQuery(in.Request.Body)
+	c.Assert(err, check.IsNil)
+	c.Assert(form.Get("Action"), check.Equals, "GetSessionToken")
+	c.Assert(form.Get("AWSAccessKeyId"), check.Equals, "SCRUBBED")
+	c.Assert(form.Get("Signature"), check.Equals, "SCRUBBED")
+	c.Assert(in.Response.Body, check.Equals, "<Credentials><AccessKeyId>SCRUBBED</AccessKeyId><SecretAccessKey>SCRUBBED</SecretAccessKey></Credentials>")
+}